@@ -0,0 +1,282 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/oidc/oidctest"
+	"github.com/Sudan23/dhukuti/internal/totp"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+	assert.NotEmpty(t, user.Token)
+
+	tests := []struct {
+		name   string
+		path   string
+		body   gin.H
+		status int
+	}{
+		{"duplicate email", "/api/v1/auth/register", gin.H{"email": "ana@example.com", "password": testPassword, "name": "Ana"}, http.StatusConflict},
+		{"weak password", "/api/v1/auth/register", gin.H{"email": "bob@example.com", "password": "short", "name": "Bob"}, http.StatusBadRequest},
+		{"invalid email", "/api/v1/auth/register", gin.H{"email": "bob", "password": testPassword, "name": "Bob"}, http.StatusBadRequest},
+		{"login", "/api/v1/auth/login", gin.H{"email": "ana@example.com", "password": testPassword}, http.StatusOK},
+		{"wrong password", "/api/v1/auth/login", gin.H{"email": "ana@example.com", "password": "wrong-password"}, http.StatusUnauthorized},
+		{"unknown email", "/api/v1/auth/login", gin.H{"email": "bob@example.com", "password": testPassword}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.status, s.do("POST", tt.path, "", tt.body))
+		})
+	}
+
+	w := s.do("GET", "/api/v1/me", user.Token, nil)
+	expect(t, http.StatusOK, w)
+	var me struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	decode(t, w, &me)
+	assert.Equal(t, "ana@example.com", me.Email)
+	assert.False(t, me.EmailVerified)
+}
+
+func TestVerifyEmail(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/auth/verify-email", "", gin.H{"token": "bogus"}))
+
+	// Another link can't be requested right after registering
+	expect(t, http.StatusTooManyRequests, s.do("POST", "/api/v1/auth/resend-verification", user.Token, nil))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/verify-email", "", gin.H{"token": s.mail.token(t, user.Email)}))
+
+	var me struct {
+		EmailVerified bool `json:"email_verified"`
+	}
+	decode(t, s.do("GET", "/api/v1/me", user.Token, nil), &me)
+	assert.True(t, me.EmailVerified)
+}
+
+func TestPasswordReset(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	// Unknown addresses get the same answer
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/forgot-password", "", gin.H{"email": "nobody@example.com"}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/forgot-password", "", gin.H{"email": user.Email}))
+
+	newPassword := "a-brand-new-passphrase"
+	token := s.mail.token(t, user.Email)
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/reset-password", "", gin.H{"token": token, "new_password": newPassword}))
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/auth/reset-password", "", gin.H{"token": token, "new_password": newPassword}))
+
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": testPassword}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": newPassword}))
+}
+
+func TestAccountLockout(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.RateLimit.FreeFailures = 100
+		cfg.RateLimit.LockoutThreshold = 3
+	})
+	user := s.register("ana@example.com")
+	login := gin.H{"email": user.Email, "password": testPassword}
+
+	for i := 0; i < 3; i++ {
+		expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": "wrong-password"}))
+	}
+	w := s.do("POST", "/api/v1/auth/login", "", login)
+	expect(t, http.StatusTooManyRequests, w)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/auth/unlock", "", gin.H{"token": "bogus"}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/unlock", "", gin.H{"token": s.mail.token(t, user.Email)}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/login", "", login))
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	login := func(s *testServer, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(`{"email":"nobody@example.com","password":"wrong-password"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		return s.serve(req)
+	}
+
+	t.Run("X-Forwarded-For from clients is ignored", func(t *testing.T) {
+		s := newTestServer(t, func(cfg *config.Config) {
+			cfg.RateLimit.IPRequests = 2
+		})
+		expect(t, http.StatusUnauthorized, login(s, "203.0.113.1"))
+		expect(t, http.StatusUnauthorized, login(s, "203.0.113.2"))
+		expect(t, http.StatusTooManyRequests, login(s, "203.0.113.3"))
+	})
+
+	t.Run("X-Forwarded-For from trusted proxies is used", func(t *testing.T) {
+		s := newTestServer(t, func(cfg *config.Config) {
+			cfg.RateLimit.IPRequests = 2
+			cfg.Server.TrustedProxies = []string{"192.0.2.0/24"} // httptest's remote address
+		})
+		for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+			expect(t, http.StatusUnauthorized, login(s, ip))
+		}
+		expect(t, http.StatusUnauthorized, login(s, "203.0.113.1"))
+		expect(t, http.StatusTooManyRequests, login(s, "203.0.113.1"))
+	})
+}
+
+func TestTwoFactor(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	w := s.do("POST", "/api/v1/auth/2fa/setup", user.Token, nil)
+	expect(t, http.StatusOK, w)
+	var setup struct {
+		Secret string `json:"secret"`
+	}
+	decode(t, w, &setup)
+	require.NotEmpty(t, setup.Secret)
+
+	// Each code is accepted once, so every step uses a different one
+	step := totp.Step(time.Now())
+	code := func(offset int64) string {
+		code, err := totp.CodeAt(setup.Secret, step+offset)
+		require.NoError(t, err)
+		return code
+	}
+
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/auth/2fa/enable", user.Token, gin.H{"code": "000000"}))
+	w = s.do("POST", "/api/v1/auth/2fa/enable", user.Token, gin.H{"code": code(-1)})
+	expect(t, http.StatusOK, w)
+	var enabled struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	decode(t, w, &enabled)
+	assert.NotEmpty(t, enabled.RecoveryCodes)
+
+	// Logging in now takes a second step
+	w = s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": testPassword})
+	expect(t, http.StatusOK, w)
+	var challenge struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	decode(t, w, &challenge)
+	require.True(t, challenge.TwoFactorRequired)
+
+	// The challenge is not a session
+	expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/me", challenge.ChallengeToken, nil))
+
+	w = s.do("POST", "/api/v1/auth/2fa/verify", "", gin.H{"challenge_token": challenge.ChallengeToken, "code": code(0)})
+	expect(t, http.StatusOK, w)
+	var session struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &session)
+	expect(t, http.StatusOK, s.do("GET", "/api/v1/me", session.Token, nil))
+
+	w = s.do("POST", "/api/v1/auth/2fa/recovery-codes", session.Token, gin.H{"code": code(1)})
+	expect(t, http.StatusOK, w)
+	var regenerated struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	decode(t, w, &regenerated)
+	assert.NotEqual(t, enabled.RecoveryCodes, regenerated.RecoveryCodes)
+
+	// Old recovery codes were replaced
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/auth/2fa/disable", session.Token,
+		gin.H{"password": testPassword, "recovery_code": enabled.RecoveryCodes[0]}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/2fa/disable", session.Token,
+		gin.H{"password": testPassword, "recovery_code": regenerated.RecoveryCodes[0]}))
+
+	w = s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": testPassword})
+	expect(t, http.StatusOK, w)
+	assert.Contains(t, w.Body.String(), `"token"`)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := oidctest.NewProvider("dhukuti")
+	t.Cleanup(provider.Close)
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.OIDC.Providers = []config.OIDCProviderConfig{{
+			Name:     "mock",
+			Issuer:   provider.Issuer(),
+			ClientID: "dhukuti",
+			Scopes:   []string{"openid", "email", "profile"},
+		}}
+	})
+
+	w := s.do("GET", "/api/v1/auth/oidc/providers", "", nil)
+	expect(t, http.StatusOK, w)
+	assert.JSONEq(t, `{"providers":["mock"]}`, w.Body.String())
+
+	expect(t, http.StatusNotFound, s.do("GET", "/api/v1/auth/oidc/unknown/login", "", nil))
+	expect(t, http.StatusNotFound, s.do("GET", "/api/v1/auth/oidc/unknown/callback", "", nil))
+
+	// The provider sends the browser straight back with a code
+	w = s.do("GET", "/api/v1/auth/oidc/mock/login", "", nil)
+	expect(t, http.StatusFound, w)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	stateCookie := cookies[0]
+	assert.Equal(t, "/api/v1/auth/oidc/mock/callback", stateCookie.Path)
+	assert.True(t, stateCookie.HttpOnly)
+	assert.True(t, stateCookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, stateCookie.SameSite)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(w.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	finish := func(cookie *http.Cookie) (*httptest.ResponseRecorder, url.Values) {
+		req := httptest.NewRequest("GET", callback.RequestURI(), nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := s.serve(req)
+		expect(t, http.StatusFound, w)
+		redirect, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "/auth/callback", redirect.Path)
+		fragment, err := url.ParseQuery(redirect.Fragment)
+		require.NoError(t, err)
+		return w, fragment
+	}
+
+	// A callback URL opened in another browser is refused, without using up
+	// the login
+	_, fragment := finish(nil)
+	assert.Equal(t, "invalid_state", fragment.Get("error"))
+	_, fragment = finish(&http.Cookie{Name: "oidc_state", Value: "someone-elses-state"})
+	assert.Equal(t, "invalid_state", fragment.Get("error"))
+
+	w, fragment = finish(stateCookie)
+	require.NotEmpty(t, fragment.Get("token"), fragment.Encode())
+	cookies = w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Negative(t, cookies[0].MaxAge, "the cookie is cleared")
+
+	var me struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	decode(t, s.do("GET", "/api/v1/me", fragment.Get("token"), nil), &me)
+	assert.Equal(t, provider.Identity.Email, me.Email)
+	assert.True(t, me.EmailVerified)
+}
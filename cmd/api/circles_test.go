@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// circleView is the part of a circle response the tests look at
+type circleView struct {
+	ID                  uint   `json:"id"`
+	AmountPerMember     uint   `json:"amount_per_member"`
+	ProposedAmount      uint   `json:"proposed_amount"`
+	PendingApprovals    []uint `json:"pending_approvals"`
+	NeedsAmountApproval bool   `json:"needs_amount_approval"`
+	Members             []struct {
+		ID     uint   `json:"id"`
+		Role   string `json:"role"`
+		Status string `json:"status"`
+	} `json:"members"`
+}
+
+// memberStatus returns the status of a member, or "" for non-members
+func (c circleView) memberStatus(userID uint) string {
+	for _, member := range c.Members {
+		if member.ID == userID {
+			return member.Status
+		}
+	}
+	return ""
+}
+
+// createCircle creates a circle through the API and returns its path
+func (s *testServer) createCircle(admin testUser, amount uint) string {
+	s.t.Helper()
+	w := s.do("POST", "/api/v1/circles", admin.Token, gin.H{"name": "Savers", "amount_per_member": amount})
+	expect(s.t, http.StatusCreated, w)
+	var circle circleView
+	decode(s.t, w, &circle)
+	return fmt.Sprintf("/api/v1/circles/%d", circle.ID)
+}
+
+// getCircle fetches a circle as a member sees it
+func (s *testServer) getCircle(path string, user testUser) circleView {
+	s.t.Helper()
+	w := s.do("GET", path, user.Token, nil)
+	expect(s.t, http.StatusOK, w)
+	var circle circleView
+	decode(s.t, w, &circle)
+	return circle
+}
+
+func TestCreateCircle(t *testing.T) {
+	s := newTestServer(t)
+	unverified := s.register("new@example.com")
+	admin := s.verifiedUser("admin@example.com")
+
+	expect(t, http.StatusForbidden, s.do("POST", "/api/v1/circles", unverified.Token, gin.H{"name": "Savers", "amount_per_member": 100}))
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/circles", admin.Token, gin.H{"name": "Savers"}))
+
+	path := s.createCircle(admin, 100)
+
+	w := s.do("GET", "/api/v1/circles", admin.Token, nil)
+	expect(t, http.StatusOK, w)
+	var circles []circleView
+	decode(t, w, &circles)
+	require.Len(t, circles, 1)
+	assert.Equal(t, uint(100), circles[0].AmountPerMember)
+
+	circle := s.getCircle(path, admin)
+	require.Len(t, circle.Members, 1)
+	assert.Equal(t, "admin", circle.Members[0].Role)
+	assert.Equal(t, "active", circle.Members[0].Status)
+
+	// Outsiders can't see the circle
+	outsider := s.verifiedUser("outsider@example.com")
+	expect(t, http.StatusNotFound, s.do("GET", path, outsider.Token, nil))
+	expect(t, http.StatusBadRequest, s.do("GET", "/api/v1/circles/abc", admin.Token, nil))
+}
+
+func TestMembership(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	carol := s.register("carol@example.com")
+	path := s.createCircle(admin, 100)
+
+	// The admin is the only vote, so Bob joins straight away
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	assert.Equal(t, "active", s.getCircle(path, admin).memberStatus(bob.ID))
+
+	tests := []struct {
+		name   string
+		user   testUser
+		path   string
+		body   gin.H
+		status int
+	}{
+		{"unknown circle", admin, "/api/v1/circles/999/members", gin.H{"user_id": carol.ID}, http.StatusNotFound},
+		{"not an admin", bob, path + "/members", gin.H{"user_id": carol.ID}, http.StatusForbidden},
+		{"unknown user", admin, path + "/members", gin.H{"user_id": 999}, http.StatusNotFound},
+		{"unverified user", admin, path + "/members", gin.H{"user_id": carol.ID}, http.StatusForbidden},
+		{"already a member", admin, path + "/members", gin.H{"user_id": bob.ID}, http.StatusConflict},
+		{"invalid role", admin, path + "/members", gin.H{"user_id": carol.ID, "role": "owner"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.status, s.do("POST", tt.path, tt.user.Token, tt.body))
+		})
+	}
+
+	// Now Bob has a vote too
+	require.NoError(t, database.DB.Model(&models.User{}).Where("id = ?", carol.ID).Update("email_verified_at", time.Now()).Error)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": carol.ID}))
+
+	circle := s.getCircle(path, bob)
+	assert.Equal(t, "pending", circle.memberStatus(carol.ID))
+	assert.Equal(t, []uint{carol.ID}, circle.PendingApprovals)
+
+	approve := fmt.Sprintf("%s/approve/%d", path, carol.ID)
+	outsider := s.verifiedUser("outsider@example.com")
+	expect(t, http.StatusNotFound, s.do("POST", approve, outsider.Token, nil))
+	expect(t, http.StatusOK, s.do("POST", approve, bob.Token, nil))
+
+	circle = s.getCircle(path, bob)
+	assert.Equal(t, "active", circle.memberStatus(carol.ID))
+	assert.Empty(t, circle.PendingApprovals)
+}
+
+func TestAmountChange(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+
+	expect(t, http.StatusForbidden, s.do("POST", path+"/propose-amount", bob.Token, gin.H{"new_amount": 200}))
+	expect(t, http.StatusBadRequest, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": 0}))
+	expect(t, http.StatusOK, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": 200}))
+
+	circle := s.getCircle(path, bob)
+	assert.True(t, circle.NeedsAmountApproval)
+	assert.Equal(t, uint(200), circle.ProposedAmount)
+	assert.Equal(t, uint(100), circle.AmountPerMember)
+
+	expect(t, http.StatusNotFound, s.do("POST", path+"/approve-amount", admin.Token, nil))
+	expect(t, http.StatusOK, s.do("POST", path+"/approve-amount", bob.Token, nil))
+
+	circle = s.getCircle(path, bob)
+	assert.False(t, circle.NeedsAmountApproval)
+	assert.Equal(t, uint(200), circle.AmountPerMember)
+	assert.Zero(t, circle.ProposedAmount)
+}
+
+func TestRecordContribution(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	outsider := s.verifiedUser("outsider@example.com")
+	path := s.createCircle(admin, 100)
+
+	expect(t, http.StatusCreated, s.do("POST", path+"/contributions", admin.Token, nil))
+	expect(t, http.StatusForbidden, s.do("POST", path+"/contributions", outsider.Token, nil))
+	expect(t, http.StatusNotFound, s.do("POST", "/api/v1/circles/999/contributions", admin.Token, nil))
+
+	var contributions []models.Contribution
+	require.NoError(t, database.DB.Find(&contributions).Error)
+	require.Len(t, contributions, 1)
+	assert.Equal(t, uint(100), contributions[0].Amount)
+}
+
+func TestCircleSecurity(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+
+	expect(t, http.StatusBadRequest, s.do("PUT", path+"/security", admin.Token, gin.H{}))
+	expect(t, http.StatusForbidden, s.do("PUT", path+"/security", bob.Token, gin.H{"require_two_factor": false}))
+
+	// Admins need two-factor authentication themselves first
+	w := s.do("PUT", path+"/security", admin.Token, gin.H{"require_two_factor": true})
+	expect(t, http.StatusForbidden, w)
+	assert.Contains(t, w.Body.String(), "Enable two-factor authentication")
+
+	expect(t, http.StatusOK, s.do("PUT", path+"/security", admin.Token, gin.H{"require_two_factor": false}))
+}
+
+func TestCircleReminders(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+
+	expect(t, http.StatusBadRequest, s.do("PUT", path+"/reminders", admin.Token, gin.H{"payment_due_day": 31}))
+	expect(t, http.StatusForbidden, s.do("PUT", path+"/reminders", bob.Token, gin.H{"payment_due_day": 10}))
+
+	w := s.do("PUT", path+"/reminders", admin.Token, gin.H{"payment_due_day": 10, "reminders_enabled": false})
+	expect(t, http.StatusOK, w)
+	var settings struct {
+		PaymentDueDay      int  `json:"payment_due_day"`
+		RemindersEnabled   bool `json:"reminders_enabled"`
+		ReminderDaysBefore int  `json:"reminder_days_before"`
+	}
+	decode(t, w, &settings)
+	assert.Equal(t, 10, settings.PaymentDueDay)
+	assert.False(t, settings.RemindersEnabled)
+	assert.Equal(t, 3, settings.ReminderDaysBefore, "omitted settings are unchanged")
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrently sends the requests at the same time and returns their statuses
+func (s *testServer) concurrently(requests []func() int) []int {
+	statuses := make([]int, len(requests))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		wg.Add(1)
+		go func(i int, request func() int) {
+			defer wg.Done()
+			<-start
+			statuses[i] = request()
+		}(i, request)
+	}
+	close(start)
+	wg.Wait()
+	return statuses
+}
+
+// countEvents counts the circle's outbox messages of one type
+func countEvents(t *testing.T, circleID uint, eventType events.Type) int64 {
+	t.Helper()
+	var count int64
+	require.NoError(t, database.DB.Model(&models.OutboxMessage{}).
+		Where("circle_id = ? AND event_type = ?", circleID, string(eventType)).Count(&count).Error)
+	return count
+}
+
+// TestConcurrentGovernance sends conflicting changes to a circle at once. On
+// Postgres, which CI runs it against too, the requests run in parallel
+// transactions and only the circle lock keeps their outcomes consistent;
+// SQLite runs one write transaction at a time, so it can't catch a missing
+// lock.
+func TestConcurrentGovernance(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	path := s.createCircle(admin, 100)
+	id, err := strconv.Atoi(strings.TrimPrefix(path, "/api/v1/circles/"))
+	require.NoError(t, err)
+	circleID := uint(id)
+
+	// Start with a circle of active members
+	members := []testUser{admin}
+	for i := 0; i < 6; i++ {
+		member := s.verifiedUser(fmt.Sprintf("member%d@example.com", i))
+		require.NoError(t, database.DB.Create(&models.CircleMember{CircleID: circleID, UserID: member.ID, Role: "member", Status: "active"}).Error)
+		members = append(members, member)
+	}
+
+	// Races don't show every time, so there are a few rounds
+	for round, name := range []string{"carol", "dave", "erin"} {
+		invitee := s.verifiedUser(name + "@example.com")
+
+		t.Run(name+": the same invitation twice", func(t *testing.T) {
+			var requests []func() int
+			for i := 0; i < 5; i++ {
+				requests = append(requests, func() int {
+					return s.do("POST", path+"/members", admin.Token, gin.H{"user_id": invitee.ID}).Code
+				})
+			}
+			statuses := s.concurrently(requests)
+			assert.ElementsMatch(t, []int{201, 409, 409, 409, 409}, statuses)
+
+			var count int64
+			require.NoError(t, database.DB.Model(&models.CircleMember{}).Where("circle_id = ? AND user_id = ?", circleID, invitee.ID).Count(&count).Error)
+			assert.Equal(t, int64(1), count)
+		})
+
+		t.Run(name+": every vote at once", func(t *testing.T) {
+			var requests []func() int
+			for _, member := range members[1:] {
+				member := member
+				requests = append(requests, func() int {
+					return s.do("POST", fmt.Sprintf("%s/approve/%d", path, invitee.ID), member.Token, nil).Code
+				})
+			}
+			for _, status := range s.concurrently(requests) {
+				assert.Equal(t, http.StatusOK, status)
+			}
+
+			assert.Equal(t, "active", s.getCircle(path, admin).memberStatus(invitee.ID))
+			assert.Equal(t, int64(round+1), countEvents(t, circleID, events.MemberApproved), "activated exactly once")
+		})
+		members = append(members, invitee)
+	}
+
+	t.Run("proposals while voting", func(t *testing.T) {
+		var requests []func() int
+		for round := 0; round < 3; round++ {
+			amount := 200 + round*100
+			requests = append(requests, func() int {
+				return s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": amount}).Code
+			})
+			for _, member := range members[1:] {
+				member := member
+				requests = append(requests, func() int {
+					return s.do("POST", path+"/approve-amount", member.Token, nil).Code
+				})
+			}
+		}
+		statuses := s.concurrently(requests)
+
+		proposals := 0
+		for i, status := range statuses {
+			if i%len(members) == 0 {
+				require.Equal(t, http.StatusOK, status, "proposal %d", i/len(members))
+				proposals++
+			} else {
+				assert.Contains(t, []int{http.StatusOK, http.StatusNotFound}, status, "a vote either counts or finds no proposal")
+			}
+		}
+
+		var circle models.Circle
+		require.NoError(t, database.DB.First(&circle, circleID).Error)
+		var votes []models.AmountApproval
+		require.NoError(t, database.DB.Where("circle_id = ?", circleID).Find(&votes).Error)
+
+		changes := countEvents(t, circleID, events.AmountChanged)
+		if circle.ProposedAmount == 0 {
+			// The last proposal was accepted and its votes cleared
+			assert.Empty(t, votes)
+			assert.NotZero(t, changes)
+		} else {
+			// One proposal is open with one vote per member, all on it
+			assert.Len(t, votes, len(members))
+			for _, vote := range votes {
+				assert.Equal(t, circle.ProposedAmount, vote.ProposedAmount)
+			}
+		}
+		assert.Equal(t, uint(1+proposals)+uint(changes), circle.Version, "every change moved the version on once")
+	})
+}
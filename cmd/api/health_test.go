@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// probe requests a probe and decodes its report
+func (s *testServer) probe(path string, status int) health.Report {
+	s.t.Helper()
+	w := s.do("GET", path, "", nil)
+	expect(s.t, status, w)
+	var report health.Report
+	decode(s.t, w, &report)
+	return report
+}
+
+func TestProbes(t *testing.T) {
+	t.Run("a healthy instance is live and ready", func(t *testing.T) {
+		s := newTestServer(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		s.checker.Go(ctx, "outbox", func(ctx context.Context) { <-ctx.Done() })
+
+		assert.Equal(t, health.StatusOK, s.probe("/livez", http.StatusOK).Status)
+		report := s.probe("/readyz", http.StatusOK)
+		assert.Equal(t, map[string]string{
+			"database":      "ok",
+			"migrations":    "ok",
+			"worker:outbox": "ok",
+		}, report.Checks)
+	})
+
+	t.Run("an unreachable database fails readiness only", func(t *testing.T) {
+		logs := captureLogs(t)
+		s := newTestServer(t)
+		sqlDB, err := database.DB.DB()
+		require.NoError(t, err)
+		require.NoError(t, sqlDB.Close())
+
+		s.probe("/livez", http.StatusOK)
+		report := s.probe("/readyz", http.StatusServiceUnavailable)
+		assert.Equal(t, health.StatusFail, report.Status)
+		assert.Equal(t, health.StatusFail, report.Checks["database"])
+		assert.Contains(t, logs.String(), "closed", "the reason is only logged")
+	})
+
+	t.Run("pending migrations fail readiness", func(t *testing.T) {
+		logs := captureLogs(t)
+		s := newTestServer(t)
+		migrations, err := database.LoadMigrations(database.DB.Dialector.Name())
+		require.NoError(t, err)
+		last := migrations[len(migrations)-1]
+		require.NoError(t, database.DB.Exec("DELETE FROM schema_migrations WHERE version = ?", last.Version).Error)
+
+		report := s.probe("/readyz", http.StatusServiceUnavailable)
+		assert.Equal(t, health.StatusFail, report.Checks["migrations"])
+		assert.Contains(t, logs.String(), "1 pending")
+		assert.Equal(t, "ok", report.Checks["database"])
+	})
+
+	t.Run("a stopped worker fails readiness", func(t *testing.T) {
+		s := newTestServer(t)
+		s.checker.Go(context.Background(), "digests", func(context.Context) {})
+		require.NoError(t, s.checker.Wait(context.Background()))
+
+		s.probe("/livez", http.StatusOK)
+		report := s.probe("/readyz", http.StatusServiceUnavailable)
+		assert.Equal(t, health.StatusFail, report.Checks["worker:digests"])
+	})
+
+	t.Run("a failed worker fails both probes", func(t *testing.T) {
+		logs := captureLogs(t)
+		s := newTestServer(t)
+		s.checker.Go(context.Background(), "reminders", func(context.Context) { panic("no channels") })
+		require.NoError(t, s.checker.Wait(context.Background()))
+
+		assert.Equal(t, health.StatusFail, s.probe("/livez", http.StatusServiceUnavailable).Checks["worker:reminders"])
+		assert.Equal(t, health.StatusFail, s.probe("/readyz", http.StatusServiceUnavailable).Checks["worker:reminders"])
+		assert.Contains(t, logs.String(), `"msg":"Background worker failed"`)
+		assert.Contains(t, logs.String(), "no channels")
+	})
+
+	t.Run("an instance shutting down is no longer ready", func(t *testing.T) {
+		s := newTestServer(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		s.checker.Go(ctx, "stream", func(ctx context.Context) { <-ctx.Done() })
+
+		s.checker.ShutDown()
+		s.probe("/livez", http.StatusOK)
+		assert.Equal(t, health.StatusFail, s.probe("/readyz", http.StatusServiceUnavailable).Checks["shutdown"])
+
+		cancel()
+		waitCtx, done := context.WithTimeout(context.Background(), time.Second)
+		defer done()
+		assert.NoError(t, s.checker.Wait(waitCtx))
+	})
+
+	t.Run("workers that ignore cancellation time out", func(t *testing.T) {
+		s := newTestServer(t)
+		release := make(chan struct{})
+		defer close(release)
+		s.checker.Go(context.Background(), "outbox", func(context.Context) { <-release })
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, s.checker.Wait(ctx), context.DeadlineExceeded)
+	})
+}
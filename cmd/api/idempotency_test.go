@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// doWithKey sends a POST request with an Idempotency-Key header
+func (s *testServer) doWithKey(path, token, key string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(s.t, err)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Idempotency-Key", key)
+	return s.serve(req)
+}
+
+// countContributions counts the contributions recorded in all circles
+func countContributions(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	require.NoError(t, database.DB.Model(&models.Contribution{}).Count(&count).Error)
+	return count
+}
+
+func TestIdempotency(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+
+	t.Run("retries are replayed", func(t *testing.T) {
+		first := s.doWithKey(path+"/contributions", admin.Token, "contribution-1", gin.H{})
+		expect(t, http.StatusCreated, first)
+		retry := s.doWithKey(path+"/contributions", admin.Token, "contribution-1", gin.H{})
+		expect(t, http.StatusCreated, retry)
+
+		assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+		assert.Equal(t, int64(1), countContributions(t))
+	})
+
+	t.Run("keys belong to one user", func(t *testing.T) {
+		w := s.doWithKey(path+"/contributions", bob.Token, "contribution-1", gin.H{})
+		expect(t, http.StatusCreated, w)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, int64(2), countContributions(t))
+	})
+
+	t.Run("a key can't be reused for another request", func(t *testing.T) {
+		expect(t, http.StatusOK, s.doWithKey(path+"/propose-amount", admin.Token, "proposal-1", gin.H{"new_amount": 200}))
+		expect(t, http.StatusUnprocessableEntity, s.doWithKey(path+"/propose-amount", admin.Token, "proposal-1", gin.H{"new_amount": 300}))
+		expect(t, http.StatusUnprocessableEntity, s.doWithKey(path+"/contributions", admin.Token, "proposal-1", gin.H{"new_amount": 200}))
+		assert.Equal(t, uint(200), s.getCircle(path, admin).ProposedAmount)
+	})
+
+	t.Run("client errors release the key", func(t *testing.T) {
+		expect(t, http.StatusBadRequest, s.doWithKey(path+"/propose-amount", admin.Token, "proposal-2", gin.H{"new_amount": 0}))
+		w := s.doWithKey(path+"/propose-amount", admin.Token, "proposal-2", gin.H{"new_amount": 0})
+		expect(t, http.StatusBadRequest, w)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	})
+
+	t.Run("conflicts release the key", func(t *testing.T) {
+		// As if the membership changed while the contribution was recorded
+		failInsert := func(tx *gorm.DB) {
+			if tx.Statement.Table == "contributions" {
+				tx.AddError(gorm.ErrForeignKeyViolated)
+			}
+		}
+		require.NoError(t, database.DB.Callback().Create().Before("gorm:create").Register("test:conflict", failInsert))
+		before := countContributions(t)
+		expect(t, http.StatusConflict, s.doWithKey(path+"/contributions", admin.Token, "conflicted", gin.H{}))
+		require.NoError(t, database.DB.Callback().Create().Remove("test:conflict"))
+
+		w := s.doWithKey(path+"/contributions", admin.Token, "conflicted", gin.H{})
+		expect(t, http.StatusCreated, w)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, before+1, countContributions(t))
+	})
+
+	t.Run("circle creation", func(t *testing.T) {
+		body := gin.H{"name": "Holiday", "amount_per_member": 50}
+		first := s.doWithKey("/api/v1/circles", admin.Token, "circle-1", body)
+		expect(t, http.StatusCreated, first)
+		retry := s.doWithKey("/api/v1/circles", admin.Token, "circle-1", body)
+		expect(t, http.StatusCreated, retry)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+
+		var count int64
+		require.NoError(t, database.DB.Model(&models.Circle{}).Where("name = ?", "Holiday").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("a request still being handled", func(t *testing.T) {
+		expect(t, http.StatusCreated, s.doWithKey(path+"/contributions", admin.Token, "in-flight", gin.H{}))
+		// As if the first request had not finished yet
+		require.NoError(t, database.DB.Model(&models.IdempotencyKey{}).Where("key = ?", "in-flight").Update("status_code", 0).Error)
+
+		w := s.doWithKey(path+"/contributions", admin.Token, "in-flight", gin.H{})
+		expect(t, http.StatusConflict, w)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("an abandoned request", func(t *testing.T) {
+		before := countContributions(t)
+		require.NoError(t, database.DB.Model(&models.IdempotencyKey{}).Where("key = ?", "in-flight").
+			Update("created_at", time.Now().Add(-time.Hour)).Error)
+
+		// It may have committed before its instance went away
+		w := s.doWithKey(path+"/contributions", admin.Token, "in-flight", gin.H{})
+		expect(t, http.StatusConflict, w)
+		assert.Empty(t, w.Header().Get("Retry-After"))
+		assert.Equal(t, before, countContributions(t), "the retry is not handled again")
+	})
+
+	t.Run("a response that can't be stored", func(t *testing.T) {
+		failStore := func(tx *gorm.DB) {
+			if tx.Statement.Table == "idempotency_keys" {
+				tx.AddError(errors.New("disk full"))
+			}
+		}
+		require.NoError(t, database.DB.Callback().Update().Before("gorm:update").Register("test:fail_store", failStore))
+		before := countContributions(t)
+		expect(t, http.StatusCreated, s.doWithKey(path+"/contributions", admin.Token, "unstored", gin.H{}))
+		require.NoError(t, database.DB.Callback().Update().Remove("test:fail_store"))
+
+		expect(t, http.StatusConflict, s.doWithKey(path+"/contributions", admin.Token, "unstored", gin.H{}))
+		assert.Equal(t, before+1, countContributions(t), "the key is kept")
+	})
+
+	t.Run("server errors release the key", func(t *testing.T) {
+		failInsert := func(tx *gorm.DB) {
+			if tx.Statement.Table == "contributions" {
+				tx.AddError(errors.New("disk full"))
+			}
+		}
+		require.NoError(t, database.DB.Callback().Create().Before("gorm:create").Register("test:fail_insert", failInsert))
+		before := countContributions(t)
+		expect(t, http.StatusInternalServerError, s.doWithKey(path+"/contributions", admin.Token, "failed", gin.H{}))
+		require.NoError(t, database.DB.Callback().Create().Remove("test:fail_insert"))
+
+		expect(t, http.StatusCreated, s.doWithKey(path+"/contributions", admin.Token, "failed", gin.H{}))
+		assert.Equal(t, before+1, countContributions(t))
+	})
+
+	t.Run("concurrent retries", func(t *testing.T) {
+		before := countContributions(t)
+		var requests []func() int
+		for i := 0; i < 5; i++ {
+			requests = append(requests, func() int {
+				return s.doWithKey(path+"/contributions", bob.Token, "contribution-2", gin.H{}).Code
+			})
+		}
+		for _, status := range s.concurrently(requests) {
+			assert.Contains(t, []int{http.StatusCreated, http.StatusConflict}, status)
+		}
+		assert.Equal(t, before+1, countContributions(t))
+	})
+
+	t.Run("keys are limited in length", func(t *testing.T) {
+		key := string(bytes.Repeat([]byte("k"), 256))
+		expect(t, http.StatusBadRequest, s.doWithKey(path+"/contributions", admin.Token, key, gin.H{}))
+	})
+
+	t.Run("requests without a key", func(t *testing.T) {
+		before := countContributions(t)
+		expect(t, http.StatusCreated, s.do("POST", path+"/contributions", admin.Token, nil))
+		expect(t, http.StatusCreated, s.do("POST", path+"/contributions", admin.Token, nil))
+		assert.Equal(t, before+2, countContributions(t))
+	})
+}
+
+func TestIdempotencyExpiry(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Idempotency.TTL = time.Nanosecond
+	})
+	admin := s.verifiedUser("admin@example.com")
+	path := s.createCircle(admin, 100)
+
+	expect(t, http.StatusCreated, s.doWithKey(path+"/contributions", admin.Token, "contribution-1", gin.H{}))
+	w := s.doWithKey(path+"/contributions", admin.Token, "contribution-1", gin.H{})
+	expect(t, http.StatusCreated, w)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"), "the key expired")
+	assert.Equal(t, int64(2), countContributions(t))
+}
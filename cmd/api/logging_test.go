@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+// captureLogs sends everything logged, including SQL, to the returned buffer
+// for the rest of the test. It must be called before newTestServer.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(logging.New(&buf, config.LogConfig{Level: "debug", Format: "json"}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines parses the captured JSON log lines
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestRequestLogging(t *testing.T) {
+	buf := captureLogs(t)
+	s := newTestServer(t)
+	database.DB.Logger = logging.NewGormLogger(logger.Info)
+	admin := s.verifiedUser("admin@example.com")
+	path := s.createCircle(admin, 100)
+
+	t.Run("request IDs are kept", func(t *testing.T) {
+		buf.Reset()
+		req := httptest.NewRequest("POST", path+"/contributions", nil)
+		req.Header.Set("Authorization", "Bearer "+admin.Token)
+		req.Header.Set("X-Request-ID", "edge-1234")
+		w := s.serve(req)
+		expect(t, http.StatusCreated, w)
+		assert.Equal(t, "edge-1234", w.Header().Get("X-Request-ID"))
+
+		var queries int
+		var handled map[string]interface{}
+		for _, line := range logLines(t, buf) {
+			assert.Equal(t, "edge-1234", line["request_id"], "every line carries the request ID: %v", line)
+			switch line["msg"] {
+			case "Query":
+				queries++
+			case "Request handled":
+				handled = line
+			}
+		}
+		assert.NotZero(t, queries, "SQL is logged with the request ID")
+		require.NotNil(t, handled)
+		assert.Equal(t, float64(http.StatusCreated), handled["status"])
+		assert.Equal(t, "/api/v1/circles/:id/contributions", handled["route"])
+		assert.Equal(t, float64(admin.ID), handled["user_id"])
+		assert.NotNil(t, handled["circle_id"])
+	})
+
+	t.Run("invalid request IDs are replaced", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/circles", nil)
+		req.Header.Set("Authorization", "Bearer "+admin.Token)
+		req.Header.Set("X-Request-ID", "forged\n{\"level\":\"ERROR\"}")
+		w := s.serve(req)
+		expect(t, http.StatusOK, w)
+		assert.Len(t, w.Header().Get("X-Request-ID"), 36, "a generated UUID")
+
+		w = s.do("GET", "/api/v1/circles", admin.Token, nil)
+		assert.Len(t, w.Header().Get("X-Request-ID"), 36, "one is generated when none is sent")
+	})
+
+	t.Run("secrets are not logged", func(t *testing.T) {
+		buf.Reset()
+		expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": admin.Email, "password": testPassword}))
+		expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/me?token=query-secret", "bad-token", nil))
+
+		logs := buf.String()
+		assert.NotEmpty(t, logs)
+		assert.NotContains(t, logs, testPassword)
+		assert.NotContains(t, logs, admin.Email, "query values are not logged")
+		assert.NotContains(t, logs, "query-secret")
+		assert.NotContains(t, logs, "bad-token")
+	})
+}
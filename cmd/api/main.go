@@ -1,13 +1,32 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Sudan23/dhukuti/internal/config"
 	"github.com/Sudan23/dhukuti/internal/database"
-	"github.com/Sudan23/dhukuti/internal/handlers"
-	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/digest"
+	"github.com/Sudan23/dhukuti/internal/health"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/notification"
+	"github.com/Sudan23/dhukuti/internal/outbox"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/Sudan23/dhukuti/internal/reminder"
+	"github.com/Sudan23/dhukuti/internal/sms"
+	"github.com/Sudan23/dhukuti/internal/stream"
+	"github.com/Sudan23/dhukuti/internal/tracing"
+	"github.com/Sudan23/dhukuti/internal/webhook"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,79 +34,121 @@ func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		fatal("Failed to load configuration", err)
 	}
 
+	// Log JSON lines through slog, including Gin's debug output
+	logging.Setup(cfg.Log)
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(strings.TrimPrefix(format, "[WARNING] "), values...)))
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, handlers int) {
+		slog.Debug("Route registered", "method", method, "path", path, "handler", handler)
+	}
+
+	// Trace requests and queries
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Failed to set up tracing", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
 
 	// Connect to database
 	if err := database.Connect(cfg); err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to connect to database", err)
 	}
 
-	// Run migrations
-	if err := database.Migrate(); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	// `api migrate ...` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
 	}
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(cfg)
-	circleHandler := handlers.NewCircleHandler()
-
-	// Setup router
-	router := gin.Default()
+	// Run migrations
+	if cfg.Database.Migrate {
+		if err := database.Migrate(); err != nil {
+			fatal("Failed to run migrations", err)
+		}
+	}
 
-	// CORS Middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+	// Initialize mailer
+	mail := mailer.New(cfg)
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
+	// Initialize SMS gateway
+	texts := sms.New(cfg)
 
-		c.Next()
-	})
+	// Initialize rate limiter
+	limiter, err := ratelimit.New(cfg, database.DB)
+	if err != nil {
+		fatal("Failed to initialize rate limiter", err)
+	}
 
-	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": "dhukuti-api",
-		})
+	// Configure password hashing and policy
+	password.Configure(password.Params{
+		Memory:      uint32(cfg.Password.Argon2Memory),
+		Iterations:  uint32(cfg.Password.Argon2Iterations),
+		Parallelism: uint8(cfg.Password.Argon2Parallelism),
+		SaltLength:  password.DefaultParams.SaltLength,
+		KeyLength:   password.DefaultParams.KeyLength,
 	})
+	passwordPolicy, err := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxLength, cfg.Password.BreachedListPath)
+	if err != nil {
+		fatal("Failed to load password policy", err)
+	}
 
-	// API v1 routes
-	v1 := router.Group("/api/v1")
-	{
-		// Auth routes (public)
-		auth := v1.Group("/auth")
-		{
-			auth.POST("/register", authHandler.Register)
-			auth.POST("/login", authHandler.Login)
-		}
-
-		// Protected routes
-		protected := v1.Group("")
-		protected.Use(middleware.AuthMiddleware(cfg))
-		{
-			// Circle routes
-			circles := protected.Group("/circles")
-			{
-				circles.POST("", circleHandler.CreateCircle)
-				circles.GET("", circleHandler.ListCircles)
-				circles.GET("/:id", circleHandler.GetCircle)
-				circles.POST("/:id/members", circleHandler.AddMember)
-				circles.POST("/:id/approve/:user_id", circleHandler.ApproveMember)
-				circles.POST("/:id/contributions", circleHandler.RecordContribution)
-				circles.POST("/:id/propose-amount", circleHandler.ProposeAmount)
-				circles.POST("/:id/approve-amount", circleHandler.ApproveAmountChange)
+	// Background workers run until shutdown; the checker reports on them in
+	// the probes
+	checker := health.New(database.DB)
+	workers, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	// Circle events are recorded in the outbox with the change that caused
+	// them and published to the sinks in the background
+	publisher := outbox.NewPublisher()
+	checker.Go(workers, "outbox", outbox.NewDispatcher(database.DB, cfg.Outbox,
+		webhook.NewSink(database.DB),
+		notification.NewSink(database.DB),
+		stream.NewSink(database.DB),
+		sms.NewSink(database.DB, texts, cfg.App.FrontendURL),
+	).Run)
+	checker.Go(workers, "webhooks", webhook.NewDispatcher(database.DB, cfg.Webhook).Run)
+
+	// Payment reminders; one instance at a time sends them
+	if cfg.Reminder.Enabled {
+		var channels []reminder.Channel
+		for _, name := range cfg.Reminder.Channels {
+			switch name {
+			case "in_app":
+				channels = append(channels, reminder.NewInAppChannel(database.DB))
+			case "email":
+				channels = append(channels, reminder.NewEmailChannel(mail, cfg.App.FrontendURL))
+			case "sms":
+				channels = append(channels, reminder.NewSMSChannel(texts, cfg.App.FrontendURL))
+			default:
+				slog.Error("Unknown reminder channel", "channel", name)
+				os.Exit(1)
 			}
 		}
+		checker.Go(workers, "reminders", reminder.NewScheduler(database.DB, cfg.Reminder, channels...).Run)
+	}
+
+	// Email digests; instances claim each digest before sending it
+	if cfg.Digest.Enabled {
+		checker.Go(workers, "digests", digest.NewJob(database.DB, mail, cfg.Digest, cfg.App.FrontendURL).Run)
+	}
+
+	// Forward stream events from all instances to this instance's clients
+	hub := stream.NewHub(database.DB, cfg.GetDSN(), cfg.Stream)
+	checker.Go(workers, "stream", hub.Run)
+
+	router, err := newRouter(cfg, mail, texts, limiter, passwordPolicy, publisher, hub, checker)
+	if err != nil {
+		fatal("Failed to set up routes", err)
 	}
 
 	// Start server
@@ -96,16 +157,60 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting server on port %s...", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Clients can't hold connections by trickling headers. There is no write
+	// timeout, as event streams stay open.
+	server := &http.Server{Addr: ":" + port, Handler: router, ReadHeaderTimeout: readHeaderTimeout}
+	// Event streams never go idle; end them so draining doesn't wait for them
+	server.RegisterOnShutdown(hub.DisconnectAll)
+	failed := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", port)
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			failed <- err
+		}
+	}()
+
+	// Wait for SIGINT or SIGTERM, then fail readiness and keep serving until
+	// load balancers have noticed. Then stop taking connections, let the
+	// requests in flight finish and stop the workers, all within the shutdown
+	// timeout.
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-failed:
+		fatal("Failed to start server", err)
+	case <-signals.Done():
+	}
+	stopSignals()
+
+	slog.Info("Shutting down", "delay", cfg.Server.ShutdownDelay.String(), "timeout", cfg.Server.ShutdownTimeout.String())
+	checker.ShutDown()
+	time.Sleep(cfg.Server.ShutdownDelay)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Failed to drain connections", "error", err)
 	}
+	stopWorkers()
+	if err := checker.Wait(ctx); err != nil {
+		slog.Error("Failed to stop background workers", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// readHeaderTimeout bounds how long a client may take to send its headers
+const readHeaderTimeout = 10 * time.Second
+
+// fatal logs the error that stops the server and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 // init loads environment variables from .env file if it exists
 func init() {
 	// Try to load .env file, but don't fail if it doesn't exist
 	if _, err := os.Stat(".env"); err == nil {
-		log.Println("Note: For .env file support, consider using godotenv package")
+		slog.Info("Note: For .env file support, consider using godotenv package")
 	}
 }
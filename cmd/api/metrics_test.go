@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/gin-gonic/gin"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scrape fetches the metrics and parses them as Prometheus does
+func (s *testServer) scrape(token string) map[string]*dto.MetricFamily {
+	s.t.Helper()
+	w := s.do("GET", "/metrics", token, nil)
+	expect(s.t, http.StatusOK, w)
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(w.Body.String()))
+	require.NoError(s.t, err)
+	return families
+}
+
+// metricValue returns the value of the series with the given labels, or -1
+// if there is none
+func metricValue(families map[string]*dto.MetricFamily, name string, labels map[string]string) float64 {
+	family, ok := families[name]
+	if !ok {
+		return -1
+	}
+	for _, metric := range family.Metric {
+		matches := 0
+		for _, label := range metric.Label {
+			if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+				matches++
+			}
+		}
+		if matches != len(labels) {
+			continue
+		}
+		switch {
+		case metric.Counter != nil:
+			return metric.Counter.GetValue()
+		case metric.Gauge != nil:
+			return metric.Gauge.GetValue()
+		case metric.Histogram != nil:
+			return float64(metric.Histogram.GetSampleCount())
+		}
+	}
+	return -1
+}
+
+func TestMetrics(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	carol := s.verifiedUser("carol@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	// Bob has a vote now, so Carol waits for approval
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": carol.ID}))
+	expect(t, http.StatusOK, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": 200}))
+	expect(t, http.StatusCreated, s.do("POST", path+"/contributions", admin.Token, nil))
+	expect(t, http.StatusCreated, s.do("POST", path+"/contributions", bob.Token, nil))
+	expect(t, http.StatusForbidden, s.do("POST", path+"/contributions", carol.Token, nil))
+	expect(t, http.StatusNotFound, s.do("GET", "/api/v1/circles/1/unknown", admin.Token, nil))
+
+	families := s.scrape("")
+
+	t.Run("http requests by route", func(t *testing.T) {
+		route := "/api/v1/circles/:id/contributions"
+		assert.Equal(t, 2.0, metricValue(families, "dhukuti_http_requests_total", map[string]string{"method": "POST", "route": route, "status": "201"}))
+		assert.Equal(t, 1.0, metricValue(families, "dhukuti_http_requests_total", map[string]string{"method": "POST", "route": route, "status": "403"}))
+		assert.Equal(t, 3.0, metricValue(families, "dhukuti_http_request_duration_seconds", map[string]string{"method": "POST", "route": route}))
+		assert.Equal(t, 1.0, metricValue(families, "dhukuti_http_requests_total", map[string]string{"route": "unmatched", "status": "404"}))
+	})
+
+	t.Run("database pool", func(t *testing.T) {
+		assert.Equal(t, 1.0, metricValue(families, "go_sql_max_open_connections", map[string]string{"db_name": "dhukuti"}))
+	})
+
+	t.Run("business figures", func(t *testing.T) {
+		for name, want := range map[string]float64{
+			"dhukuti_contributions_recorded_total": 2,
+			"dhukuti_contributions_amount_total":   200,
+			"dhukuti_amount_proposals_open":        1,
+			"dhukuti_member_approvals_pending":     1,
+			"dhukuti_circles_active":               1,
+		} {
+			assert.Equal(t, want, metricValue(families, name, nil), name)
+		}
+	})
+
+	t.Run("figures follow the database", func(t *testing.T) {
+		expect(t, http.StatusOK, s.do("POST", fmt.Sprintf("%s/approve/%d", path, carol.ID), bob.Token, nil))
+		families := s.scrape("")
+		assert.Equal(t, 0.0, metricValue(families, "dhukuti_member_approvals_pending", nil))
+	})
+}
+
+func TestMetricsToken(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Metrics.Token = "scrape-secret"
+	})
+
+	expect(t, http.StatusUnauthorized, s.do("GET", "/metrics", "", nil))
+	expect(t, http.StatusUnauthorized, s.do("GET", "/metrics", "wrong", nil))
+	assert.Contains(t, s.scrape("scrape-secret"), "dhukuti_contributions_recorded_total")
+}
+
+func TestMetricsTokenRequiredInProduction(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("METRICS_TOKEN", "")
+	_, err := config.Load()
+	assert.ErrorContains(t, err, "METRICS_TOKEN is required")
+
+	t.Setenv("METRICS_TOKEN", "scrape-secret")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "scrape-secret", cfg.Metrics.Token)
+}
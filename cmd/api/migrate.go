@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const migrateUsage = `usage: api migrate <command>
+
+commands:
+  up        apply pending migrations
+  down [n]  roll back the last n migrations (default 1)
+  status    list migrations and when they were applied`
+
+// runMigrate runs the migrate command against the connected database
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", migrateUsage)
+	}
+
+	// Only the outcome is of interest, not every statement
+	db := database.DB.Session(&gorm.Session{Logger: database.DB.Logger.LogMode(logger.Warn)})
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(db)
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+		return err
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of migrations: %s", args[1])
+			}
+			steps = n
+		}
+		rolledBack, err := database.MigrateDown(db, steps)
+		for _, migration := range rolledBack {
+			fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(rolledBack) == 0 {
+			fmt.Println("no applied migrations")
+		}
+		return err
+
+	case "status":
+		states, err := database.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, state := range states {
+			applied := "pending"
+			if state.AppliedAt != nil {
+				applied = state.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			if state.Up == "" {
+				applied += " (no migration file)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", state.Version, state.Name, applied)
+		}
+		return w.Flush()
+	}
+
+	return fmt.Errorf("unknown command %q\n%s", args[0], migrateUsage)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+
+	// Bob is invited and accepted, then asked to vote on a new amount
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	expect(t, http.StatusOK, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": 200}))
+	s.dispatch()
+
+	w := s.do("GET", "/api/v1/notifications/unread-count", bob.Token, nil)
+	expect(t, http.StatusOK, w)
+	var count struct {
+		Total  int64            `json:"total"`
+		ByType map[string]int64 `json:"by_type"`
+	}
+	decode(t, w, &count)
+	assert.Equal(t, int64(3), count.Total)
+	assert.Equal(t, int64(2), count.ByType[models.NotificationCircleInvite])
+	assert.Equal(t, int64(1), count.ByType[models.NotificationVoteNeeded])
+
+	w = s.do("GET", "/api/v1/notifications", bob.Token, nil)
+	expect(t, http.StatusOK, w)
+	var list struct {
+		Notifications []struct {
+			ID   uint   `json:"id"`
+			Type string `json:"type"`
+		} `json:"notifications"`
+		UnreadCount int64 `json:"unread_count"`
+	}
+	decode(t, w, &list)
+	require.Len(t, list.Notifications, 3)
+	assert.Equal(t, int64(3), list.UnreadCount)
+
+	// Notifications are private
+	read := fmt.Sprintf("/api/v1/notifications/%d/read", list.Notifications[0].ID)
+	expect(t, http.StatusNotFound, s.do("POST", read, admin.Token, nil))
+	expect(t, http.StatusOK, s.do("POST", read, bob.Token, nil))
+
+	w = s.do("POST", "/api/v1/notifications/read-all", bob.Token, nil)
+	expect(t, http.StatusOK, w)
+	assert.JSONEq(t, `{"marked_read":2}`, w.Body.String())
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+
+	w := s.do("GET", "/api/v1/notifications/preferences", bob.Token, nil)
+	expect(t, http.StatusOK, w)
+	var prefs struct {
+		Preferences []struct {
+			Type  string `json:"type"`
+			InApp bool   `json:"in_app"`
+		} `json:"preferences"`
+	}
+	decode(t, w, &prefs)
+	require.Len(t, prefs.Preferences, len(models.NotificationTypes))
+	for _, pref := range prefs.Preferences {
+		assert.True(t, pref.InApp, pref.Type)
+	}
+
+	off := gin.H{"preferences": []gin.H{{"type": models.NotificationCircleInvite, "in_app": false}}}
+	for _, unknown := range []string{"gossip", "payout"} {
+		expect(t, http.StatusBadRequest, s.do("PUT", "/api/v1/notifications/preferences", bob.Token,
+			gin.H{"preferences": []gin.H{{"type": unknown, "in_app": false}}}))
+	}
+	expect(t, http.StatusOK, s.do("PUT", "/api/v1/notifications/preferences", bob.Token, off))
+
+	// Muted types are not delivered
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	s.dispatch()
+
+	var count struct {
+		Total int64 `json:"total"`
+	}
+	decode(t, s.do("GET", "/api/v1/notifications/unread-count", bob.Token, nil), &count)
+	assert.Zero(t, count.Total)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// onePixelPNG is the smallest valid PNG image
+var onePixelPNG, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==")
+
+func TestUpdateProfile(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	tests := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"name", gin.H{"name": "Ana Maria"}, http.StatusOK},
+		{"digest settings", gin.H{"digest_frequency": "weekly", "time_zone": "Asia/Kathmandu"}, http.StatusOK},
+		{"unknown digest frequency", gin.H{"digest_frequency": "hourly"}, http.StatusBadRequest},
+		{"unknown time zone", gin.H{"time_zone": "Mars/Olympus_Mons"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.status, s.do("PATCH", "/api/v1/me", user.Token, tt.body))
+		})
+	}
+
+	var me struct {
+		Name            string `json:"name"`
+		DigestFrequency string `json:"digest_frequency"`
+		TimeZone        string `json:"time_zone"`
+	}
+	decode(t, s.do("GET", "/api/v1/me", user.Token, nil), &me)
+	assert.Equal(t, "Ana Maria", me.Name)
+	assert.Equal(t, "weekly", me.DigestFrequency)
+	assert.Equal(t, "Asia/Kathmandu", me.TimeZone)
+}
+
+func TestChangePassword(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+	newPassword := "a-brand-new-passphrase"
+
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/me/password", user.Token,
+		gin.H{"current_password": "wrong-password", "new_password": newPassword}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/me/password", user.Token,
+		gin.H{"current_password": testPassword, "new_password": newPassword}))
+
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": newPassword}))
+}
+
+func TestChangeEmail(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+	s.register("taken@example.com")
+
+	expect(t, http.StatusConflict, s.do("POST", "/api/v1/me/email", user.Token,
+		gin.H{"new_email": "taken@example.com", "password": testPassword}))
+	expect(t, http.StatusAccepted, s.do("POST", "/api/v1/me/email", user.Token,
+		gin.H{"new_email": "ana.new@example.com", "password": testPassword}))
+
+	// The address only changes once the new one is confirmed
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/verify-email", "", gin.H{"token": s.mail.token(t, "ana.new@example.com")}))
+
+	var me struct {
+		Email string `json:"email"`
+	}
+	decode(t, s.do("GET", "/api/v1/me", user.Token, nil), &me)
+	assert.Equal(t, "ana.new@example.com", me.Email)
+}
+
+func TestAccountWithoutPassword(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+	other := s.register("bob@example.com")
+	// As if created by signing in with an identity provider
+	require.NoError(t, database.DB.Model(&models.User{}).Where("id IN ?", []uint{user.ID, other.ID}).
+		Update("password", models.NoPassword).Error)
+
+	expect(t, http.StatusAccepted, s.do("POST", "/api/v1/me/email", user.Token, gin.H{"new_email": "ana.new@example.com"}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/me/password", user.Token, gin.H{"new_password": testPassword}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": testPassword}))
+
+	// Once set, the password is required again
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/me/password", user.Token, gin.H{"new_password": "a-brand-new-passphrase"}))
+	expect(t, http.StatusUnauthorized, s.do("DELETE", "/api/v1/me", user.Token, gin.H{}))
+
+	expect(t, http.StatusOK, s.do("DELETE", "/api/v1/me", other.Token, gin.H{}))
+}
+
+func TestUploadAvatar(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	upload := func(data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("avatar", "avatar.png")
+		require.NoError(t, err)
+		part.Write(data)
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest("POST", "/api/v1/me/avatar", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+user.Token)
+		return s.serve(req)
+	}
+
+	expect(t, http.StatusUnsupportedMediaType, upload([]byte("not an image")))
+
+	w := upload(onePixelPNG)
+	expect(t, http.StatusOK, w)
+	var resp struct {
+		AvatarURL string `json:"avatar_url"`
+	}
+	decode(t, w, &resp)
+	require.NotEmpty(t, resp.AvatarURL)
+
+	// The file is served from the upload directory
+	w = s.do("GET", resp.AvatarURL, "", nil)
+	expect(t, http.StatusOK, w)
+	assert.Equal(t, onePixelPNG, w.Body.Bytes())
+	expect(t, http.StatusOK, s.do("HEAD", resp.AvatarURL, "", nil))
+}
+
+func TestPhoneVerification(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+	phone := "+9779841000000"
+
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/me/phone", user.Token, gin.H{"phone": "12"}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/me/phone", user.Token, gin.H{"phone": "+977 984-1000000"}))
+
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/me/phone/verify", user.Token, gin.H{"code": "000000"}))
+	expect(t, http.StatusOK, s.do("POST", "/api/v1/me/phone/verify", user.Token, gin.H{"code": s.texts.code(t, phone)}))
+
+	var me struct {
+		Phone string `json:"phone"`
+	}
+	decode(t, s.do("GET", "/api/v1/me", user.Token, nil), &me)
+	assert.Equal(t, phone, me.Phone)
+
+	expect(t, http.StatusOK, s.do("DELETE", "/api/v1/me/phone", user.Token, nil))
+	me.Phone = ""
+	decode(t, s.do("GET", "/api/v1/me", user.Token, nil), &me)
+	assert.Empty(t, me.Phone)
+}
+
+func TestPersonalAccessTokens(t *testing.T) {
+	s := newTestServer(t)
+	user := s.verifiedUser("ana@example.com")
+
+	expect(t, http.StatusBadRequest, s.do("POST", "/api/v1/me/tokens", user.Token, gin.H{"name": "ci", "scopes": []string{"everything"}}))
+
+	w := s.do("POST", "/api/v1/me/tokens", user.Token, gin.H{"name": "ci", "scopes": []string{"circles:read"}})
+	expect(t, http.StatusCreated, w)
+	var created struct {
+		ID    uint   `json:"id"`
+		Token string `json:"token"`
+	}
+	decode(t, w, &created)
+
+	// Tokens reach the scoped routes only
+	expect(t, http.StatusOK, s.do("GET", "/api/v1/circles", created.Token, nil))
+	expect(t, http.StatusForbidden, s.do("POST", "/api/v1/circles/1/approve-amount", created.Token, nil))
+	expect(t, http.StatusForbidden, s.do("GET", "/api/v1/me", created.Token, nil))
+
+	w = s.do("GET", "/api/v1/me/tokens", user.Token, nil)
+	expect(t, http.StatusOK, w)
+	var tokens []struct {
+		Name string `json:"name"`
+	}
+	decode(t, w, &tokens)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "ci", tokens[0].Name)
+
+	expect(t, http.StatusOK, s.do("DELETE", fmt.Sprintf("/api/v1/me/tokens/%d", created.ID), user.Token, nil))
+	expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/circles", created.Token, nil))
+}
+
+func TestExportData(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	w := s.do("GET", "/api/v1/me/export?format=json", user.Token, nil)
+	expect(t, http.StatusOK, w)
+	var export struct {
+		Profile struct {
+			Email string `json:"email"`
+		} `json:"profile"`
+	}
+	decode(t, w, &export)
+	assert.Equal(t, user.Email, export.Profile.Email)
+
+	w = s.do("GET", "/api/v1/me/export", user.Token, nil)
+	expect(t, http.StatusOK, w)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	_, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	s := newTestServer(t)
+	user := s.register("ana@example.com")
+
+	w := s.do("POST", "/api/v1/stream/ticket", user.Token, nil)
+	expect(t, http.StatusCreated, w)
+	var ticket struct {
+		Ticket string `json:"ticket"`
+	}
+	decode(t, w, &ticket)
+
+	expect(t, http.StatusUnauthorized, s.do("DELETE", "/api/v1/me", user.Token, gin.H{"password": "wrong-password"}))
+	expect(t, http.StatusOK, s.do("DELETE", "/api/v1/me", user.Token, gin.H{"password": testPassword}))
+
+	// The session outlives the account but is refused everywhere, including
+	// routes that never load the user
+	expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/me", user.Token, nil))
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/webhooks", user.Token, gin.H{"url": "https://example.com/hook"}))
+	expect(t, http.StatusUnauthorized, s.do("PUT", "/api/v1/notifications/preferences", user.Token, gin.H{}))
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/stream/ticket", user.Token, nil))
+	expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/stream?ticket="+ticket.Ticket, "", nil))
+	expect(t, http.StatusUnauthorized, s.do("POST", "/api/v1/auth/login", "", gin.H{"email": user.Email, "password": testPassword}))
+}
+
+func TestDeleteAccountWithUnsettledBalance(t *testing.T) {
+	s := newTestServer(t)
+	user := s.verifiedUser("ana@example.com")
+	path := s.createCircle(user, 100)
+	// Joined mid-month two months ago; payments are due on the 1st
+	joined := time.Now().UTC().AddDate(0, -2, 0)
+	joined = time.Date(joined.Year(), joined.Month(), 15, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, database.DB.Model(&models.CircleMember{}).Where("user_id = ?", user.ID).Update("created_at", joined).Error)
+
+	w := s.do("DELETE", "/api/v1/me", user.Token, gin.H{"password": testPassword})
+	expect(t, http.StatusConflict, w)
+	var resp struct {
+		Details struct {
+			Balances []struct {
+				Paid        int `json:"paid"`
+				Outstanding int `json:"outstanding"`
+			} `json:"balances"`
+		} `json:"details"`
+	}
+	decode(t, w, &resp)
+	require.Len(t, resp.Details.Balances, 1)
+	assert.Zero(t, resp.Details.Balances[0].Paid)
+	assert.GreaterOrEqual(t, resp.Details.Balances[0].Outstanding, 2)
+
+	for i := 0; i < 3; i++ {
+		expect(t, http.StatusCreated, s.do("POST", path+"/contributions", user.Token, gin.H{}))
+	}
+	// Raising the amount doesn't make contributions paid before unsettled
+	require.NoError(t, database.DB.Model(&models.Circle{}).Where("creator_id = ?", user.ID).Update("amount_per_member", 300).Error)
+
+	expect(t, http.StatusOK, s.do("DELETE", "/api/v1/me", user.Token, gin.H{"password": testPassword}))
+}
+
+func TestDeleteAccountInCircles(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+
+	t.Run("the only admin can't leave their members behind", func(t *testing.T) {
+		w := s.do("DELETE", "/api/v1/me", admin.Token, gin.H{"password": testPassword})
+		expect(t, http.StatusConflict, w)
+		assert.Contains(t, w.Body.String(), `"circle_ids":[1]`)
+	})
+
+	t.Run("a proposed amount waiting only on the user is applied", func(t *testing.T) {
+		expect(t, http.StatusOK, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": 200}))
+		expect(t, http.StatusOK, s.do("DELETE", "/api/v1/me", bob.Token, gin.H{"password": testPassword}))
+
+		circle := s.getCircle(path, admin)
+		assert.Equal(t, uint(200), circle.AmountPerMember)
+		assert.Zero(t, circle.ProposedAmount)
+		assert.False(t, circle.NeedsAmountApproval)
+	})
+}
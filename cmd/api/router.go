@@ -0,0 +1,212 @@
+package main
+
+import (
+	"log/slog"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/handlers"
+	"github.com/Sudan23/dhukuti/internal/health"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/metrics"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/oidc"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/Sudan23/dhukuti/internal/repository"
+	"github.com/Sudan23/dhukuti/internal/service"
+	"github.com/Sudan23/dhukuti/internal/sms"
+	"github.com/Sudan23/dhukuti/internal/stream"
+	"github.com/Sudan23/dhukuti/internal/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// newRouter creates the handlers and registers every route of the API
+func newRouter(cfg *config.Config, mail mailer.Mailer, texts sms.Gateway, limiter *ratelimit.Limiter, passwordPolicy *password.Policy, publisher events.Publisher, hub *stream.Hub, checker *health.Checker) (*gin.Engine, error) {
+	// Domain services work on the database through the repository
+	repo := repository.NewGorm(database.DB, publisher)
+	circleService := service.NewCircleService(repo)
+	membershipService := service.NewMembershipService(repo)
+	contributionService := service.NewContributionService(repo)
+	authService := service.NewAuthService(repo)
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(cfg, mail, limiter, passwordPolicy, authService)
+	emailVerificationHandler := handlers.NewEmailVerificationHandler(cfg, mail)
+	twoFactorHandler := handlers.NewTwoFactorHandler(cfg, limiter)
+	passwordResetHandler := handlers.NewPasswordResetHandler(cfg, mail, limiter, passwordPolicy)
+	profileHandler := handlers.NewProfileHandler(cfg, mail, passwordPolicy, publisher)
+	phoneHandler := handlers.NewPhoneHandler(cfg, texts)
+	oidcHandler := handlers.NewOIDCHandler(cfg, oidc.NewProviders(cfg))
+	tokenHandler := handlers.NewPersonalAccessTokenHandler()
+	circleHandler := handlers.NewCircleHandler(circleService, membershipService, contributionService)
+	webhookHandler := handlers.NewWebhookHandler(cfg)
+	notificationHandler := handlers.NewNotificationHandler()
+	streamHandler := handlers.NewStreamHandler(cfg, hub)
+	healthHandler := handlers.NewHealthHandler(checker)
+
+	// Retries of requests that move money or change a circle get the first response
+	idempotent := middleware.Idempotency(cfg.Idempotency.TTL)
+
+	// Setup router; requests are traced, logged through slog with their
+	// request ID and counted per route in the metrics
+	router := gin.New()
+	// Without this Gin believes any X-Forwarded-For, letting clients pick the
+	// IP their rate limits are counted against
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
+	apiMetrics := metrics.New(database.DB)
+	router.Use(
+		tracing.Middleware(),
+		middleware.RequestLogger(slog.Default()),
+		apiMetrics.Middleware(),
+		middleware.Recovery(),
+	)
+
+	// CORS Middleware
+	router.Use(func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Last-Event-ID, Idempotency-Key, X-Request-ID, traceparent, tracestate, baggage")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Idempotent-Replayed, Retry-After")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+
+		c.Next()
+	})
+
+	// Probes: /livez fails when the instance needs a restart, /readyz when it
+	// can't serve requests. /health is kept for existing monitors.
+	router.GET("/livez", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(200, gin.H{
+			"status":  "ok",
+			"service": "dhukuti-api",
+		})
+	})
+
+	// Prometheus metrics
+	router.GET("/metrics", apiMetrics.Handler(cfg.Metrics.Token))
+
+	// User uploaded files (avatars)
+	router.Static("/uploads", cfg.Storage.UploadDir)
+
+	// API v1 routes
+	v1 := router.Group("/api/v1")
+	{
+		// Auth routes (public)
+		auth := v1.Group("/auth")
+		auth.Use(middleware.RateLimit(limiter))
+		{
+			auth.POST("/register", authHandler.Register)
+			auth.POST("/login", authHandler.Login)
+			auth.POST("/unlock", authHandler.UnlockAccount)
+			auth.POST("/verify-email", emailVerificationHandler.VerifyEmail)
+			auth.POST("/2fa/verify", twoFactorHandler.VerifyLogin)
+			auth.POST("/forgot-password", passwordResetHandler.RequestPasswordReset)
+			auth.POST("/reset-password", passwordResetHandler.ResetPassword)
+
+			// External identity providers
+			auth.GET("/oidc/providers", oidcHandler.ListProviders)
+			auth.GET("/oidc/:provider/login", oidcHandler.Login)
+			auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
+		}
+
+		// Protected routes, for logged in users only
+		protected := v1.Group("")
+		protected.Use(middleware.AuthMiddleware(cfg), middleware.RequireSession())
+		{
+			protected.POST("/auth/resend-verification", emailVerificationHandler.ResendVerification)
+
+			// Profile routes
+			me := protected.Group("/me")
+			{
+				me.GET("", profileHandler.GetMe)
+				me.PATCH("", profileHandler.UpdateMe)
+				me.DELETE("", profileHandler.DeleteAccount)
+				me.GET("/export", profileHandler.ExportData)
+				me.POST("/password", profileHandler.ChangePassword)
+				me.POST("/email", profileHandler.ChangeEmail)
+				me.POST("/avatar", profileHandler.UploadAvatar)
+				me.POST("/phone", phoneHandler.AddPhone)
+				me.POST("/phone/verify", phoneHandler.VerifyPhone)
+				me.DELETE("/phone", phoneHandler.RemovePhone)
+				me.GET("/tokens", tokenHandler.ListTokens)
+				me.POST("/tokens", tokenHandler.CreateToken)
+				me.DELETE("/tokens/:token_id", tokenHandler.RevokeToken)
+			}
+
+			// Two-factor authentication routes
+			twoFactor := protected.Group("/auth/2fa")
+			{
+				twoFactor.POST("/setup", twoFactorHandler.Setup)
+				twoFactor.POST("/enable", twoFactorHandler.Enable)
+				twoFactor.POST("/disable", twoFactorHandler.Disable)
+				twoFactor.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+			}
+
+			// Webhook routes
+			webhooks := protected.Group("/webhooks")
+			{
+				webhooks.GET("", webhookHandler.ListWebhooks)
+				webhooks.POST("", webhookHandler.CreateWebhook)
+				webhooks.DELETE("/:webhook_id", webhookHandler.DeleteWebhook)
+				webhooks.GET("/:webhook_id/deliveries", webhookHandler.ListDeliveries)
+				webhooks.POST("/:webhook_id/deliveries/:delivery_id/redeliver", webhookHandler.RedeliverDelivery)
+			}
+
+			// Notification centre routes
+			notifications := protected.Group("/notifications")
+			{
+				notifications.GET("", notificationHandler.ListNotifications)
+				notifications.GET("/unread-count", notificationHandler.UnreadCount)
+				notifications.POST("/read-all", notificationHandler.MarkAllRead)
+				notifications.POST("/:notification_id/read", notificationHandler.MarkRead)
+				notifications.GET("/preferences", notificationHandler.GetPreferences)
+				notifications.PUT("/preferences", notificationHandler.UpdatePreferences)
+			}
+
+			// Real-time event stream ticket
+			protected.POST("/stream/ticket", streamHandler.CreateTicket)
+
+			// Circle routes
+			circles := protected.Group("/circles")
+			circles.Use(middleware.RequireCircleTwoFactor())
+			{
+				circles.POST("", middleware.RequireVerifiedEmail(), idempotent, circleHandler.CreateCircle)
+				circles.POST("/:id/members", circleHandler.AddMember)
+				circles.POST("/:id/propose-amount", idempotent, circleHandler.ProposeAmount)
+				circles.PUT("/:id/security", circleHandler.UpdateSecurity)
+				circles.PUT("/:id/reminders", circleHandler.UpdateReminders)
+			}
+		}
+
+		// Real-time event stream (Server-Sent Events)
+		v1.GET("/stream", middleware.StreamAuth(cfg), middleware.RequireSession(), streamHandler.Stream)
+
+		// Routes that also accept personal access tokens with the right scope
+		scoped := v1.Group("")
+		scoped.Use(middleware.AuthMiddleware(cfg))
+		{
+			circles := scoped.Group("/circles")
+			circles.Use(middleware.RequireCircleTwoFactor())
+			{
+				circles.GET("", middleware.RequireScope(models.ScopeCirclesRead), circleHandler.ListCircles)
+				circles.GET("/:id", middleware.RequireScope(models.ScopeCirclesRead), circleHandler.GetCircle)
+				circles.POST("/:id/contributions", middleware.RequireScope(models.ScopeContributionsWrite), middleware.RequireVerifiedEmail(), idempotent, circleHandler.RecordContribution)
+				circles.POST("/:id/approve/:user_id", middleware.RequireScope(models.ScopeVotesWrite), circleHandler.ApproveMember)
+				circles.POST("/:id/approve-amount", middleware.RequireScope(models.ScopeVotesWrite), circleHandler.ApproveAmountChange)
+			}
+		}
+	}
+
+	return router, nil
+}
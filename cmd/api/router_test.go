@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/database/databasetest"
+	"github.com/Sudan23/dhukuti/internal/health"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/notification"
+	"github.com/Sudan23/dhukuti/internal/outbox"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/Sudan23/dhukuti/internal/sms"
+	"github.com/Sudan23/dhukuti/internal/stream"
+	"github.com/Sudan23/dhukuti/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+// The suite runs every route against a fresh database per test: in-memory
+// SQLite, or Postgres with DB_DRIVER=postgres (see databasetest). TestMain
+// fails the run if a registered route was never requested.
+
+const testPassword = "correct-horse-battery-staple"
+
+var (
+	coverageMu sync.Mutex
+	routes     gin.RoutesInfo
+	covered    = make(map[string]bool)
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	log.SetOutput(io.Discard)
+
+	// Cheap hashing keeps the suite fast
+	password.Configure(password.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+
+	code := m.Run()
+	if code == 0 && flag.Lookup("test.run").Value.String() == "" {
+		if missing := uncoveredRoutes(); len(missing) > 0 {
+			fmt.Printf("routes without tests:\n  %s\n", strings.Join(missing, "\n  "))
+			code = 1
+		}
+	}
+	os.Exit(code)
+}
+
+// recordRoute marks the route serving method and path as tested
+func recordRoute(method, path string) {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	for _, route := range routes {
+		if route.Method == method && matchRoute(route.Path, path) {
+			covered[route.Method+" "+route.Path] = true
+		}
+	}
+}
+
+// matchRoute reports whether path matches a gin route pattern
+func matchRoute(pattern, path string) bool {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(pathParts) || (!strings.HasPrefix(part, ":") && part != pathParts[i]) {
+			return false
+		}
+	}
+	return len(patternParts) == len(pathParts)
+}
+
+func uncoveredRoutes() []string {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	var missing []string
+	for _, route := range routes {
+		if key := route.Method + " " + route.Path; !covered[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// mailbox is a mailer that keeps the messages it sends
+type mailbox struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *mailbox) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+var tokenLink = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// token returns the token in the last link emailed to an address
+func (m *mailbox) token(t *testing.T, to string) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			match := tokenLink.FindStringSubmatch(m.messages[i].Body)
+			require.NotNil(t, match, "no link in email to %s", to)
+			return match[1]
+		}
+	}
+	t.Fatalf("no email sent to %s", to)
+	return ""
+}
+
+// textbox is an SMS gateway that keeps the messages it sends
+type textbox struct {
+	mu       sync.Mutex
+	messages []sms.Message
+}
+
+func (b *textbox) Send(msg sms.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.messages = append(b.messages, msg)
+	return nil
+}
+
+var smsCode = regexp.MustCompile(`\b(\d{6})\b`)
+
+// code returns the verification code last texted to a number
+func (b *textbox) code(t *testing.T, to string) string {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := len(b.messages) - 1; i >= 0; i-- {
+		if b.messages[i].To == to {
+			match := smsCode.FindStringSubmatch(b.messages[i].Body)
+			require.NotNil(t, match, "no code in text to %s", to)
+			return match[1]
+		}
+	}
+	t.Fatalf("no text sent to %s", to)
+	return ""
+}
+
+// testServer is the API with its own database
+type testServer struct {
+	t          *testing.T
+	cfg        *config.Config
+	router     *gin.Engine
+	mail       *mailbox
+	texts      *textbox
+	dispatcher *outbox.Dispatcher
+	checker    *health.Checker
+	hub        *stream.Hub
+}
+
+// newTestServer starts the API on an empty database of its own, SQLite or
+// Postgres depending on DB_DRIVER. configure may change the configuration
+// before the routes are set up.
+func newTestServer(t *testing.T, configure ...func(cfg *config.Config)) *testServer {
+	t.Helper()
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	cfg.Database = databasetest.Config(t)
+	cfg.Storage.UploadDir = t.TempDir()
+	cfg.RateLimit.Store = "memory"
+	cfg.Password.BreachedListPath = ""
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	require.NoError(t, database.Connect(cfg))
+	require.NoError(t, database.Migrate())
+	database.DB.Logger = logger.Discard
+	sqlDB, err := database.DB.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	limiter, err := ratelimit.New(cfg, database.DB)
+	require.NoError(t, err)
+	policy, err := password.NewPolicy(cfg.Password.MinLength, cfg.Password.MaxLength, "")
+	require.NoError(t, err)
+
+	s := &testServer{t: t, cfg: cfg, mail: &mailbox{}, texts: &textbox{}, checker: health.New(database.DB)}
+	s.dispatcher = outbox.NewDispatcher(database.DB, cfg.Outbox,
+		webhook.NewSink(database.DB),
+		notification.NewSink(database.DB),
+		stream.NewSink(database.DB),
+		sms.NewSink(database.DB, s.texts, cfg.App.FrontendURL),
+	)
+	s.hub = stream.NewHub(database.DB, cfg.GetDSN(), cfg.Stream)
+	s.router, err = newRouter(cfg, s.mail, s.texts, limiter, policy, outbox.NewPublisher(), s.hub, s.checker)
+	require.NoError(t, err)
+
+	coverageMu.Lock()
+	routes = s.router.Routes()
+	coverageMu.Unlock()
+	return s
+}
+
+// serve handles a request and records the route it exercised
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	recordRoute(req.Method, req.URL.Path)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// do sends a request with an optional JSON body and bearer token
+func (s *testServer) do(method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		require.NoError(s.t, err)
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.serve(req)
+}
+
+// dispatch publishes all pending circle events to the sinks
+func (s *testServer) dispatch() {
+	s.t.Helper()
+	for {
+		processed, err := s.dispatcher.DispatchOnce(context.Background())
+		require.NoError(s.t, err)
+		if processed == 0 {
+			return
+		}
+	}
+}
+
+// decode parses a JSON response into v
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v), w.Body.String())
+}
+
+// expect checks the status of a response, showing the body on failure
+func expect(t *testing.T, status int, w *httptest.ResponseRecorder) {
+	t.Helper()
+	require.Equal(t, status, w.Code, w.Body.String())
+}
+
+// testUser is a registered user and their session token
+type testUser struct {
+	ID    uint
+	Email string
+	Token string
+}
+
+// register signs up a user through the API
+func (s *testServer) register(email string) testUser {
+	s.t.Helper()
+	w := s.do("POST", "/api/v1/auth/register", "", gin.H{"email": email, "password": testPassword, "name": strings.Split(email, "@")[0]})
+	expect(s.t, http.StatusCreated, w)
+
+	var resp struct {
+		Token string
+		User  struct{ ID uint }
+	}
+	decode(s.t, w, &resp)
+	return testUser{ID: resp.User.ID, Email: email, Token: resp.Token}
+}
+
+// verifiedUser signs up a user and verifies their email address directly
+func (s *testServer) verifiedUser(email string) testUser {
+	s.t.Helper()
+	user := s.register(email)
+	require.NoError(s.t, database.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		Update("email_verified_at", time.Now()).Error)
+	return user
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do("GET", "/health", "", nil)
+	expect(t, http.StatusOK, w)
+	assert.JSONEq(t, `{"status":"ok","service":"dhukuti-api"}`, w.Body.String())
+}
+
+func TestCORSPreflight(t *testing.T) {
+	s := newTestServer(t)
+
+	w := s.do("OPTIONS", "/api/v1/circles", "", nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestProtectedRoutesRequireAuth(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, path := range []string{"/api/v1/me", "/api/v1/circles", "/api/v1/notifications", "/api/v1/stream"} {
+		assert.Equal(t, http.StatusUnauthorized, s.do("GET", path, "", nil).Code, path)
+		assert.Equal(t, http.StatusUnauthorized, s.do("GET", path, "not-a-token", nil).Code, path)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	s.dispatch()
+
+	w := s.do("POST", "/api/v1/stream/ticket", admin.Token, nil)
+	expect(t, http.StatusCreated, w)
+	var ticket struct {
+		Ticket    string `json:"ticket"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	decode(t, w, &ticket)
+	assert.Positive(t, ticket.ExpiresIn)
+
+	expect(t, http.StatusUnauthorized, s.do("GET", "/api/v1/stream?ticket=bogus", "", nil))
+	expect(t, http.StatusBadRequest, s.do("GET", "/api/v1/stream?ticket="+ticket.Ticket+"&last_event_id=abc", "", nil))
+
+	// The stream stays open until the client goes away
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "/api/v1/stream?ticket="+ticket.Ticket+"&last_event_id=0", nil).WithContext(ctx)
+	w = s.serve(req)
+	expect(t, http.StatusOK, w)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.Contains(t, body, "retry: 3000")
+	assert.Contains(t, body, "event: member_added")
+	assert.Contains(t, body, "event: member_approved")
+}
+
+func TestStreamHub(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	path := s.createCircle(admin, 100)
+
+	// Postgres notifies the hub of new events; SQLite is polled
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		s.hub.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+	sub := s.hub.Subscribe(admin.ID)
+	defer s.hub.Unsubscribe(sub)
+
+	// The hub starts from the end of the stream, so keep making changes
+	// until one arrives
+	amount := 100
+	assert.Eventually(t, func() bool {
+		amount++
+		expect(t, http.StatusOK, s.do("POST", path+"/propose-amount", admin.Token, gin.H{"new_amount": amount}))
+		s.dispatch()
+		select {
+		case event := <-sub.C:
+			return event.EventType == "amount_proposed"
+		case <-time.After(200 * time.Millisecond):
+			return false
+		}
+	}, 10*time.Second, 10*time.Millisecond)
+}
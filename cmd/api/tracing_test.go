@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/database/databasetest"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// recordSpans keeps the spans ended during the rest of the test in memory
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+// spanAttribute returns the value of a span's attribute
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := recordSpans(t)
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	path := s.createCircle(admin, 100)
+
+	t.Run("requests continue the caller's trace", func(t *testing.T) {
+		exporter.Reset()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+admin.Token)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		expect(t, http.StatusOK, s.serve(req))
+
+		var server tracetest.SpanStub
+		var queries []tracetest.SpanStub
+		for _, span := range exporter.GetSpans() {
+			switch span.SpanKind {
+			case trace.SpanKindServer:
+				server = span
+			case trace.SpanKindClient:
+				queries = append(queries, span)
+			}
+		}
+
+		require.Equal(t, "GET /api/v1/circles/:id", server.Name)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+		assert.Equal(t, int64(http.StatusOK), spanAttribute(server, "http.response.status_code").AsInt64())
+		assert.Equal(t, "/api/v1/circles/:id", spanAttribute(server, "http.route").AsString())
+
+		system := "sqlite"
+		if databasetest.Postgres() {
+			system = "postgresql"
+		}
+		require.NotEmpty(t, queries, "every query has a span")
+		for _, query := range queries {
+			assert.Equal(t, server.SpanContext.SpanID(), query.Parent.SpanID(), query.Name)
+			assert.Equal(t, system, spanAttribute(query, "db.system").AsString())
+			assert.NotEmpty(t, spanAttribute(query, "db.query.text").AsString())
+		}
+		var names []string
+		for _, query := range queries {
+			names = append(names, query.Name)
+		}
+		assert.Contains(t, names, "SELECT circles")
+	})
+
+	t.Run("queries don't carry their values", func(t *testing.T) {
+		exporter.Reset()
+		s.register("private@example.com")
+
+		for _, span := range exporter.GetSpans() {
+			assert.NotContains(t, spanAttribute(span, "db.query.text").AsString(), "private@example.com", span.Name)
+		}
+	})
+
+	t.Run("query errors don't carry their values", func(t *testing.T) {
+		conflict := func(tx *gorm.DB) {
+			if tx.Statement.Table == "users" {
+				tx.AddError(errors.New(`duplicate key value violates unique constraint "idx_users_email": Key (email)=(clash@example.com) already exists.`))
+			}
+		}
+		require.NoError(t, database.DB.Callback().Create().Before("gorm:create").Register("test:conflict", conflict))
+		exporter.Reset()
+		s.do("POST", "/api/v1/auth/register", "", gin.H{"email": "clash@example.com", "password": testPassword, "name": "clash"})
+		require.NoError(t, database.DB.Callback().Create().Remove("test:conflict"))
+
+		var failed []tracetest.SpanStub
+		for _, span := range exporter.GetSpans() {
+			if span.Name == "INSERT users" {
+				failed = append(failed, span)
+			}
+		}
+		require.NotEmpty(t, failed)
+		for _, span := range failed {
+			assert.Equal(t, codes.Error, span.Status.Code)
+			assert.Contains(t, span.Status.Description, "Key (email)=([REDACTED])")
+			for _, event := range span.Events {
+				for _, kv := range event.Attributes {
+					assert.NotContains(t, kv.Value.Emit(), "clash@example.com")
+				}
+			}
+		}
+	})
+
+	t.Run("requests without a trace start one", func(t *testing.T) {
+		exporter.Reset()
+		expect(t, http.StatusOK, s.do("GET", "/api/v1/circles", admin.Token, nil))
+
+		spans := exporter.GetSpans()
+		require.NotEmpty(t, spans)
+		traceID := spans[0].SpanContext.TraceID()
+		for _, span := range spans {
+			assert.Equal(t, traceID, span.SpanContext.TraceID(), "one trace per request")
+			if span.SpanKind == trace.SpanKindServer {
+				assert.False(t, span.Parent.IsValid())
+				assert.True(t, strings.HasPrefix(span.Name, "GET "))
+			}
+		}
+	})
+}
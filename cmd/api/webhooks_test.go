@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhooks(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.verifiedUser("admin@example.com")
+	bob := s.verifiedUser("bob@example.com")
+	path := s.createCircle(admin, 100)
+	circleID, err := strconv.Atoi(strings.TrimPrefix(path, "/api/v1/circles/"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		body   gin.H
+		status int
+	}{
+		{"plain http", gin.H{"url": "http://hooks.example.com"}, http.StatusBadRequest},
+		{"unknown event", gin.H{"url": "https://hooks.example.com", "events": []string{"circle.exploded"}}, http.StatusBadRequest},
+		{"someone else's circle", gin.H{"url": "https://hooks.example.com", "circle_id": 999}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, tt.status, s.do("POST", "/api/v1/webhooks", admin.Token, tt.body))
+		})
+	}
+
+	w := s.do("POST", "/api/v1/webhooks", admin.Token, gin.H{"url": "https://hooks.example.com/dhukuti", "circle_id": circleID})
+	expect(t, http.StatusCreated, w)
+	var created struct {
+		ID     uint   `json:"id"`
+		Secret string `json:"secret"`
+	}
+	decode(t, w, &created)
+	assert.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+
+	w = s.do("GET", "/api/v1/webhooks", admin.Token, nil)
+	expect(t, http.StatusOK, w)
+	assert.NotContains(t, w.Body.String(), created.Secret, "the secret is only shown once")
+	var webhooks []struct {
+		ID uint `json:"id"`
+	}
+	decode(t, w, &webhooks)
+	require.Len(t, webhooks, 1)
+
+	// Circle activity queues a delivery
+	expect(t, http.StatusCreated, s.do("POST", path+"/members", admin.Token, gin.H{"user_id": bob.ID}))
+	s.dispatch()
+
+	webhook := fmt.Sprintf("/api/v1/webhooks/%d", created.ID)
+	expect(t, http.StatusNotFound, s.do("GET", webhook+"/deliveries", bob.Token, nil))
+	w = s.do("GET", webhook+"/deliveries", admin.Token, nil)
+	expect(t, http.StatusOK, w)
+	var deliveries []struct {
+		ID        uint   `json:"id"`
+		EventType string `json:"event_type"`
+		Status    string `json:"status"`
+	}
+	decode(t, w, &deliveries)
+	require.NotEmpty(t, deliveries)
+	assert.Equal(t, "pending", deliveries[0].Status)
+
+	expect(t, http.StatusNotFound, s.do("POST", webhook+"/deliveries/999/redeliver", admin.Token, nil))
+	expect(t, http.StatusAccepted, s.do("POST", fmt.Sprintf("%s/deliveries/%d/redeliver", webhook, deliveries[0].ID), admin.Token, nil))
+
+	expect(t, http.StatusNotFound, s.do("DELETE", webhook, bob.Token, nil))
+	expect(t, http.StatusBadRequest, s.do("DELETE", "/api/v1/webhooks/abc", admin.Token, nil))
+	expect(t, http.StatusOK, s.do("DELETE", webhook, admin.Token, nil))
+	expect(t, http.StatusNotFound, s.do("GET", webhook+"/deliveries", admin.Token, nil))
+}
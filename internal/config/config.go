@@ -2,44 +2,201 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
 // Config holds all application configuration
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	App      AppConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	JWT         JWTConfig
+	App         AppConfig
+	Mail        MailConfig
+	SMS         SMSConfig
+	RateLimit   RateLimitConfig
+	Storage     StorageConfig
+	OIDC        OIDCConfig
+	Password    PasswordConfig
+	Webhook     WebhookConfig
+	Outbox      OutboxConfig
+	Stream      StreamConfig
+	Reminder    ReminderConfig
+	Digest      DigestConfig
+	Idempotency IdempotencyConfig
+	Log         LogConfig
+	Metrics     MetricsConfig
+	Tracing     TracingConfig
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
-	Port    string
-	GinMode string
+	Port            string
+	GinMode         string
+	PublicURL       string        // externally reachable base URL of the API, used for OAuth redirects
+	ShutdownDelay   time.Duration // time readiness fails before the server stops taking connections, for load balancers to notice
+	ShutdownTimeout time.Duration // time allowed to drain requests and stop the workers on SIGINT or SIGTERM
+	TrustedProxies  []string      // IPs and CIDRs of proxies whose X-Forwarded-For is believed; none by default
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
+	Driver   string // postgres or sqlite
+	Path     string // SQLite database file, or :memory:
 	Host     string
 	Port     string
 	User     string
 	Password string
 	DBName   string
 	SSLMode  string
+	Schema   string // Postgres schema to use instead of the user's default search path
+	Migrate  bool   // apply pending migrations on startup
 }
 
 // JWTConfig holds JWT configuration
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret          string
+	Expiry          time.Duration
+	ChallengeExpiry time.Duration // lifetime of the two-factor login challenge
 }
 
 // AppConfig holds application configuration
 type AppConfig struct {
+	Name        string
 	Environment string
+	FrontendURL string
+}
+
+// MailConfig holds outgoing email configuration
+type MailConfig struct {
+	SMTPHost                string
+	SMTPPort                string
+	SMTPUser                string
+	SMTPPassword            string
+	From                    string
+	VerificationTokenExpiry time.Duration
+	VerificationResendDelay time.Duration
+}
+
+// SMSConfig holds outgoing text message configuration
+type SMSConfig struct {
+	GatewayURL      string // HTTP endpoint of the SMS provider; messages are only logged when empty
+	APIKey          string
+	From            string        // sender ID or number
+	CodeExpiry      time.Duration // lifetime of phone verification codes
+	CodeResendDelay time.Duration
+	CodeMaxAttempts int // wrong codes allowed before a new one must be requested
+}
+
+// RateLimitConfig holds request throttling and account lockout configuration
+type RateLimitConfig struct {
+	Store                 string // memory or postgres
+	IPRequests            int    // requests allowed per IP and route within IPWindow
+	IPWindow              time.Duration
+	FreeFailures          int // failed logins allowed before backoff starts
+	BackoffBase           time.Duration
+	BackoffMax            time.Duration
+	FailureWindow         time.Duration // how long failed attempts are remembered
+	LockoutThreshold      int           // failed logins on one account before it is locked
+	LockoutDuration       time.Duration
+	PasswordResetRequests int // reset emails allowed per account within PasswordResetWindow
+	PasswordResetWindow   time.Duration
+}
+
+// StorageConfig holds configuration for user uploaded files
+type StorageConfig struct {
+	UploadDir     string // local directory served under /uploads
+	MaxAvatarSize int64  // bytes
+}
+
+// OIDCConfig holds the configured OpenID Connect login providers
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig
+}
+
+// OIDCProviderConfig holds the settings for a single OpenID Connect provider
+type OIDCProviderConfig struct {
+	Name         string // used in URLs, e.g. /auth/oidc/google/login
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// PasswordConfig holds the password policy and hashing cost
+type PasswordConfig struct {
+	MinLength         int
+	MaxLength         int
+	BreachedListPath  string // optional file of breached passwords, one per line
+	Argon2Memory      int    // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+}
+
+// WebhookConfig holds outbound webhook delivery configuration
+type WebhookConfig struct {
+	MaxAttempts          int
+	BackoffBase          time.Duration // delay before the first retry, doubled for each further retry
+	Timeout              time.Duration // per request
+	PollInterval         time.Duration
+	AllowPrivateNetworks bool // allow delivery to loopback and private addresses, for local development
+}
+
+// OutboxConfig holds configuration for publishing events from the outbox
+type OutboxConfig struct {
+	MaxAttempts  int           // attempts before a message is dead-lettered
+	BackoffBase  time.Duration // delay before the first retry, doubled for each further retry
+	PollInterval time.Duration
+	Retention    time.Duration // how long published messages are kept
+}
+
+// StreamConfig holds configuration for the real-time event stream
+type StreamConfig struct {
+	Retention    time.Duration // how long events can be replayed after a reconnect
+	Heartbeat    time.Duration // interval of keep-alive comments on idle streams
+	TicketExpiry time.Duration // lifetime of the tickets browsers use to open a stream
+}
+
+// ReminderConfig holds configuration for the payment reminder scheduler
+type ReminderConfig struct {
+	Enabled  bool
+	Interval time.Duration // how often reminders are computed
+	Channels []string      // channels reminders are sent through, e.g. in_app and email
+}
+
+// DigestConfig holds configuration for the email digest
+type DigestConfig struct {
+	Enabled  bool
+	Interval time.Duration // how often due digests are looked for
+	Hour     int           // local hour of the day digests are sent at
+	Weekday  time.Weekday  // day weekly digests are sent on
+}
+
+// IdempotencyConfig holds configuration for Idempotency-Key handling
+type IdempotencyConfig struct {
+	TTL time.Duration // how long a key's response is replayed to retries
+}
+
+// LogConfig holds logging configuration
+type LogConfig struct {
+	Level  string // debug, info, warn or error
+	Format string // json, or text for reading logs locally
+}
+
+// MetricsConfig holds configuration for the Prometheus metrics endpoint
+type MetricsConfig struct {
+	Token string // bearer token scrapers must send; the endpoint is open when empty, outside production
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration
+type TracingConfig struct {
+	Exporter     string  // none, otlp or stdout
+	OTLPEndpoint string  // OTLP/HTTP traces URL; the standard OTEL_EXPORTER_OTLP_* variables apply when empty
+	ServiceName  string  // service.name reported with every span
+	SampleRatio  float64 // share of new traces recorded, 0-1; callers' sampling decisions are followed
 }
 
 // Load loads configuration from environment variables
@@ -49,34 +206,480 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid JWT_EXPIRY_HOURS: %w", err)
 	}
 
+	challengeExpiryMinutes, err := strconv.Atoi(getEnv("JWT_CHALLENGE_EXPIRY_MINUTES", "5"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT_CHALLENGE_EXPIRY_MINUTES: %w", err)
+	}
+
+	verificationExpiryHours, err := strconv.Atoi(getEnv("EMAIL_VERIFICATION_EXPIRY_HOURS", "48"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_EXPIRY_HOURS: %w", err)
+	}
+
+	verificationResendSeconds, err := strconv.Atoi(getEnv("EMAIL_VERIFICATION_RESEND_SECONDS", "60"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_VERIFICATION_RESEND_SECONDS: %w", err)
+	}
+
+	smsConfig, err := loadSMSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimit, err := loadRateLimitConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	maxAvatarKB, err := getEnvInt("MAX_AVATAR_SIZE_KB", 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	oidcProviders, err := loadOIDCProviders()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordConfig, err := loadPasswordConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	webhookConfig, err := loadWebhookConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	outboxConfig, err := loadOutboxConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	streamConfig, err := loadStreamConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	reminderInterval, err := getEnvInt("REMINDER_INTERVAL_MINUTES", 15)
+	if err != nil {
+		return nil, err
+	}
+
+	digestConfig, err := loadDigestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	idempotencyTTL, err := getEnvInt("IDEMPOTENCY_TTL_HOURS", 24)
+	if err != nil {
+		return nil, err
+	}
+
+	logConfig, err := loadLogConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tracingConfig, err := loadTracingConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownDelaySeconds, err := getEnvInt("SHUTDOWN_DELAY_SECONDS", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeoutSeconds, err := getEnvInt("SHUTDOWN_TIMEOUT_SECONDS", 30)
+	if err != nil {
+		return nil, err
+	}
+
+	trustedProxies, err := loadTrustedProxies()
+	if err != nil {
+		return nil, err
+	}
+
+	environment := getEnv("APP_ENV", "development")
+	metricsConfig, err := loadMetricsConfig(environment)
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
 		Server: ServerConfig{
-			Port:    getEnv("PORT", "8080"),
-			GinMode: getEnv("GIN_MODE", "debug"),
+			Port:            getEnv("PORT", "8080"),
+			GinMode:         getEnv("GIN_MODE", "debug"),
+			PublicURL:       strings.TrimRight(getEnv("API_PUBLIC_URL", "http://localhost:8080"), "/"),
+			ShutdownDelay:   time.Duration(shutdownDelaySeconds) * time.Second,
+			ShutdownTimeout: time.Duration(shutdownTimeoutSeconds) * time.Second,
+			TrustedProxies:  trustedProxies,
 		},
 		Database: DatabaseConfig{
+			Driver:   getEnv("DB_DRIVER", "postgres"),
+			Path:     getEnv("DB_PATH", "dhukuti.db"),
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
 			User:     getEnv("DB_USER", "dhukuti"),
 			Password: getEnv("DB_PASSWORD", "dhukuti_password"),
 			DBName:   getEnv("DB_NAME", "dhukuti_db"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Schema:   getEnv("DB_SCHEMA", ""),
+			Migrate:  getEnv("DB_MIGRATE_ON_START", "true") == "true",
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-secret-key-change-this"),
-			Expiry: time.Duration(jwtExpiryHours) * time.Hour,
+			Secret:          getEnv("JWT_SECRET", "your-secret-key-change-this"),
+			Expiry:          time.Duration(jwtExpiryHours) * time.Hour,
+			ChallengeExpiry: time.Duration(challengeExpiryMinutes) * time.Minute,
 		},
 		App: AppConfig{
-			Environment: getEnv("APP_ENV", "development"),
+			Name:        getEnv("APP_NAME", "Dhukuti"),
+			Environment: environment,
+			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+		},
+		Mail: MailConfig{
+			SMTPHost:                getEnv("SMTP_HOST", ""),
+			SMTPPort:                getEnv("SMTP_PORT", "587"),
+			SMTPUser:                getEnv("SMTP_USER", ""),
+			SMTPPassword:            getEnv("SMTP_PASSWORD", ""),
+			From:                    getEnv("MAIL_FROM", "Dhukuti <no-reply@dhukuti.local>"),
+			VerificationTokenExpiry: time.Duration(verificationExpiryHours) * time.Hour,
+			VerificationResendDelay: time.Duration(verificationResendSeconds) * time.Second,
+		},
+		SMS:       smsConfig,
+		RateLimit: rateLimit,
+		Storage: StorageConfig{
+			UploadDir:     getEnv("UPLOAD_DIR", "./uploads"),
+			MaxAvatarSize: int64(maxAvatarKB) * 1024,
+		},
+		OIDC: OIDCConfig{
+			Providers: oidcProviders,
+		},
+		Password: passwordConfig,
+		Webhook:  webhookConfig,
+		Outbox:   outboxConfig,
+		Stream:   streamConfig,
+		Reminder: ReminderConfig{
+			Enabled:  getEnv("REMINDERS_ENABLED", "true") == "true",
+			Interval: time.Duration(reminderInterval) * time.Minute,
+			Channels: splitList(getEnv("REMINDER_CHANNELS", "in_app,email")),
+		},
+		Digest: digestConfig,
+		Idempotency: IdempotencyConfig{
+			TTL: time.Duration(idempotencyTTL) * time.Hour,
 		},
+		Log:     logConfig,
+		Metrics: metricsConfig,
+		Tracing: tracingConfig,
 	}
 
 	return cfg, nil
 }
 
+// loadMetricsConfig reads the metrics token. Production requires one, as the
+// metrics reveal business figures of the whole deployment.
+func loadMetricsConfig(environment string) (MetricsConfig, error) {
+	cfg := MetricsConfig{Token: getEnv("METRICS_TOKEN", "")}
+	if cfg.Token == "" && environment == "production" {
+		return cfg, fmt.Errorf("METRICS_TOKEN is required when APP_ENV is production")
+	}
+	return cfg, nil
+}
+
+// loadLogConfig reads the log level and format
+func loadLogConfig() (LogConfig, error) {
+	cfg := LogConfig{
+		Level:  strings.ToLower(getEnv("LOG_LEVEL", "info")),
+		Format: strings.ToLower(getEnv("LOG_FORMAT", "json")),
+	}
+	switch cfg.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		return cfg, fmt.Errorf("invalid LOG_LEVEL %q: must be debug, info, warn or error", cfg.Level)
+	}
+	if cfg.Format != "json" && cfg.Format != "text" {
+		return cfg, fmt.Errorf("invalid LOG_FORMAT %q: must be json or text", cfg.Format)
+	}
+	return cfg, nil
+}
+
+// loadTracingConfig reads the trace exporter and sampling settings
+func loadTracingConfig() (TracingConfig, error) {
+	cfg := TracingConfig{
+		Exporter:     strings.ToLower(getEnv("TRACING_EXPORTER", "none")),
+		OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""),
+		ServiceName:  getEnv("TRACING_SERVICE_NAME", "dhukuti-api"),
+	}
+	switch cfg.Exporter {
+	case "none", "otlp", "stdout":
+	default:
+		return cfg, fmt.Errorf("invalid TRACING_EXPORTER %q: must be none, otlp or stdout", cfg.Exporter)
+	}
+
+	var err error
+	cfg.SampleRatio, err = strconv.ParseFloat(getEnv("TRACING_SAMPLE_RATIO", "1"), 64)
+	if err != nil || cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return cfg, fmt.Errorf("invalid TRACING_SAMPLE_RATIO: must be a number between 0 and 1")
+	}
+	return cfg, nil
+}
+
+// loadSMSConfig reads the SMS gateway and phone verification settings
+func loadSMSConfig() (SMSConfig, error) {
+	cfg := SMSConfig{
+		GatewayURL: getEnv("SMS_GATEWAY_URL", ""),
+		APIKey:     getEnv("SMS_GATEWAY_API_KEY", ""),
+		From:       getEnv("SMS_FROM", "Dhukuti"),
+	}
+
+	var err error
+	var expiry, resend int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"SMS_CODE_EXPIRY_MINUTES", 10, &expiry},
+		{"SMS_CODE_RESEND_SECONDS", 60, &resend},
+		{"SMS_CODE_MAX_ATTEMPTS", 5, &cfg.CodeMaxAttempts},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.CodeExpiry = time.Duration(expiry) * time.Minute
+	cfg.CodeResendDelay = time.Duration(resend) * time.Second
+
+	return cfg, nil
+}
+
+// loadRateLimitConfig reads the rate limiting and lockout settings
+func loadRateLimitConfig() (RateLimitConfig, error) {
+	cfg := RateLimitConfig{
+		Store: getEnv("RATE_LIMIT_STORE", "memory"),
+	}
+
+	var err error
+	var ipWindow, backoffBase, backoffMax, failureWindow, lockout, resetWindow int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"RATE_LIMIT_IP_REQUESTS", 20, &cfg.IPRequests},
+		{"RATE_LIMIT_IP_WINDOW_SECONDS", 60, &ipWindow},
+		{"RATE_LIMIT_FREE_FAILURES", 3, &cfg.FreeFailures},
+		{"RATE_LIMIT_BACKOFF_BASE_SECONDS", 1, &backoffBase},
+		{"RATE_LIMIT_BACKOFF_MAX_SECONDS", 900, &backoffMax},
+		{"RATE_LIMIT_FAILURE_WINDOW_MINUTES", 60, &failureWindow},
+		{"ACCOUNT_LOCKOUT_THRESHOLD", 10, &cfg.LockoutThreshold},
+		{"ACCOUNT_LOCKOUT_MINUTES", 30, &lockout},
+		{"PASSWORD_RESET_REQUESTS", 3, &cfg.PasswordResetRequests},
+		{"PASSWORD_RESET_WINDOW_MINUTES", 60, &resetWindow},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.IPWindow = time.Duration(ipWindow) * time.Second
+	cfg.BackoffBase = time.Duration(backoffBase) * time.Second
+	cfg.BackoffMax = time.Duration(backoffMax) * time.Second
+	cfg.FailureWindow = time.Duration(failureWindow) * time.Minute
+	cfg.LockoutDuration = time.Duration(lockout) * time.Minute
+	cfg.PasswordResetWindow = time.Duration(resetWindow) * time.Minute
+
+	return cfg, nil
+}
+
+// loadPasswordConfig reads the password policy and Argon2id parameters
+func loadPasswordConfig() (PasswordConfig, error) {
+	cfg := PasswordConfig{
+		BreachedListPath: getEnv("PASSWORD_BREACHED_LIST", ""),
+	}
+
+	var err error
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"PASSWORD_MIN_LENGTH", 8, &cfg.MinLength},
+		{"PASSWORD_MAX_LENGTH", 128, &cfg.MaxLength},
+		{"PASSWORD_ARGON2_MEMORY_KB", 64 * 1024, &cfg.Argon2Memory},
+		{"PASSWORD_ARGON2_ITERATIONS", 3, &cfg.Argon2Iterations},
+		{"PASSWORD_ARGON2_PARALLELISM", 2, &cfg.Argon2Parallelism},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	if cfg.Argon2Memory < 8 || cfg.Argon2Iterations < 1 || cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+		return cfg, fmt.Errorf("invalid Argon2 parameters")
+	}
+
+	return cfg, nil
+}
+
+// loadWebhookConfig reads the webhook delivery settings
+func loadWebhookConfig() (WebhookConfig, error) {
+	cfg := WebhookConfig{
+		AllowPrivateNetworks: getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false") == "true",
+	}
+
+	var err error
+	var backoffBase, timeout, pollInterval int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"WEBHOOK_MAX_ATTEMPTS", 8, &cfg.MaxAttempts},
+		{"WEBHOOK_BACKOFF_BASE_SECONDS", 30, &backoffBase},
+		{"WEBHOOK_TIMEOUT_SECONDS", 10, &timeout},
+		{"WEBHOOK_POLL_INTERVAL_SECONDS", 5, &pollInterval},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.BackoffBase = time.Duration(backoffBase) * time.Second
+	cfg.Timeout = time.Duration(timeout) * time.Second
+	cfg.PollInterval = time.Duration(pollInterval) * time.Second
+
+	return cfg, nil
+}
+
+// loadOutboxConfig reads the outbox dispatcher settings
+func loadOutboxConfig() (OutboxConfig, error) {
+	var cfg OutboxConfig
+
+	var err error
+	var backoffBase, pollInterval, retention int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"OUTBOX_MAX_ATTEMPTS", 10, &cfg.MaxAttempts},
+		{"OUTBOX_BACKOFF_BASE_SECONDS", 1, &backoffBase},
+		{"OUTBOX_POLL_INTERVAL_MS", 1000, &pollInterval},
+		{"OUTBOX_RETENTION_HOURS", 168, &retention},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.BackoffBase = time.Duration(backoffBase) * time.Second
+	cfg.PollInterval = time.Duration(pollInterval) * time.Millisecond
+	cfg.Retention = time.Duration(retention) * time.Hour
+
+	return cfg, nil
+}
+
+// loadStreamConfig reads the real-time event stream settings
+func loadStreamConfig() (StreamConfig, error) {
+	var cfg StreamConfig
+
+	var err error
+	var retention, heartbeat, ticketExpiry int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"STREAM_RETENTION_HOURS", 24, &retention},
+		{"STREAM_HEARTBEAT_SECONDS", 25, &heartbeat},
+		{"STREAM_TICKET_EXPIRY_SECONDS", 60, &ticketExpiry},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+
+	cfg.Retention = time.Duration(retention) * time.Hour
+	cfg.Heartbeat = time.Duration(heartbeat) * time.Second
+	cfg.TicketExpiry = time.Duration(ticketExpiry) * time.Second
+
+	return cfg, nil
+}
+
+// loadDigestConfig reads the email digest settings
+func loadDigestConfig() (DigestConfig, error) {
+	cfg := DigestConfig{
+		Enabled: getEnv("DIGEST_ENABLED", "true") == "true",
+	}
+
+	var err error
+	var interval, weekday int
+	settings := []struct {
+		key          string
+		defaultValue int
+		target       *int
+	}{
+		{"DIGEST_INTERVAL_MINUTES", 15, &interval},
+		{"DIGEST_HOUR", 8, &cfg.Hour},
+		{"DIGEST_WEEKDAY", 1, &weekday},
+	}
+	for _, setting := range settings {
+		if *setting.target, err = getEnvInt(setting.key, setting.defaultValue); err != nil {
+			return cfg, err
+		}
+	}
+	if cfg.Hour < 0 || cfg.Hour > 23 {
+		return cfg, fmt.Errorf("invalid DIGEST_HOUR: must be between 0 and 23")
+	}
+	if weekday < 0 || weekday > 6 {
+		return cfg, fmt.Errorf("invalid DIGEST_WEEKDAY: must be between 0 (Sunday) and 6")
+	}
+
+	cfg.Interval = time.Duration(interval) * time.Minute
+	cfg.Weekday = time.Weekday(weekday)
+
+	return cfg, nil
+}
+
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS. Each name
+// is configured with OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET and optionally OIDC_<NAME>_SCOPES.
+func loadOIDCProviders() ([]OIDCProviderConfig, error) {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		provider := OIDCProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %s requires %sISSUER and %sCLIENT_ID", name, prefix, prefix)
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
 // GetDSN returns the database connection string
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf(
+	dsn := fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		c.Database.Host,
 		c.Database.Port,
@@ -85,6 +688,10 @@ func (c *Config) GetDSN() string {
 		c.Database.DBName,
 		c.Database.SSLMode,
 	)
+	if c.Database.Schema != "" {
+		dsn += " search_path=" + c.Database.Schema
+	}
+	return dsn
 }
 
 // getEnv gets an environment variable or returns a default value
@@ -94,3 +701,35 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt gets an integer environment variable or returns a default value
+func getEnvInt(key string, defaultValue int) (int, error) {
+	value, err := strconv.Atoi(getEnv(key, strconv.Itoa(defaultValue)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// loadTrustedProxies reads TRUSTED_PROXIES. Client IPs taken from
+// X-Forwarded-For key the rate limits, so only listed proxies are believed.
+func loadTrustedProxies() ([]string, error) {
+	proxies := splitList(getEnv("TRUSTED_PROXIES", ""))
+	for _, proxy := range proxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", proxy)
+		}
+	}
+	return proxies, nil
+}
+
+// splitList splits a comma-separated setting, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -2,10 +2,12 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/Sudan23/dhukuti/internal/config"
-	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/tracing"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -18,44 +20,58 @@ var DB *gorm.DB
 func Connect(cfg *config.Config) error {
 	var err error
 
-	// Configure GORM logger
-	logLevel := logger.Info
-	if cfg.App.Environment == "production" {
-		logLevel = logger.Error
+	// Every query is logged at debug level; otherwise only failed and slow ones
+	logLevel := logger.Warn
+	if cfg.Log.Level == "debug" {
+		logLevel = logger.Info
+	}
+
+	var dialector gorm.Dialector
+	switch cfg.Database.Driver {
+	case "", "postgres":
+		dialector = postgres.Open(cfg.GetDSN())
+	case "sqlite":
+		dialector = sqlite.Open(cfg.Database.Path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	default:
+		return fmt.Errorf("unknown database driver: %s", cfg.Database.Driver)
 	}
 
 	// Connect to database
-	DB, err = gorm.Open(postgres.Open(cfg.GetDSN()), &gorm.Config{
-		Logger: logger.Default.LogMode(logLevel),
+	DB, err = gorm.Open(dialector, &gorm.Config{
+		Logger: logging.NewGormLogger(logLevel),
+		// Report constraint violations as gorm.ErrDuplicatedKey etc.
+		TranslateError: true,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	log.Println("Database connection established")
-	return nil
-}
+	// Each query gets a span in the trace of the request running it
+	if err := DB.Use(tracing.NewPlugin()); err != nil {
+		return fmt.Errorf("failed to set up query tracing: %w", err)
+	}
 
-// Migrate runs database migrations
-func Migrate() error {
-	log.Println("Running database migrations...")
-
-	err := DB.AutoMigrate(
-		&models.User{},
-		&models.Circle{},
-		&models.CircleMember{},
-		&models.MemberApproval{},
-		&models.Contribution{},
-		&models.AmountApproval{},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to run migrations: %w", err)
+	if !IsPostgres(DB) {
+		// SQLite has a single writer, and every connection to :memory: opens
+		// a separate database
+		sqlDB, err := DB.DB()
+		if err != nil {
+			return fmt.Errorf("failed to configure database: %w", err)
+		}
+		sqlDB.SetMaxOpenConns(1)
 	}
 
-	log.Println("Database migrations completed successfully")
+	slog.Info("Database connection established", "driver", DB.Dialector.Name())
 	return nil
 }
 
+// IsPostgres reports whether db is a Postgres database. Features that
+// coordinate several API instances (advisory locks, LISTEN/NOTIFY, row
+// locks) are only available there; SQLite is meant for a single instance.
+func IsPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
 // GetDB returns the database instance
 func GetDB() *gorm.DB {
 	return DB
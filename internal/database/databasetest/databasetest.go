@@ -0,0 +1,76 @@
+// Package databasetest gives each test an empty database of its own. Tests
+// run on in-memory SQLite unless DB_DRIVER=postgres, in which case each test
+// gets a fresh schema in the Postgres database configured by the DB_*
+// variables, so the Postgres migrations and locking are exercised too.
+package databasetest
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Postgres reports whether tests run against Postgres
+func Postgres() bool {
+	return os.Getenv("DB_DRIVER") == "postgres"
+}
+
+// Config returns the configuration of a new, empty database. A Postgres
+// schema is dropped when the test ends.
+func Config(t testing.TB) config.DatabaseConfig {
+	t.Helper()
+	if !Postgres() {
+		return config.DatabaseConfig{Driver: "sqlite", Path: ":memory:"}
+	}
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	admin, err := gorm.Open(postgres.Open(cfg.GetDSN()), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	sqlDB, err := admin.DB()
+	require.NoError(t, err)
+
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB.Close()
+	})
+
+	db := cfg.Database
+	db.Driver = "postgres"
+	db.Schema = schema
+	return db
+}
+
+// Open connects to a new, empty database, closing it when the test ends
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	cfg := &config.Config{Database: Config(t)}
+
+	dialector := sqlite.Open(":memory:?_pragma=foreign_keys(1)")
+	if cfg.Database.Driver == "postgres" {
+		dialector = postgres.Open(cfg.GetDSN())
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	if cfg.Database.Driver == "sqlite" {
+		// Every connection to :memory: opens a separate database
+		sqlDB.SetMaxOpenConns(1)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
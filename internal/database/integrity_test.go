@@ -0,0 +1,117 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// seedCircle creates a circle whose admin is an active member and returns the
+// circle and its admin
+func seedCircle(t *testing.T, db *gorm.DB) (models.Circle, models.User) {
+	t.Helper()
+	admin := models.User{Email: "admin@example.com", Password: "x", Name: "Admin"}
+	require.NoError(t, db.Create(&admin).Error)
+	circle := models.Circle{Name: "Savers", AmountPerMember: 100, CreatorID: admin.ID}
+	// Leaves out columns the baseline doesn't have
+	require.NoError(t, db.Omit("version").Create(&circle).Error)
+	require.NoError(t, db.Create(&models.CircleMember{CircleID: circle.ID, UserID: admin.ID, Role: "admin", Status: "active"}).Error)
+	return circle, admin
+}
+
+func TestIntegrityConstraints(t *testing.T) {
+	db := newTestDB(t)
+	_, err := MigrateUp(db)
+	require.NoError(t, err)
+
+	circle, admin := seedCircle(t, db)
+	bob := models.User{Email: "bob@example.com", Password: "x", Name: "Bob"}
+	outsider := models.User{Email: "outsider@example.com", Password: "x", Name: "Outsider"}
+	require.NoError(t, db.Create(&bob).Error)
+	require.NoError(t, db.Create(&outsider).Error)
+
+	t.Run("duplicate member", func(t *testing.T) {
+		err := db.Create(&models.CircleMember{CircleID: circle.ID, UserID: admin.ID, Role: "member", Status: "pending"}).Error
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	})
+
+	t.Run("unknown role and status", func(t *testing.T) {
+		assert.Error(t, db.Create(&models.CircleMember{CircleID: circle.ID, UserID: bob.ID, Role: "owner", Status: "active"}).Error)
+		assert.Error(t, db.Create(&models.CircleMember{CircleID: circle.ID, UserID: bob.ID, Role: "member", Status: "left"}).Error)
+	})
+
+	t.Run("member of an unknown circle", func(t *testing.T) {
+		err := db.Create(&models.CircleMember{CircleID: 999, UserID: bob.ID, Role: "member", Status: "pending"}).Error
+		assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+	})
+
+	require.NoError(t, db.Create(&models.CircleMember{CircleID: circle.ID, UserID: bob.ID, Role: "member", Status: "pending"}).Error)
+	vote := models.MemberApproval{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: admin.ID}
+	require.NoError(t, db.Create(&vote).Error)
+
+	t.Run("duplicate vote", func(t *testing.T) {
+		err := db.Create(&models.MemberApproval{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: admin.ID}).Error
+		assert.ErrorIs(t, err, gorm.ErrDuplicatedKey)
+	})
+
+	t.Run("vote by a non-member", func(t *testing.T) {
+		err := db.Create(&models.MemberApproval{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: outsider.ID}).Error
+		assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+		err = db.Create(&models.AmountApproval{CircleID: circle.ID, ProposerID: admin.ID, ProposedAmount: 200, ApproverID: outsider.ID}).Error
+		assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+	})
+
+	t.Run("removing a member removes their votes", func(t *testing.T) {
+		require.NoError(t, db.Unscoped().Where("circle_id = ? AND user_id = ?", circle.ID, bob.ID).Delete(&models.CircleMember{}).Error)
+		var count int64
+		require.NoError(t, db.Model(&models.MemberApproval{}).Where("pending_user_id = ?", bob.ID).Count(&count).Error)
+		assert.Zero(t, count)
+	})
+
+	t.Run("contributions outlive the membership", func(t *testing.T) {
+		// SQLite reports RESTRICT violations without an error GORM translates
+		require.NoError(t, db.Create(&models.Contribution{CircleID: circle.ID, UserID: bob.ID, Amount: 100, Month: circle.CreatedAt}).Error)
+		assert.Error(t, db.Unscoped().Delete(&models.User{}, bob.ID).Error)
+	})
+}
+
+func TestIntegrityMigrationCleansUpRows(t *testing.T) {
+	db := newTestDB(t)
+	migrations, err := LoadMigrations(db.Dialector.Name())
+	require.NoError(t, err)
+
+	// A database at the baseline with rows the constraints reject
+	require.NoError(t, db.Exec(adaptSQL(db, migrations[0].Up)).Error)
+	require.NoError(t, db.AutoMigrate(&schemaMigration{}))
+	require.NoError(t, db.Create(&schemaMigration{Version: migrations[0].Version, Name: migrations[0].Name}).Error)
+	circle, admin := seedCircle(t, db)
+	bob := models.User{Email: "bob@example.com", Password: "x", Name: "Bob"}
+	require.NoError(t, db.Create(&bob).Error)
+	require.NoError(t, db.Create(&[]models.CircleMember{
+		{CircleID: circle.ID, UserID: bob.ID, Role: "member", Status: "pending"},
+		{CircleID: circle.ID, UserID: bob.ID, Role: "member", Status: "active"},
+		{CircleID: circle.ID, UserID: admin.ID, Role: "owner", Status: "active"},
+	}).Error)
+	require.NoError(t, db.Create(&[]models.MemberApproval{
+		{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: admin.ID},
+		{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: admin.ID, Approved: true},
+		{CircleID: circle.ID, PendingUserID: bob.ID, ApproverUserID: 999},
+	}).Error)
+
+	_, err = MigrateUp(db)
+	require.NoError(t, err)
+
+	var members []models.CircleMember
+	require.NoError(t, db.Order("user_id").Find(&members).Error)
+	require.Len(t, members, 2)
+	assert.Equal(t, "admin", members[0].Role, "the admin's active row is kept")
+	assert.Equal(t, "active", members[1].Status, "the active row wins over the pending one")
+
+	var votes []models.MemberApproval
+	require.NoError(t, db.Find(&votes).Error)
+	require.Len(t, votes, 1)
+	assert.True(t, votes[0].Approved, "a cast vote wins over an uncast one")
+}
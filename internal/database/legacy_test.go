@@ -0,0 +1,100 @@
+package database
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The models as AutoMigrate created them before versioned migrations, frozen
+// at the first release so upgrades are tested against a real old schema
+
+type legacyUser struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Email     string         `gorm:"uniqueIndex;not null"`
+	Password  string         `gorm:"not null"`
+	Name      string         `gorm:"not null"`
+	Circles   []legacyCircle `gorm:"many2many:circle_members;"`
+}
+
+func (legacyUser) TableName() string { return "users" }
+
+type legacyCircle struct {
+	ID              uint `gorm:"primarykey"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	Name            string         `gorm:"not null"`
+	Description     string
+	AmountPerMember uint         `gorm:"not null;default:0"`
+	ProposedAmount  uint         `gorm:"default:0"`
+	CreatorID       uint         `gorm:"not null"`
+	Creator         legacyUser   `gorm:"foreignKey:CreatorID"`
+	Members         []legacyUser `gorm:"many2many:circle_members;"`
+}
+
+func (legacyCircle) TableName() string { return "circles" }
+
+type legacyCircleMember struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	CircleID  uint           `gorm:"not null;index:idx_circle_user,priority:1;index:idx_circle_status,priority:1"`
+	UserID    uint           `gorm:"not null;index:idx_circle_user,priority:2"`
+	Role      string         `gorm:"not null;default:'member'"`
+	Status    string         `gorm:"not null;default:'active';index:idx_circle_status,priority:2"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyCircleMember) TableName() string { return "circle_members" }
+
+type legacyMemberApproval struct {
+	ID             uint `gorm:"primarykey"`
+	CircleID       uint `gorm:"not null;index:idx_member_approval,priority:1"`
+	PendingUserID  uint `gorm:"not null;index:idx_member_approval,priority:2"`
+	ApproverUserID uint `gorm:"not null;index:idx_member_approval,priority:3"`
+	Approved       bool `gorm:"default:false"`
+	CreatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyMemberApproval) TableName() string { return "member_approvals" }
+
+type legacyContribution struct {
+	ID        uint      `gorm:"primarykey"`
+	CircleID  uint      `gorm:"not null;index:idx_contribution,priority:1"`
+	UserID    uint      `gorm:"not null;index:idx_contribution,priority:2"`
+	Amount    uint      `gorm:"not null"`
+	Month     time.Time `gorm:"not null"`
+	CreatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyContribution) TableName() string { return "contributions" }
+
+type legacyAmountApproval struct {
+	ID             uint `gorm:"primarykey"`
+	CircleID       uint `gorm:"not null;index:idx_amount_approval,priority:1"`
+	ProposerID     uint `gorm:"not null"`
+	ProposedAmount uint `gorm:"not null"`
+	ApproverID     uint `gorm:"not null;index:idx_amount_approval,priority:2"`
+	Approved       bool `gorm:"default:false"`
+	CreatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+func (legacyAmountApproval) TableName() string { return "amount_approvals" }
+
+// createLegacySchema creates the tables as the first release did on startup
+func createLegacySchema(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&legacyUser{},
+		&legacyCircle{},
+		&legacyCircleMember{},
+		&legacyMemberApproval{},
+		&legacyContribution{},
+		&legacyAmountApproval{},
+	)
+}
@@ -0,0 +1,295 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Sudan23/dhukuti/migrations"
+	"gorm.io/gorm"
+)
+
+// migrationLockKey is the advisory lock held while migrating, so instances
+// starting together apply each migration once
+const migrationLockKey = 0x64686b0003
+
+// migrationFile matches <version>_<name>.up.sql and <version>_<name>.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// addColumnIfNotExists matches ALTER TABLE ... ADD COLUMN IF NOT EXISTS,
+// which SQLite lacks
+var addColumnIfNotExists = regexp.MustCompile(`ALTER TABLE "(\w+)" ADD COLUMN IF NOT EXISTS "(\w+)"([^;]*);`)
+
+// Migration is a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and when it was applied, if it has been
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// schemaMigration records an applied migration in schema_migrations
+type schemaMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName specifies the table name for schemaMigration
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate applies pending migrations to DB
+func Migrate() error {
+	slog.Info("Running database migrations")
+
+	applied, err := MigrateUp(DB)
+	if err != nil {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+	for _, migration := range applied {
+		slog.Info("Applied migration", "version", migration.Version, "name", migration.Name)
+	}
+
+	slog.Info("Database migrations completed")
+	return nil
+}
+
+// MigrateUp applies pending migrations in version order, each in its own
+// transaction, and returns the ones it applied
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(db, func() error {
+		states, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for _, state := range states {
+			if state.AppliedAt != nil {
+				continue
+			}
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(adaptSQL(tx, state.Up)).Error; err != nil {
+					return err
+				}
+				return tx.Create(&schemaMigration{
+					Version:   state.Version,
+					Name:      state.Name,
+					AppliedAt: time.Now(),
+				}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+			}
+			applied = append(applied, state.Migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown rolls back the last steps applied migrations, newest first,
+// and returns the ones it rolled back
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	var rolledBack []Migration
+	err := withMigrationLock(db, func() error {
+		states, err := MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+
+		for i := len(states) - 1; i >= 0 && len(rolledBack) < steps; i-- {
+			state := states[i]
+			if state.AppliedAt == nil {
+				continue
+			}
+			if state.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down migration", state.Version, state.Name)
+			}
+			if err := db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(adaptSQL(tx, state.Down)).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&schemaMigration{}, state.Version).Error
+			}); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", state.Version, state.Name, err)
+			}
+			rolledBack = append(rolledBack, state.Migration)
+		}
+		return nil
+	})
+	return rolledBack, err
+}
+
+// MigrationStatus lists the migrations for db's driver in version order and
+// when each was applied. Applied versions without a file, e.g. after
+// downgrading the binary, are included without their SQL.
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	available, err := LoadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var applied []schemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	appliedAt := make(map[int64]time.Time, len(applied))
+	for _, row := range applied {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	states := make([]MigrationState, 0, len(available))
+	for _, migration := range available {
+		state := MigrationState{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			state.AppliedAt = &at
+			delete(appliedAt, migration.Version)
+		}
+		states = append(states, state)
+	}
+	for _, row := range applied {
+		if _, unknown := appliedAt[row.Version]; unknown {
+			at := row.AppliedAt
+			states = append(states, MigrationState{
+				Migration: Migration{Version: row.Version, Name: row.Name},
+				AppliedAt: &at,
+			})
+		}
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// PendingMigrations returns the migrations for db's driver that have not been
+// applied yet. Unlike MigrationStatus it only reads, so it is cheap enough for
+// health checks.
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	available, err := LoadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int64
+	if err := db.Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]bool, len(versions))
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	var pending []Migration
+	for _, migration := range available {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// LoadMigrations reads the embedded migrations for a driver in version order
+func LoadMigrations(driver string) ([]Migration, error) {
+	files, err := fs.ReadDir(migrations.FS, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database driver %s: %w", driver, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file %s/%s", driver, file.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		sql, err := fs.ReadFile(migrations.FS, driver+"/"+file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(sql)
+		} else {
+			migration.Down = string(sql)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up migration", migration.Version, migration.Name)
+		}
+		list = append(list, *migration)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// adaptSQL rewrites what db's driver can't run. On SQLite an ADD COLUMN IF
+// NOT EXISTS becomes a plain ADD COLUMN if the table exists without the
+// column, and is dropped otherwise: the column exists, or the table is
+// created by the same migration, with the column.
+func adaptSQL(db *gorm.DB, sql string) string {
+	if IsPostgres(db) {
+		return sql
+	}
+	return addColumnIfNotExists.ReplaceAllStringFunc(sql, func(statement string) string {
+		match := addColumnIfNotExists.FindStringSubmatch(statement)
+		table, column := match[1], match[2]
+		if !db.Migrator().HasTable(table) || db.Migrator().HasColumn(table, column) {
+			return ""
+		}
+		return fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s"%s;`, table, column, match[3])
+	})
+}
+
+// withMigrationLock runs fn while holding the migration lock. SQLite serves a
+// single instance and needs none.
+func withMigrationLock(db *gorm.DB, fn func() error) error {
+	if !IsPostgres(db) {
+		return fn()
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Waits for any other instance to finish migrating
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
+	return fn()
+}
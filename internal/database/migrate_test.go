@@ -0,0 +1,254 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database/databasetest"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// schemaModels lists every model stored in the database
+var schemaModels = []interface{}{
+	&models.User{},
+	&models.Circle{},
+	&models.CircleMember{},
+	&models.MemberApproval{},
+	&models.Contribution{},
+	&models.AmountApproval{},
+	&models.PasswordResetToken{},
+	&models.EmailVerificationToken{},
+	&models.RecoveryCode{},
+	&models.AccountUnlockToken{},
+	&models.RateLimit{},
+	&models.ExternalIdentity{},
+	&models.OIDCState{},
+	&models.PersonalAccessToken{},
+	&models.Webhook{},
+	&models.WebhookDelivery{},
+	&models.OutboxMessage{},
+	&models.Notification{},
+	&models.NotificationPreference{},
+	&models.StreamEvent{},
+	&models.PaymentReminder{},
+	&models.PhoneVerification{},
+	&models.SMSNotification{},
+	&models.IdempotencyKey{},
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	return databasetest.Open(t)
+}
+
+// assertSchemaMatchesModels checks every model's columns, indexes and check
+// constraints exist
+func assertSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	migrator := db.Migrator()
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+
+		if !assert.True(t, migrator.HasTable(model), "table %s", table) {
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, migrator.HasColumn(model, field.DBName), "column %s.%s", table, field.DBName)
+			}
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			if !assert.True(t, migrator.HasIndex(model, index.Name), "index %s on %s", index.Name, table) {
+				continue
+			}
+			query := "SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?"
+			if IsPostgres(db) {
+				query = "SELECT indexdef FROM pg_indexes WHERE schemaname = current_schema() AND indexname = ?"
+			}
+			var sql string
+			require.NoError(t, db.Raw(query, index.Name).Scan(&sql).Error)
+			assert.Equal(t, index.Class == "UNIQUE", strings.HasPrefix(sql, "CREATE UNIQUE"), "uniqueness of index %s on %s", index.Name, table)
+		}
+		for _, check := range stmt.Schema.ParseCheckConstraints() {
+			assert.True(t, migrator.HasConstraint(model, check.Name), "check %s on %s", check.Name, table)
+		}
+	}
+}
+
+// describeSchema lists every table's columns and indexes, to compare schemas
+func describeSchema(t *testing.T, db *gorm.DB) map[string][]string {
+	t.Helper()
+	tables, err := db.Migrator().GetTables()
+	require.NoError(t, err)
+
+	schema := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		if strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		columns, err := db.Migrator().ColumnTypes(table)
+		require.NoError(t, err)
+		for _, column := range columns {
+			nullable, _ := column.Nullable()
+			schema[table] = append(schema[table], fmt.Sprintf("column %s %s nullable=%t",
+				column.Name(), strings.ToLower(column.DatabaseTypeName()), nullable))
+		}
+		indexes, err := db.Migrator().GetIndexes(table)
+		require.NoError(t, err)
+		for _, index := range indexes {
+			unique, _ := index.Unique()
+			schema[table] = append(schema[table], fmt.Sprintf("index %s %v unique=%t", index.Name(), index.Columns(), unique))
+		}
+		sort.Strings(schema[table])
+	}
+	return schema
+}
+
+func TestMigrations(t *testing.T) {
+	db := newTestDB(t)
+
+	applied, err := MigrateUp(db)
+	require.NoError(t, err)
+	require.NotEmpty(t, applied)
+	assertSchemaMatchesModels(t, db)
+
+	applied, err = MigrateUp(db)
+	require.NoError(t, err)
+	assert.Empty(t, applied, "migrations are applied once")
+
+	states, err := MigrationStatus(db)
+	require.NoError(t, err)
+	for _, state := range states {
+		assert.NotNil(t, state.AppliedAt, "migration %d_%s", state.Version, state.Name)
+	}
+	pending, err := PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// Rolling everything back leaves only the bookkeeping
+	rolledBack, err := MigrateDown(db, len(states))
+	require.NoError(t, err)
+	assert.Len(t, rolledBack, len(states))
+	assert.Equal(t, states[len(states)-1].Version, rolledBack[0].Version, "newest first")
+	pending, err = PendingMigrations(db)
+	require.NoError(t, err)
+	assert.Len(t, pending, len(states))
+	for _, model := range schemaModels {
+		assert.False(t, db.Migrator().HasTable(model))
+	}
+
+	_, err = MigrateUp(db)
+	require.NoError(t, err)
+	assertSchemaMatchesModels(t, db)
+}
+
+func TestMigrationStatusUnknownVersion(t *testing.T) {
+	db := newTestDB(t)
+	_, err := MigrateUp(db)
+	require.NoError(t, err)
+
+	// Applied by a newer release
+	require.NoError(t, db.Create(&schemaMigration{Version: 99999, Name: "from_the_future"}).Error)
+
+	states, err := MigrationStatus(db)
+	require.NoError(t, err)
+	last := states[len(states)-1]
+	assert.Equal(t, int64(99999), last.Version)
+	assert.NotNil(t, last.AppliedAt)
+	assert.Empty(t, last.Up)
+
+	_, err = MigrateDown(db, 1)
+	assert.ErrorContains(t, err, "has no down migration")
+}
+
+func TestBaselineAdoptsAutoMigratedSchema(t *testing.T) {
+	upgraded := newTestDB(t)
+	require.NoError(t, createLegacySchema(upgraded))
+	require.NoError(t, upgraded.Create(&legacyUser{Email: "ana@example.com", Password: "x", Name: "Ana"}).Error)
+
+	_, err := MigrateUp(upgraded)
+	require.NoError(t, err)
+	assertSchemaMatchesModels(t, upgraded)
+
+	fresh := newTestDB(t)
+	_, err = MigrateUp(fresh)
+	require.NoError(t, err)
+	assert.Equal(t, describeSchema(t, fresh), describeSchema(t, upgraded))
+
+	var count int64
+	require.NoError(t, upgraded.Model(&models.User{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestExistingUsersCountAsVerified(t *testing.T) {
+	db := newTestDB(t)
+	_, err := MigrateUp(db)
+	require.NoError(t, err)
+	_, err = MigrateDown(db, 1)
+	require.NoError(t, err)
+
+	// Registered before email verification existed
+	legacy := models.User{Email: "legacy@example.com", Password: "x", Name: "Legacy"}
+	require.NoError(t, db.Create(&legacy).Error)
+	// Registered since and sent a verification email
+	pending := models.User{Email: "pending@example.com", Password: "x", Name: "Pending"}
+	require.NoError(t, db.Create(&pending).Error)
+	require.NoError(t, db.Create(&models.EmailVerificationToken{UserID: pending.ID, Email: pending.Email, Token: "hash", ExpiresAt: time.Now()}).Error)
+	// Deleted
+	deleted := models.User{Email: "deleted@example.com", Password: "x", Name: "Deleted", AnonymizedAt: &legacy.CreatedAt}
+	require.NoError(t, db.Create(&deleted).Error)
+
+	_, err = MigrateUp(db)
+	require.NoError(t, err)
+
+	verified := func(user models.User) bool {
+		require.NoError(t, db.First(&user, user.ID).Error)
+		return user.IsEmailVerified()
+	}
+	assert.True(t, verified(legacy))
+	assert.False(t, verified(pending))
+	assert.False(t, verified(deleted))
+
+	_, err = MigrateDown(db, 1)
+	require.NoError(t, err)
+	assert.False(t, verified(legacy), "rolled back")
+}
+
+func TestUpgradedUsersCountAsVerified(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, createLegacySchema(db))
+	legacy := legacyUser{Email: "legacy@example.com", Password: "x", Name: "Legacy"}
+	require.NoError(t, db.Create(&legacy).Error)
+
+	_, err := MigrateUp(db)
+	require.NoError(t, err)
+
+	var user models.User
+	require.NoError(t, db.First(&user, legacy.ID).Error)
+	assert.True(t, user.IsEmailVerified())
+	require.NotNil(t, user.EmailVerifiedAt)
+	assert.WithinDuration(t, legacy.CreatedAt, *user.EmailVerifiedAt, time.Second, "verified when they registered")
+}
+
+func TestMigrationFilesMatchAcrossDrivers(t *testing.T) {
+	postgres, err := LoadMigrations("postgres")
+	require.NoError(t, err)
+	sqlite, err := LoadMigrations("sqlite")
+	require.NoError(t, err)
+
+	require.Equal(t, len(postgres), len(sqlite), "every migration exists for both drivers")
+	for i := range postgres {
+		assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+		assert.NotEmpty(t, postgres[i].Down, "migration %d_%s needs a down migration", postgres[i].Version, postgres[i].Name)
+		assert.NotEmpty(t, sqlite[i].Down, "migration %d_%s needs a down migration", sqlite[i].Version, sqlite[i].Name)
+	}
+}
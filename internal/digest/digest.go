@@ -0,0 +1,125 @@
+// Package digest emails members a daily or weekly summary across all their
+// circles: votes waiting for them, contributions coming due and the
+// contributions recorded since the last digest.
+//
+// Every API instance runs the Job. Before sending, an instance claims the
+// digest by moving the user's digest_sent_at to the scheduled time with a
+// conditional update, so each digest is sent by exactly one instance.
+package digest
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+)
+
+// maxContributions caps the contributions listed in one digest
+const maxContributions = 20
+
+//go:embed templates
+var templateFS embed.FS
+
+var funcs = map[string]interface{}{
+	"date": func(t time.Time) string { return t.Format("Mon 2 Jan") },
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templateFS, "templates/digest.html"))
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(funcs).ParseFS(templateFS, "templates/digest.txt"))
+)
+
+// Digest is the summary sent to one user
+type Digest struct {
+	Name        string
+	Frequency   string // daily or weekly
+	Since       time.Time
+	FrontendURL string
+
+	Votes             []Vote
+	Dues              []Due
+	Contributions     []Contribution
+	MoreContributions int // recorded but not listed
+}
+
+// Vote is a decision waiting for the user
+type Vote struct {
+	CircleID uint
+	Circle   string
+	Subject  string
+}
+
+// Due is the user's next contribution to a circle
+type Due struct {
+	CircleID    uint
+	Circle      string
+	Amount      uint
+	DueDate     time.Time
+	Outstanding int // unpaid contributions past their due date
+}
+
+// Overdue reports whether the contribution is past its due date
+func (d Due) Overdue() bool {
+	return d.Outstanding > 0
+}
+
+// Contribution is a payment recorded in one of the user's circles
+type Contribution struct {
+	CircleID  uint
+	Circle    string
+	Member    string
+	Amount    uint
+	CreatedAt time.Time
+}
+
+// IsEmpty reports whether there is nothing to tell the user
+func (d *Digest) IsEmpty() bool {
+	return len(d.Votes) == 0 && len(d.Dues) == 0 && len(d.Contributions) == 0
+}
+
+// Subject returns the email subject line
+func (d *Digest) Subject() string {
+	if d.Frequency == models.DigestWeekly {
+		return "Your weekly Dhukuti digest"
+	}
+	return "Your daily Dhukuti digest"
+}
+
+// Render returns the text and HTML bodies of the digest
+func (d *Digest) Render() (text, html string, err error) {
+	var textBuf, htmlBuf bytes.Buffer
+	if err := textTemplate.Execute(&textBuf, d); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplate.Execute(&htmlBuf, d); err != nil {
+		return "", "", err
+	}
+	return textBuf.String(), htmlBuf.String(), nil
+}
+
+// Slot returns the most recent time at or before now that a digest of the
+// given frequency is scheduled for, in the user's time zone
+func Slot(frequency string, loc *time.Location, hour int, weekday time.Weekday, now time.Time) time.Time {
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	if slot.After(local) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if frequency == models.DigestWeekly {
+		back := (int(slot.Weekday()) - int(weekday) + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot
+}
+
+// period returns when the digest scheduled at slot starts looking back from,
+// and the next slot, up to which upcoming dues are listed
+func period(frequency string, slot time.Time) (since, until time.Time) {
+	if frequency == models.DigestWeekly {
+		return slot.AddDate(0, 0, -7), slot.AddDate(0, 0, 7)
+	}
+	return slot.AddDate(0, 0, -1), slot.AddDate(0, 0, 1)
+}
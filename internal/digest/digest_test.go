@@ -0,0 +1,88 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlot(t *testing.T) {
+	kathmandu, err := time.LoadLocation("Asia/Kathmandu") // UTC+5:45
+	require.NoError(t, err)
+
+	// Wednesday 4 March 2026
+	tests := []struct {
+		name      string
+		frequency string
+		loc       *time.Location
+		now       time.Time
+		want      time.Time
+	}{
+		{"daily after the hour", models.DigestDaily, time.UTC,
+			time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)},
+		{"daily before the hour", models.DigestDaily, time.UTC,
+			time.Date(2026, 3, 4, 7, 59, 0, 0, time.UTC), time.Date(2026, 3, 3, 8, 0, 0, 0, time.UTC)},
+		{"daily at the hour", models.DigestDaily, time.UTC,
+			time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC), time.Date(2026, 3, 4, 8, 0, 0, 0, time.UTC)},
+		{"daily in the user's time zone", models.DigestDaily, kathmandu,
+			time.Date(2026, 3, 4, 2, 30, 0, 0, time.UTC), time.Date(2026, 3, 4, 8, 0, 0, 0, kathmandu)},
+		{"daily before the local hour", models.DigestDaily, kathmandu,
+			time.Date(2026, 3, 4, 2, 0, 0, 0, time.UTC), time.Date(2026, 3, 3, 8, 0, 0, 0, kathmandu)},
+		{"weekly mid-week", models.DigestWeekly, time.UTC,
+			time.Date(2026, 3, 4, 9, 0, 0, 0, time.UTC), time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)},
+		{"weekly on the day before the hour", models.DigestWeekly, time.UTC,
+			time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC), time.Date(2026, 2, 23, 8, 0, 0, 0, time.UTC)},
+		{"weekly on the day after the hour", models.DigestWeekly, time.UTC,
+			time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC), time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Slot(tt.frequency, tt.loc, 8, time.Monday, tt.now)
+			assert.True(t, tt.want.Equal(got), "want %s, got %s", tt.want, got)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := &Digest{
+		Name:        "Ram <script>",
+		Frequency:   models.DigestWeekly,
+		Since:       time.Date(2026, 2, 23, 8, 0, 0, 0, time.UTC),
+		FrontendURL: "https://dhukuti.example",
+		Votes:       []Vote{{CircleID: 1, Circle: "Family", Subject: "Sita wants to join"}},
+		Dues: []Due{
+			{CircleID: 1, Circle: "Family", Amount: 5000, DueDate: time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)},
+			{CircleID: 2, Circle: "Friends", Amount: 2000, DueDate: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), Outstanding: 2},
+		},
+		Contributions: []Contribution{
+			{CircleID: 1, Circle: "Family", Member: "Hari", Amount: 5000, CreatedAt: time.Date(2026, 2, 25, 10, 0, 0, 0, time.UTC)},
+		},
+		MoreContributions: 3,
+	}
+	assert.False(t, d.IsEmpty())
+	assert.Equal(t, "Your weekly Dhukuti digest", d.Subject())
+
+	text, html, err := d.Render()
+	require.NoError(t, err)
+
+	for _, want := range []string{
+		"since Mon 23 Feb",
+		"- Family: Sita wants to join\n  https://dhukuti.example/circles/1",
+		"- Family: 5000 due on Thu 5 Mar",
+		"- Friends: 2000 was due on Sun 1 Feb (2 unpaid)",
+		"- Family: Hari paid 5000 on Wed 25 Feb",
+		"- and 3 more",
+	} {
+		assert.Contains(t, text, want)
+	}
+
+	assert.Contains(t, html, `<a href="https://dhukuti.example/circles/1">Family</a>: Sita wants to join`)
+	assert.Contains(t, html, "Ram &lt;script&gt;")
+	assert.False(t, strings.Contains(html, "<script>"))
+
+	assert.True(t, (&Digest{Frequency: models.DigestDaily}).IsEmpty())
+}
@@ -0,0 +1,325 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/reminder"
+	"gorm.io/gorm"
+)
+
+// batchSize is the number of users whose digests are built together
+const batchSize = 200
+
+// Job sends the digests that are due
+type Job struct {
+	db          *gorm.DB
+	mailer      mailer.Mailer
+	cfg         config.DigestConfig
+	frontendURL string
+}
+
+// NewJob creates a digest job
+func NewJob(db *gorm.DB, m mailer.Mailer, cfg config.DigestConfig, frontendURL string) *Job {
+	return &Job{db: db, mailer: m, cfg: cfg, frontendURL: frontendURL}
+}
+
+// Run sends due digests every interval until ctx is cancelled
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if sent, err := j.RunOnce(ctx, time.Now()); err != nil {
+			slog.Error("Digest run failed", "error", err)
+		} else if sent > 0 {
+			slog.Info("Sent digests", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recipient is a user whose digest is due
+type recipient struct {
+	user  models.User
+	loc   *time.Location
+	slot  time.Time // when the digest is scheduled
+	since time.Time
+	until time.Time
+}
+
+// RunOnce sends the digests due at now and returns how many were sent
+func (j *Job) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	sent := 0
+	var users []models.User
+	err := j.db.WithContext(ctx).
+		Where("digest_frequency IN ? AND email_verified_at IS NOT NULL AND anonymized_at IS NULL",
+			[]string{models.DigestDaily, models.DigestWeekly}).
+		FindInBatches(&users, batchSize, func(tx *gorm.DB, _ int) error {
+			var due []recipient
+			for _, user := range users {
+				loc := user.Location()
+				slot := Slot(user.DigestFrequency, loc, j.cfg.Hour, j.cfg.Weekday, now)
+				if user.DigestSentAt != nil && !user.DigestSentAt.Before(slot) {
+					continue
+				}
+				since, until := period(user.DigestFrequency, slot)
+				due = append(due, recipient{user: user, loc: loc, slot: slot, since: since, until: until})
+			}
+			if len(due) == 0 {
+				return nil
+			}
+
+			digests, err := j.build(ctx, due, now)
+			if err != nil {
+				return err
+			}
+			for _, r := range due {
+				ok, err := j.send(ctx, r, digests[r.user.ID])
+				if err != nil {
+					slog.Error("Failed to send digest", "user_id", r.user.ID, "error", err)
+					continue
+				}
+				if ok {
+					sent++
+				}
+			}
+			return nil
+		}).Error
+	return sent, err
+}
+
+// send claims a user's digest and emails it. Empty digests are claimed but
+// not sent. A digest that fails to send is released for the next run.
+func (j *Job) send(ctx context.Context, r recipient, d *Digest) (bool, error) {
+	db := j.db.WithContext(ctx)
+
+	claim := db.Model(&models.User{}).
+		Where("id = ? AND (digest_sent_at IS NULL OR digest_sent_at < ?)", r.user.ID, r.slot).
+		Update("digest_sent_at", r.slot)
+	if claim.Error != nil {
+		return false, claim.Error
+	}
+	if claim.RowsAffected == 0 || d.IsEmpty() {
+		return false, nil // sent by another instance, or nothing to say
+	}
+
+	text, html, err := d.Render()
+	if err == nil {
+		err = j.mailer.Send(mailer.Message{
+			To:      r.user.Email,
+			Subject: d.Subject(),
+			Body:    text,
+			HTML:    html,
+		})
+	}
+	if err != nil {
+		release := db.Model(&models.User{}).
+			Where("id = ? AND digest_sent_at = ?", r.user.ID, r.slot).
+			Update("digest_sent_at", r.user.DigestSentAt)
+		if release.Error != nil {
+			err = fmt.Errorf("%w (and failed to release it: %v)", err, release.Error)
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// build gathers the digests of a batch of users with one query per kind of
+// item rather than per user
+func (j *Job) build(ctx context.Context, due []recipient, now time.Time) (map[uint]*Digest, error) {
+	db := j.db.WithContext(ctx)
+
+	userIDs := make([]uint, len(due))
+	digests := make(map[uint]*Digest, len(due))
+	oldest := now
+	for i, r := range due {
+		userIDs[i] = r.user.ID
+		digests[r.user.ID] = &Digest{
+			Name:        r.user.Name,
+			Frequency:   r.user.DigestFrequency,
+			Since:       r.since,
+			FrontendURL: j.frontendURL,
+		}
+		if r.since.Before(oldest) {
+			oldest = r.since
+		}
+	}
+
+	// Batch fetch active memberships with the number of contributions paid
+	var memberships []struct {
+		CircleID uint
+		UserID   uint
+		JoinedAt time.Time
+		Paid     int
+	}
+	if err := db.Table("circle_members").
+		Select("circle_members.circle_id, circle_members.user_id, circle_members.created_at AS joined_at, "+
+			"(SELECT COUNT(*) FROM contributions WHERE contributions.circle_id = circle_members.circle_id "+
+			"AND contributions.user_id = circle_members.user_id AND contributions.deleted_at IS NULL) AS paid").
+		Joins("JOIN circles ON circles.id = circle_members.circle_id AND circles.deleted_at IS NULL").
+		Where("circle_members.user_id IN ? AND circle_members.status = ? AND circle_members.deleted_at IS NULL", userIDs, "active").
+		Scan(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	// Batch fetch pending member votes
+	var memberVotes []models.MemberApproval
+	if err := db.Where("approver_user_id IN ? AND approved = ?", userIDs, false).
+		Order("created_at").Find(&memberVotes).Error; err != nil {
+		return nil, err
+	}
+
+	// Batch fetch pending amount votes
+	var amountVotes []models.AmountApproval
+	if err := db.Where("approver_id IN ? AND approved = ?", userIDs, false).
+		Order("created_at").Find(&amountVotes).Error; err != nil {
+		return nil, err
+	}
+
+	// Batch fetch the circles involved
+	circleSet := make(map[uint]struct{})
+	for _, m := range memberships {
+		circleSet[m.CircleID] = struct{}{}
+	}
+	for _, v := range memberVotes {
+		circleSet[v.CircleID] = struct{}{}
+	}
+	for _, v := range amountVotes {
+		circleSet[v.CircleID] = struct{}{}
+	}
+	circleIDs := make([]uint, 0, len(circleSet))
+	for id := range circleSet {
+		circleIDs = append(circleIDs, id)
+	}
+	circleMap := make(map[uint]models.Circle, len(circleIDs))
+	if len(circleIDs) > 0 {
+		var circles []models.Circle
+		if err := db.Where("id IN ?", circleIDs).Find(&circles).Error; err != nil {
+			return nil, err
+		}
+		for _, circle := range circles {
+			circleMap[circle.ID] = circle
+		}
+	}
+
+	// Batch fetch recent contributions in the users' circles
+	var contributions []models.Contribution
+	if len(circleIDs) > 0 {
+		if err := db.Where("circle_id IN ? AND created_at >= ?", circleIDs, oldest).
+			Order("created_at DESC").Find(&contributions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// Batch fetch the names of applicants and contributors
+	nameSet := make(map[uint]struct{})
+	for _, v := range memberVotes {
+		nameSet[v.PendingUserID] = struct{}{}
+	}
+	for _, c := range contributions {
+		nameSet[c.UserID] = struct{}{}
+	}
+	names := make(map[uint]string, len(nameSet))
+	if len(nameSet) > 0 {
+		ids := make([]uint, 0, len(nameSet))
+		for id := range nameSet {
+			ids = append(ids, id)
+		}
+		var people []models.User
+		if err := db.Select("id, name").Where("id IN ?", ids).Find(&people).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range people {
+			names[p.ID] = p.Name
+		}
+	}
+
+	// Assemble each user's digest
+	windows := make(map[uint]recipient, len(due))
+	for _, r := range due {
+		windows[r.user.ID] = r
+	}
+	for _, v := range memberVotes {
+		circle, ok := circleMap[v.CircleID]
+		if !ok {
+			continue
+		}
+		d := digests[v.ApproverUserID]
+		d.Votes = append(d.Votes, Vote{
+			CircleID: circle.ID,
+			Circle:   circle.Name,
+			Subject:  names[v.PendingUserID] + " wants to join",
+		})
+	}
+	for _, v := range amountVotes {
+		circle, ok := circleMap[v.CircleID]
+		if !ok {
+			continue
+		}
+		d := digests[v.ApproverID]
+		d.Votes = append(d.Votes, Vote{
+			CircleID: circle.ID,
+			Circle:   circle.Name,
+			Subject:  fmt.Sprintf("change the contribution from %d to %d", circle.AmountPerMember, v.ProposedAmount),
+		})
+	}
+
+	memberOf := make(map[uint][]uint) // circle ID to user IDs
+	for _, m := range memberships {
+		circle, ok := circleMap[m.CircleID]
+		if !ok {
+			continue
+		}
+		memberOf[m.CircleID] = append(memberOf[m.CircleID], m.UserID)
+
+		next := reminder.NextDue(m.JoinedAt, circle.PaymentDueDay, m.Paid, now)
+		if !next.Overdue() && !next.DueDate.Before(windows[m.UserID].until) {
+			continue // not due before the next digest
+		}
+		d := digests[m.UserID]
+		d.Dues = append(d.Dues, Due{
+			CircleID:    circle.ID,
+			Circle:      circle.Name,
+			Amount:      circle.AmountPerMember,
+			DueDate:     next.DueDate,
+			Outstanding: next.Outstanding,
+		})
+	}
+
+	for _, c := range contributions {
+		circle := circleMap[c.CircleID]
+		for _, userID := range memberOf[c.CircleID] {
+			if c.CreatedAt.Before(windows[userID].since) {
+				continue
+			}
+			d := digests[userID]
+			if len(d.Contributions) == maxContributions {
+				d.MoreContributions++
+				continue
+			}
+			d.Contributions = append(d.Contributions, Contribution{
+				CircleID:  circle.ID,
+				Circle:    circle.Name,
+				Member:    names[c.UserID],
+				Amount:    c.Amount,
+				CreatedAt: c.CreatedAt.In(windows[userID].loc),
+			})
+		}
+	}
+
+	for _, d := range digests {
+		sort.Slice(d.Dues, func(a, b int) bool { return d.Dues[a].DueDate.Before(d.Dues[b].DueDate) })
+	}
+	return digests, nil
+}
@@ -0,0 +1,80 @@
+// Package events defines the circle events published to integrations
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Type identifies what happened
+type Type string
+
+// Event catalogue
+const (
+	MemberAdded          Type = "member_added"          // a user was invited and awaits approval
+	MemberApproved       Type = "member_approved"       // all members approved an invitee, who is now active
+	VoteCast             Type = "vote_cast"             // a member voted on an invitee or a proposed amount
+	ContributionRecorded Type = "contribution_recorded" // a member recorded a contribution
+	AmountProposed       Type = "amount_proposed"       // an admin proposed a new contribution amount
+	AmountChanged        Type = "amount_changed"        // all members approved the proposed amount
+)
+
+// Types lists every event type
+var Types = []Type{MemberAdded, MemberApproved, VoteCast, ContributionRecorded, AmountProposed, AmountChanged}
+
+// IsValid reports whether t is in the catalogue
+func (t Type) IsValid() bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is something that happened in a circle
+type Event struct {
+	ID         string                 `json:"id"`
+	Type       Type                   `json:"type"`
+	CircleID   uint                   `json:"circle_id"`
+	ActorID    uint                   `json:"actor_id,omitempty"` // the user who caused the event, if any
+	OccurredAt time.Time              `json:"occurred_at"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// New creates an event with a fresh ID
+func New(eventType Type, circleID, actorID uint, data map[string]interface{}) Event {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return Event{
+		ID:         "evt_" + hex.EncodeToString(b),
+		Type:       eventType,
+		CircleID:   circleID,
+		ActorID:    actorID,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+// Uint reads a numeric field from the event's data, which holds float64
+// after a round trip through JSON. It is 0 when the field is missing.
+func (e Event) Uint(key string) uint {
+	switch v := e.Data[key].(type) {
+	case float64:
+		return uint(v)
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	}
+	return 0
+}
+
+// Publisher records events. tx is the transaction making the change the
+// event describes, so the event is only recorded if the change commits.
+type Publisher interface {
+	Publish(tx *gorm.DB, event Event) error
+}
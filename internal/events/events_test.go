@@ -0,0 +1,26 @@
+package events
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUintAfterJSONRoundTrip(t *testing.T) {
+	event := New(AmountChanged, 1, 2, map[string]interface{}{
+		"amount":  uint(150),
+		"user_id": 7,
+	})
+	assert.Equal(t, uint(150), event.Uint("amount"))
+
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+	var decoded Event
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+
+	assert.Equal(t, uint(150), decoded.Uint("amount"))
+	assert.Equal(t, uint(7), decoded.Uint("user_id"))
+	assert.Equal(t, uint(0), decoded.Uint("missing"))
+}
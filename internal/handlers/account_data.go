@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/reminder"
+	"github.com/Sudan23/dhukuti/internal/repository"
+	"github.com/Sudan23/dhukuti/internal/service"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errSoleAdmin stops an account deletion that would leave circles with
+// members but no admin
+var errSoleAdmin = errors.New("sole admin of a circle with other members")
+
+// DataExport is the personal data bundle returned by ExportData
+type DataExport struct {
+	ExportedAt    time.Time             `json:"exported_at"`
+	Profile       ExportProfile         `json:"profile"`
+	Memberships   []ExportMembership    `json:"memberships"`
+	Contributions []ExportContribution  `json:"contributions"`
+	Votes         ExportVotes           `json:"votes"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+// ExportProfile is the user's profile as stored
+type ExportProfile struct {
+	ID               uint       `json:"id"`
+	Email            string     `json:"email"`
+	Name             string     `json:"name"`
+	AvatarURL        string     `json:"avatar_url,omitempty"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	Phone            string     `json:"phone,omitempty"`
+	PhoneVerifiedAt  *time.Time `json:"phone_verified_at,omitempty"`
+	DigestFrequency  string     `json:"digest_frequency"`
+	TimeZone         string     `json:"time_zone"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// ExportMembership is a circle the user belongs to
+type ExportMembership struct {
+	CircleID   uint      `json:"circle_id"`
+	CircleName string    `json:"circle_name"`
+	Role       string    `json:"role"`
+	Status     string    `json:"status"`
+	JoinedAt   time.Time `json:"joined_at"`
+}
+
+// ExportContribution is a contribution recorded by the user
+type ExportContribution struct {
+	ID         uint      `json:"id"`
+	CircleID   uint      `json:"circle_id"`
+	CircleName string    `json:"circle_name"`
+	Amount     uint      `json:"amount"`
+	Month      time.Time `json:"month"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ExportVotes holds the votes the user has cast or been asked to cast
+type ExportVotes struct {
+	Members []ExportMemberVote `json:"members"`
+	Amounts []ExportAmountVote `json:"amounts"`
+}
+
+// ExportMemberVote is a vote on admitting a new member
+type ExportMemberVote struct {
+	CircleID      uint      `json:"circle_id"`
+	PendingUserID uint      `json:"pending_user_id"`
+	Approved      bool      `json:"approved"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ExportAmountVote is a vote on a proposed contribution amount
+type ExportAmountVote struct {
+	CircleID       uint      `json:"circle_id"`
+	ProposedAmount uint      `json:"proposed_amount"`
+	Approved       bool      `json:"approved"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CircleBalance describes the contributions a member still owes a circle
+type CircleBalance struct {
+	CircleID    uint      `json:"circle_id"`
+	CircleName  string    `json:"circle_name"`
+	Paid        int       `json:"paid"`        // contributions recorded
+	Outstanding int       `json:"outstanding"` // unpaid contributions past their due date
+	DueDate     time.Time `json:"due_date"`    // when the earliest unpaid contribution was due
+}
+
+// DeleteAccountRequest represents an account deletion request
+type DeleteAccountRequest struct {
+	Password     string `json:"password"` // not needed for accounts without a password
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// ExportData returns the user's personal data as a ZIP archive of JSON files,
+// or as a single JSON document with ?format=json
+func (h *ProfileHandler) ExportData(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	export, err := buildDataExport(c.Request.Context(), user)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to build data export", "error", err)
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to export data",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	filename := fmt.Sprintf("dhukuti-export-%d-%s", user.ID, export.ExportedAt.Format("20060102"))
+	if c.Query("format") == "json" {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"memberships.json", export.Memberships},
+		{"contributions.json", export.Contributions},
+		{"votes.json", export.Votes},
+		{"notifications.json", export.Notifications},
+	}
+	for _, file := range files {
+		w, err := archive.Create(file.name)
+		if err == nil {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(file.data)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// DeleteAccount anonymises the user's account. Contributions are kept so
+// circle history stays intact, and are shown as made by a former member.
+func (h *ProfileHandler) DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmPassword(user, req.Password) {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
+		return
+	}
+
+	if user.IsTwoFactorEnabled() {
+		valid, err := verifySecondFactor(c.Request.Context(), user, req.Code, req.RecoveryCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+			return
+		}
+		if !valid {
+			c.JSON(http.StatusUnauthorized, models.ErrInvalidTwoFactorCode)
+			return
+		}
+	}
+
+	balances, err := unsettledBalances(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to check balances",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+	if len(balances) > 0 {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Settle your outstanding contributions before deleting your account",
+			models.ErrCodeConflict,
+		).WithDetails(map[string]interface{}{"balances": balances}))
+		return
+	}
+
+	avatar := user.AvatarURL
+	var soleAdminCircles []uint
+	err = requestDB(c).Transaction(func(tx *gorm.DB) error {
+		// Votes and roles in the user's circles wait until they have left;
+		// locking in ID order keeps two deletions from deadlocking
+		repo := repository.NewGorm(tx, h.events)
+		var circleIDs []uint
+		if err := tx.Model(&models.CircleMember{}).Where("user_id = ?", user.ID).
+			Order("circle_id").Pluck("circle_id", &circleIDs).Error; err != nil {
+			return err
+		}
+		var circles []*models.Circle
+		for _, circleID := range circleIDs {
+			circle, err := repo.Circles().Lock(circleID)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			circles = append(circles, circle)
+		}
+
+		// Circles must not be left without an admin
+		if err := tx.Raw(`
+			SELECT cm.circle_id FROM circle_members cm
+			WHERE cm.user_id = ? AND cm.role = 'admin' AND cm.deleted_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM circle_members other
+				WHERE other.circle_id = cm.circle_id AND other.user_id <> cm.user_id
+				AND other.role = 'admin' AND other.status = 'active' AND other.deleted_at IS NULL
+			)
+			AND EXISTS (
+				SELECT 1 FROM circle_members other
+				WHERE other.circle_id = cm.circle_id AND other.user_id <> cm.user_id AND other.deleted_at IS NULL
+			)`, user.ID).Scan(&soleAdminCircles).Error; err != nil {
+			return err
+		}
+		if len(soleAdminCircles) > 0 {
+			return errSoleAdmin
+		}
+
+		// Pending applicants may have been waiting only on this user's vote
+		var affectedApprovals []models.MemberApproval
+		if err := tx.Where("approver_user_id = ? AND approved = ?", user.ID, false).Find(&affectedApprovals).Error; err != nil {
+			return err
+		}
+
+		if err := anonymizeUser(tx, user); err != nil {
+			return err
+		}
+
+		// Leave all circles and withdraw from outstanding votes
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.CircleMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("approver_user_id = ? OR pending_user_id = ?", user.ID, user.ID).Delete(&models.MemberApproval{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("approver_id = ?", user.ID).Delete(&models.AmountApproval{}).Error; err != nil {
+			return err
+		}
+
+		for _, approval := range affectedApprovals {
+			if err := service.CompleteApproval(repo, approval.CircleID, approval.PendingUserID, user.ID); err != nil {
+				return err
+			}
+		}
+		// Likewise a proposed amount may have been waiting on this user
+		for _, circle := range circles {
+			if err := service.CompleteAmountChange(c.Request.Context(), repo, circle, user.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errSoleAdmin) {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Make another member an admin of your circles before deleting your account",
+			models.ErrCodeConflict,
+		).WithDetails(map[string]interface{}{"circle_ids": soleAdminCircles}))
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to delete account", "error", err)
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to delete account",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	if strings.HasPrefix(avatar, "/uploads/avatars/") {
+		os.Remove(filepath.Join(h.cfg.Storage.UploadDir, "avatars", filepath.Base(avatar)))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Your account has been deleted"})
+}
+
+// anonymizeUser strips personal data from the user row while keeping the ID,
+// so contributions referencing it stay valid
+func anonymizeUser(tx *gorm.DB, user *models.User) error {
+	now := time.Now()
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"email":             fmt.Sprintf("deleted-user-%d@deleted.invalid", user.ID),
+		"name":              models.FormerMemberName,
+		"password":          models.NoPassword,
+		"avatar_url":        "",
+		"email_verified_at": nil,
+		"phone":             "",
+		"phone_verified_at": nil,
+		"digest_frequency":  models.DigestOff,
+		"totp_secret":       "",
+		"totp_enabled_at":   nil,
+		"locked_until":      nil,
+		"anonymized_at":     now,
+	}).Error; err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.AccountUnlockToken{},
+		&models.RecoveryCode{},
+		&models.ExternalIdentity{},
+		&models.PersonalAccessToken{},
+		&models.Webhook{},
+		&models.Notification{},
+		&models.NotificationPreference{},
+		&models.PaymentReminder{},
+		&models.PhoneVerification{},
+		&models.SMSNotification{},
+		&models.IdempotencyKey{},
+	} {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unsettledBalances returns the circles in which the user has contributions
+// past their due date, as the payment reminders count them
+func unsettledBalances(ctx context.Context, userID uint) ([]CircleBalance, error) {
+	var memberships []struct {
+		CircleID      uint
+		CircleName    string
+		PaymentDueDay int
+		JoinedAt      time.Time
+		Paid          int
+	}
+	if err := database.DB.WithContext(ctx).Table("circle_members").
+		Select("circle_members.circle_id, circles.name AS circle_name, circles.payment_due_day, circle_members.created_at AS joined_at, "+
+			"(SELECT COUNT(*) FROM contributions WHERE contributions.circle_id = circle_members.circle_id "+
+			"AND contributions.user_id = circle_members.user_id AND contributions.deleted_at IS NULL) AS paid").
+		Joins("JOIN circles ON circles.id = circle_members.circle_id AND circles.deleted_at IS NULL").
+		Where("circle_members.user_id = ? AND circle_members.status = ? AND circle_members.deleted_at IS NULL", userID, "active").
+		Scan(&memberships).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var balances []CircleBalance
+	for _, m := range memberships {
+		due := reminder.NextDue(m.JoinedAt, m.PaymentDueDay, m.Paid, now)
+		if !due.Overdue() {
+			continue
+		}
+		balances = append(balances, CircleBalance{
+			CircleID:    m.CircleID,
+			CircleName:  m.CircleName,
+			Paid:        m.Paid,
+			Outstanding: due.Outstanding,
+			DueDate:     due.DueDate,
+		})
+	}
+	return balances, nil
+}
+
+// buildDataExport gathers everything stored about the user
+func buildDataExport(ctx context.Context, user *models.User) (*DataExport, error) {
+	db := database.DB.WithContext(ctx)
+	export := &DataExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			ID:               user.ID,
+			Email:            user.Email,
+			Name:             user.Name,
+			AvatarURL:        user.AvatarURL,
+			EmailVerifiedAt:  user.EmailVerifiedAt,
+			Phone:            user.Phone,
+			PhoneVerifiedAt:  user.PhoneVerifiedAt,
+			DigestFrequency:  user.DigestFrequency,
+			TimeZone:         user.TimeZone,
+			TwoFactorEnabled: user.IsTwoFactorEnabled(),
+			CreatedAt:        user.CreatedAt,
+			UpdatedAt:        user.UpdatedAt,
+		},
+		Memberships:   []ExportMembership{},
+		Contributions: []ExportContribution{},
+		Notifications: []models.Notification{},
+		Votes: ExportVotes{
+			Members: []ExportMemberVote{},
+			Amounts: []ExportAmountVote{},
+		},
+	}
+
+	if err := db.Table("circle_members").
+		Select("circle_members.circle_id, circles.name AS circle_name, circle_members.role, circle_members.status, circle_members.created_at AS joined_at").
+		Joins("JOIN circles ON circles.id = circle_members.circle_id").
+		Where("circle_members.user_id = ? AND circle_members.deleted_at IS NULL", user.ID).
+		Order("circle_members.created_at").
+		Scan(&export.Memberships).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Table("contributions").
+		Select("contributions.id, contributions.circle_id, circles.name AS circle_name, contributions.amount, contributions.month, contributions.created_at").
+		Joins("JOIN circles ON circles.id = contributions.circle_id").
+		Where("contributions.user_id = ? AND contributions.deleted_at IS NULL", user.ID).
+		Order("contributions.created_at").
+		Scan(&export.Contributions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&models.MemberApproval{}).
+		Where("approver_user_id = ?", user.ID).
+		Order("created_at").
+		Scan(&export.Votes.Members).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Model(&models.AmountApproval{}).
+		Where("approver_id = ?", user.ID).
+		Order("created_at").
+		Scan(&export.Votes.Amounts).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("user_id = ?", user.ID).
+		Order("created_at").
+		Find(&export.Notifications).Error; err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UnlockAccountRequest represents the unlock account request body
+type UnlockAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UnlockAccount lifts a lockout using the token from the lockout email
+func (h *AuthHandler) UnlockAccount(c *gin.Context) {
+	var req UnlockAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	var token models.AccountUnlockToken
+	if err := requestDB(c).Where("token = ?", hashToken(req.Token)).First(&token).Error; err != nil || !token.IsValid() {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid or expired unlock token",
+			models.ErrCodeInvalidToken,
+		))
+		return
+	}
+
+	var user models.User
+	if err := requestDB(c).First(&user, token.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrUserNotFound)
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("locked_until", nil).Error; err != nil {
+			return err
+		}
+		return tx.Model(&token).Update("used", true).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to unlock account",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	accountKey, _ := loginKeys(user.Email, "")
+	if err := h.limiter.Succeed(c.Request.Context(), accountKey); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to reset login failures", "target_user_id", user.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Your account has been unlocked. You can now log in.",
+	})
+}
+
+// loginKeys returns the rate limit keys tracking failed logins for an
+// account and for a client IP
+func loginKeys(email, ip string) (accountKey, ipKey string) {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email)), "login:ip:" + ip
+}
+
+// recordLoginFailure counts a failed login against the account and the client
+// IP, locking the account once the lockout threshold is reached. user is nil
+// when the email did not match an account.
+func (h *AuthHandler) recordLoginFailure(c *gin.Context, user *models.User, accountKey, ipKey string) {
+	ctx := c.Request.Context()
+
+	if _, err := h.limiter.Fail(ctx, ipKey); err != nil {
+		logging.FromContext(ctx).Error("Failed to record login failure", "limit_key", ipKey, "error", err)
+	}
+
+	failures, err := h.limiter.Fail(ctx, accountKey)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to record login failure", "limit_key", accountKey, "error", err)
+		return
+	}
+
+	threshold := h.cfg.RateLimit.LockoutThreshold
+	if user == nil || threshold <= 0 || failures < threshold || user.IsLocked() {
+		return
+	}
+
+	if err := lockAccount(ctx, h.cfg.App.FrontendURL, h.cfg.RateLimit.LockoutDuration, h.mailer, user); err != nil {
+		logging.FromContext(ctx).Error("Failed to lock account", "target_user_id", user.ID, "error", err)
+	}
+}
+
+// lockAccount locks the user out for the given duration and emails them a
+// link to unlock the account early
+func lockAccount(ctx context.Context, frontendURL string, duration time.Duration, m mailer.Mailer, user *models.User) error {
+	db := database.DB.WithContext(ctx)
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate unlock token: %w", err)
+	}
+
+	lockedUntil := time.Now().Add(duration)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("locked_until", lockedUntil).Error; err != nil {
+			return err
+		}
+
+		// Invalidate any existing unlock tokens for this user
+		if err := tx.Model(&models.AccountUnlockToken{}).
+			Where("user_id = ? AND used = ?", user.ID, false).
+			Update("used", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.AccountUnlockToken{
+			UserID:    user.ID,
+			Token:     hashToken(token),
+			ExpiresAt: lockedUntil,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Dhukuti account has been locked",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe locked your account after several failed sign-in attempts. "+
+				"It will unlock automatically at %s.\n\nIf this was you, unlock it now:\n\n%s/unlock-account?token=%s\n\n"+
+				"If it wasn't you, consider resetting your password.\n",
+			user.Name, lockedUntil.Format(time.RFC1123), frontendURL, token,
+		),
+	})
+}
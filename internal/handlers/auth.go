@@ -1,29 +1,39 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/Sudan23/dhukuti/internal/config"
-	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
 	"github.com/Sudan23/dhukuti/internal/middleware"
 	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/Sudan23/dhukuti/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthHandler handles authentication operations
 type AuthHandler struct {
-	cfg *config.Config
+	cfg     *config.Config
+	mailer  mailer.Mailer
+	limiter *ratelimit.Limiter
+	policy  *password.Policy
+	auth    service.Auth
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(cfg *config.Config) *AuthHandler {
-	return &AuthHandler{cfg: cfg}
+func NewAuthHandler(cfg *config.Config, m mailer.Mailer, limiter *ratelimit.Limiter, policy *password.Policy, auth service.Auth) *AuthHandler {
+	return &AuthHandler{cfg: cfg, mailer: m, limiter: limiter, policy: policy, auth: auth}
 }
 
 // RegisterRequest represents a registration request
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Name     string `json:"name" binding:"required"`
 }
 
@@ -39,11 +49,25 @@ type AuthResponse struct {
 	User  UserResponse `json:"user"`
 }
 
+// TwoFactorChallengeResponse is returned by Login when the account has
+// two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int    `json:"expires_in"` // seconds
+}
+
 // UserResponse represents a user in responses
 type UserResponse struct {
-	ID    uint   `json:"id"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	ID               uint   `json:"id"`
+	Email            string `json:"email"`
+	Name             string `json:"name"`
+	AvatarURL        string `json:"avatar_url,omitempty"`
+	EmailVerified    bool   `json:"email_verified"`
+	Phone            string `json:"phone,omitempty"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled"`
+	DigestFrequency  string `json:"digest_frequency"`
+	TimeZone         string `json:"time_zone"`
 }
 
 // Register handles user registration
@@ -54,31 +78,20 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Check if user already exists
-	var existingUser models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, models.NewErrorResponse(
-			"User with this email already exists",
-			models.ErrCodeAlreadyExists,
-		))
+	if !checkPasswordPolicy(c, h.policy, req.Password, req.Email) {
 		return
 	}
 
-	// Create new user
-	user := models.User{
-		Email: req.Email,
-		Name:  req.Name,
-	}
-
-	if err := user.HashPassword(req.Password); err != nil {
-		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
-			"Failed to hash password",
-			models.ErrCodeInternal,
+	user, err := h.auth.Register(c.Request.Context(), req.Email, req.Name, req.Password)
+	if errors.Is(err, service.ErrEmailTaken) {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"User with this email already exists",
+			models.ErrCodeAlreadyExists,
 		))
 		return
 	}
-
-	if err := database.DB.Create(&user).Error; err != nil {
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to create user", "error", err)
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to create user",
 			models.ErrCodeDatabase,
@@ -86,6 +99,12 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// Send verification email; the account is usable even if this fails,
+	// since the user can request another one
+	if err := sendVerificationEmail(c.Request.Context(), h.cfg, h.mailer, user, user.Email); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to send verification email", "target_user_id", user.ID, "error", err)
+	}
+
 	// Generate JWT token
 	token, err := middleware.GenerateToken(user.ID, user.Email, h.cfg)
 	if err != nil {
@@ -98,11 +117,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, AuthResponse{
 		Token: token,
-		User: UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-		},
+		User:  newUserResponse(user),
 	})
 }
 
@@ -114,17 +129,52 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// Find user by email
-	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
+	// Back off clients and accounts with recent failed attempts
+	accountKey, ipKey := loginKeys(req.Email, c.ClientIP())
+	if wait, err := h.limiter.Blocked(c.Request.Context(), accountKey, ipKey); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to check login backoff", "error", err)
+	} else if wait > 0 {
+		middleware.TooManyRequests(c, wait, models.ErrTooManyRequests)
 		return
 	}
 
-	// Check password
-	if err := user.CheckPassword(req.Password); err != nil {
+	user, err := h.auth.Login(c.Request.Context(), req.Email, req.Password)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrAccountLocked):
+		middleware.TooManyRequests(c, time.Until(*user.LockedUntil), models.ErrAccountLocked)
+		return
+	case errors.Is(err, service.ErrInvalidCredentials):
+		h.recordLoginFailure(c, user, accountKey, ipKey)
 		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
 		return
+	default:
+		logging.FromContext(c.Request.Context()).Error("Failed to look up user", "error", err)
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	if err := h.limiter.Succeed(c.Request.Context(), accountKey); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to reset login failures", "target_user_id", user.ID, "error", err)
+	}
+
+	// Accounts with 2FA get a short-lived challenge instead of a session token
+	if user.IsTwoFactorEnabled() {
+		challenge, err := middleware.GenerateChallengeToken(user.ID, user.Email, h.cfg)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to generate token",
+				models.ErrCodeInternal,
+			))
+			return
+		}
+
+		c.JSON(http.StatusOK, TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+			ExpiresIn:         int(h.cfg.JWT.ChallengeExpiry.Seconds()),
+		})
+		return
 	}
 
 	// Generate JWT token
@@ -139,10 +189,31 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	c.JSON(http.StatusOK, AuthResponse{
 		Token: token,
-		User: UserResponse{
-			ID:    user.ID,
-			Email: user.Email,
-			Name:  user.Name,
-		},
+		User:  newUserResponse(user),
 	})
 }
+
+// newUserResponse builds the public representation of a user
+func newUserResponse(user *models.User) UserResponse {
+	return UserResponse{
+		ID:               user.ID,
+		Email:            user.Email,
+		Name:             user.Name,
+		AvatarURL:        user.AvatarURL,
+		EmailVerified:    user.IsEmailVerified(),
+		Phone:            user.Phone,
+		TwoFactorEnabled: user.IsTwoFactorEnabled(),
+		DigestFrequency:  user.DigestFrequency,
+		TimeZone:         user.TimeZone,
+	}
+}
+
+// checkPasswordPolicy validates a new password, writing a 400 response if it
+// is rejected
+func checkPasswordPolicy(c *gin.Context, policy *password.Policy, plain, email string) bool {
+	if err := policy.Validate(plain, email); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return false
+	}
+	return true
+}
@@ -1,12 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
-	"github.com/Sudan23/dhukuti/internal/database"
 	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
@@ -39,19 +40,12 @@ func (h *CircleHandler) GetContributions(c *gin.Context) {
 		return
 	}
 
-	// Verify user is a member of the circle
-	var membership models.CircleMember
-	if err := database.DB.Where("circle_id = ? AND user_id = ?", circleID, userID).
-		First(&membership).Error; err != nil {
+	contributions, err := h.contributions.List(c.Request.Context(), uint(circleID), userID.(uint))
+	if errors.Is(err, service.ErrNotMember) {
 		c.JSON(http.StatusForbidden, models.ErrCircleNotFound)
 		return
 	}
-
-	// Fetch all contributions for this circle with user details
-	var contributions []models.Contribution
-	if err := database.DB.Where("circle_id = ?", circleID).
-		Order("created_at DESC").
-		Find(&contributions).Error; err != nil {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to fetch contributions",
 			models.ErrCodeDatabase,
@@ -59,37 +53,14 @@ func (h *CircleHandler) GetContributions(c *gin.Context) {
 		return
 	}
 
-	// Get all unique user IDs
-	userIDs := make([]uint, 0)
-	userIDMap := make(map[uint]bool)
-	for _, contrib := range contributions {
-		if !userIDMap[contrib.UserID] {
-			userIDs = append(userIDs, contrib.UserID)
-			userIDMap[contrib.UserID] = true
-		}
-	}
-
-	// Batch fetch user details
-	var users []models.User
-	if len(userIDs) > 0 {
-		database.DB.Where("id IN ?", userIDs).Find(&users)
-	}
-
-	// Create user lookup map
-	userMap := make(map[uint]models.User)
-	for _, user := range users {
-		userMap[user.ID] = user
-	}
-
 	// Build response
 	response := make([]ContributionResponse, len(contributions))
 	for i, contrib := range contributions {
-		user := userMap[contrib.UserID]
 		response[i] = ContributionResponse{
 			ID:        contrib.ID,
 			UserID:    contrib.UserID,
-			UserName:  user.Name,
-			UserEmail: user.Email,
+			UserName:  contrib.UserName,
+			UserEmail: contrib.UserEmail,
 			Amount:    contrib.Amount,
 			Month:     contrib.Month,
 			CreatedAt: contrib.CreatedAt,
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// EmailVerificationHandler handles email verification operations
+type EmailVerificationHandler struct {
+	cfg    *config.Config
+	mailer mailer.Mailer
+}
+
+// NewEmailVerificationHandler creates a new email verification handler
+func NewEmailVerificationHandler(cfg *config.Config, m mailer.Mailer) *EmailVerificationHandler {
+	return &EmailVerificationHandler{cfg: cfg, mailer: m}
+}
+
+// VerifyEmailRequest represents the verify email request body
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail marks the user's email as verified using a valid token
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	var token models.EmailVerificationToken
+	if err := requestDB(c).Where("token = ?", hashToken(req.Token)).First(&token).Error; err != nil || !token.IsValid() {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Invalid or expired verification token",
+			models.ErrCodeInvalidToken,
+		))
+		return
+	}
+
+	var user models.User
+	if err := requestDB(c).First(&user, token.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrUserNotFound)
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if !user.IsEmailVerified() {
+		updates["email_verified_at"] = time.Now()
+	}
+
+	// Tokens issued for an email change carry the new address
+	previousEmail := user.Email
+	if token.Email != "" && token.Email != user.Email {
+		var existing models.User
+		if err := requestDB(c).Where("email = ?", token.Email).First(&existing).Error; err == nil {
+			c.JSON(http.StatusConflict, models.NewErrorResponse(
+				"User with this email already exists",
+				models.ErrCodeAlreadyExists,
+			))
+			return
+		}
+		updates["email"] = token.Email
+		updates["email_verified_at"] = time.Now()
+	}
+
+	if len(updates) > 0 {
+		if err := requestDB(c).Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to verify email",
+				models.ErrCodeDatabase,
+			))
+			return
+		}
+	}
+
+	// Let the old address know it is no longer attached to the account
+	if user.Email != previousEmail {
+		if err := h.mailer.Send(mailer.Message{
+			To:      previousEmail,
+			Subject: "Your Dhukuti email address was changed",
+			Body: fmt.Sprintf(
+				"Hi %s,\n\nThe email address on your account was changed to %s. If you didn't do this, reset your password and contact support.\n",
+				user.Name, user.Email,
+			),
+		}); err != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to notify previous email address", "target_user_id", user.ID, "error", err)
+		}
+	}
+
+	// Mark token as used
+	token.Used = true
+	requestDB(c).Save(&token)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Email address verified successfully.",
+	})
+}
+
+// ResendVerification sends a fresh verification email to the authenticated user
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+		return
+	}
+
+	var user models.User
+	if err := requestDB(c).First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrUserNotFound)
+		return
+	}
+
+	if user.IsEmailVerified() {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Email address is already verified",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	// Throttle resends based on the most recent token issued
+	var lastToken models.EmailVerificationToken
+	if err := requestDB(c).Where("user_id = ?", user.ID).Order("created_at DESC").First(&lastToken).Error; err == nil {
+		if wait := h.cfg.Mail.VerificationResendDelay - time.Since(lastToken.CreatedAt); wait > 0 {
+			middleware.TooManyRequests(c, wait, models.NewErrorResponse(
+				"Please wait before requesting another verification email",
+				models.ErrCodeRateLimited,
+			))
+			return
+		}
+	}
+
+	if err := sendVerificationEmail(c.Request.Context(), h.cfg, h.mailer, &user, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to send verification email",
+			models.ErrCodeExternal,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Verification email has been sent.",
+	})
+}
+
+// sendVerificationEmail issues a new verification token for the given address
+// and emails it there. The address differs from user.Email for email changes.
+func sendVerificationEmail(ctx context.Context, cfg *config.Config, m mailer.Mailer, user *models.User, email string) error {
+	db := database.DB.WithContext(ctx)
+	token, err := newToken()
+	if err != nil {
+		return fmt.Errorf("failed to generate verification token: %w", err)
+	}
+
+	// Invalidate any existing tokens for this user
+	db.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used = ?", user.ID, false).
+		Update("used", true)
+
+	verification := models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     email,
+		Token:     hashToken(token),
+		ExpiresAt: time.Now().Add(cfg.Mail.VerificationTokenExpiry),
+	}
+	if err := db.Create(&verification).Error; err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	return m.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your Dhukuti email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s/verify-email?token=%s\n\nThis link expires in %s.\n",
+			user.Name, cfg.App.FrontendURL, token, cfg.Mail.VerificationTokenExpiry,
+		),
+	})
+}
+
+// newToken returns a random hex-encoded token suitable for emailed links
+func newToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 digest of a random token.
+// Tokens carry 256 bits of entropy, so a fast hash is enough and lets us
+// look them up by index.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
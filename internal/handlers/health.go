@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Sudan23/dhukuti/internal/health"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the probes used by load balancers and orchestrators
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{checker: checker}
+}
+
+// Live answers the liveness probe: 503 means the instance should be restarted
+func (h *HealthHandler) Live(c *gin.Context) {
+	respondReport(c, h.checker.Live())
+}
+
+// Ready answers the readiness probe: 503 means the instance should get no
+// traffic for now
+func (h *HealthHandler) Ready(c *gin.Context) {
+	respondReport(c, h.checker.Ready(c.Request.Context()))
+}
+
+// respondReport writes a probe's report with its status code
+func respondReport(c *gin.Context, report health.Report) {
+	status := http.StatusOK
+	if !report.OK() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// Page sizes for ListNotifications
+const (
+	defaultNotificationLimit = 20
+	maxNotificationLimit     = 100
+)
+
+// NotificationHandler serves the authenticated user's notification centre
+type NotificationHandler struct{}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler() *NotificationHandler {
+	return &NotificationHandler{}
+}
+
+// NotificationListResponse is a page of notifications, newest first
+type NotificationListResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	UnreadCount   int64                 `json:"unread_count"`
+	NextBefore    *uint                 `json:"next_before"` // pass as ?before= for the next page; null on the last page
+}
+
+// UnreadCountResponse is the number of unread notifications, in total and per type
+type UnreadCountResponse struct {
+	Total  int64            `json:"total"`
+	ByType map[string]int64 `json:"by_type"`
+}
+
+// UpdatePreferencesRequest represents a request to change notification preferences
+type UpdatePreferencesRequest struct {
+	Preferences []struct {
+		Type  string `json:"type" binding:"required"`
+		InApp *bool  `json:"in_app" binding:"required"`
+	} `json:"preferences" binding:"required,min=1,dive"`
+}
+
+// ListNotifications returns the user's notifications, newest first.
+// ?unread=true returns only unread ones, ?limit= sets the page size and
+// ?before=<id> continues after the previous page.
+func (h *NotificationHandler) ListNotifications(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	limit := defaultNotificationLimit
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("limit must be between 1 and 100", models.ErrCodeValidation))
+			return
+		}
+		limit = n
+	}
+
+	query := requestDB(c).Where("user_id = ?", userID)
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if raw := c.Query("before"); raw != "" {
+		before, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid before cursor", models.ErrCodeInvalidInput))
+			return
+		}
+		query = query.Where("id < ?", before)
+	}
+
+	// Fetch one extra row to learn whether there is another page
+	var notifications []models.Notification
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch notifications", models.ErrCodeDatabase))
+		return
+	}
+
+	response := NotificationListResponse{Notifications: notifications}
+	if len(notifications) > limit {
+		response.Notifications = notifications[:limit]
+		response.NextBefore = &notifications[limit-1].ID
+	}
+
+	if err := requestDB(c).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&response.UnreadCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch notifications", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UnreadCount returns the number of unread notifications
+func (h *NotificationHandler) UnreadCount(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var rows []struct {
+		Type  string
+		Count int64
+	}
+	if err := requestDB(c).Model(&models.Notification{}).
+		Select("type, COUNT(*) AS count").
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Group("type").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to count notifications", models.ErrCodeDatabase))
+		return
+	}
+
+	response := UnreadCountResponse{ByType: make(map[string]int64, len(rows))}
+	for _, row := range rows {
+		response.ByType[row.Type] = row.Count
+		response.Total += row.Count
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// MarkRead marks one notification as read
+func (h *NotificationHandler) MarkRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid notification ID", models.ErrCodeInvalidInput))
+		return
+	}
+
+	var notification models.Notification
+	if err := requestDB(c).Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Notification not found", models.ErrCodeNotFound))
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := requestDB(c).Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update notification", models.ErrCodeDatabase))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllRead marks every unread notification as read
+func (h *NotificationHandler) MarkAllRead(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := requestDB(c).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update notifications", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"marked_read": result.RowsAffected})
+}
+
+// GetPreferences returns the user's setting for every notification type
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var stored []models.NotificationPreference
+	if err := requestDB(c).Where("user_id = ?", userID).Find(&stored).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch preferences", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"preferences": notificationPreferences(stored)})
+}
+
+// UpdatePreferences changes the user's setting for one or more notification types
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	var req UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	preferences := make([]models.NotificationPreference, 0, len(req.Preferences))
+	for _, p := range req.Preferences {
+		if !models.IsValidNotificationType(p.Type) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Unknown notification type: "+p.Type, models.ErrCodeValidation))
+			return
+		}
+		preferences = append(preferences, models.NotificationPreference{
+			UserID: userID.(uint),
+			Type:   p.Type,
+			InApp:  *p.InApp,
+		})
+	}
+
+	if err := requestDB(c).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+		DoUpdates: clause.AssignmentColumns([]string{"in_app", "updated_at"}),
+	}).Create(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to update preferences", models.ErrCodeDatabase))
+		return
+	}
+
+	var stored []models.NotificationPreference
+	requestDB(c).Where("user_id = ?", userID).Find(&stored)
+	c.JSON(http.StatusOK, gin.H{"preferences": notificationPreferences(stored)})
+}
+
+// notificationPreferences fills in defaults for types without a stored preference
+func notificationPreferences(stored []models.NotificationPreference) []models.NotificationPreference {
+	byType := make(map[string]models.NotificationPreference, len(stored))
+	for _, p := range stored {
+		byType[p.Type] = p
+	}
+
+	preferences := make([]models.NotificationPreference, 0, len(models.NotificationTypes))
+	for _, t := range models.NotificationTypes {
+		p, ok := byType[t]
+		if !ok {
+			p = models.NotificationPreference{Type: t, InApp: true}
+		}
+		preferences = append(preferences, p)
+	}
+	return preferences
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/oidc"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oidcStateExpiry bounds how long a user may take at the provider's login page
+const oidcStateExpiry = 10 * time.Minute
+
+// oidcStateCookie ties a login to the browser that started it, so a callback
+// URL sent to someone else can't log them into the sender's account
+const oidcStateCookie = "oidc_state"
+
+// Errors reported to the frontend in the callback redirect
+const (
+	oidcErrInvalidState    = "invalid_state"
+	oidcErrProvider        = "provider_error"
+	oidcErrEmailUnverified = "email_not_verified"
+	oidcErrAccountLocked   = "account_locked"
+	oidcErrServer          = "server_error"
+)
+
+// errOIDCEmailUnverified is returned when the provider does not vouch for the email
+var errOIDCEmailUnverified = errors.New("provider did not verify the email address")
+
+// OIDCHandler handles login through external OpenID Connect providers
+type OIDCHandler struct {
+	cfg       *config.Config
+	providers map[string]*oidc.Provider
+}
+
+// NewOIDCHandler creates a new OpenID Connect login handler
+func NewOIDCHandler(cfg *config.Config, providers map[string]*oidc.Provider) *OIDCHandler {
+	return &OIDCHandler{cfg: cfg, providers: providers}
+}
+
+// ListProviders returns the names of the configured login providers
+func (h *OIDCHandler) ListProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.cfg.OIDC.Providers))
+	for _, provider := range h.cfg.OIDC.Providers {
+		names = append(names, provider.Name)
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": names})
+}
+
+// Login starts the authorization code flow by redirecting to the provider
+func (h *OIDCHandler) Login(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Unknown login provider", models.ErrCodeNotFound))
+		return
+	}
+
+	state := models.OIDCState{
+		Provider:  provider.Name,
+		ExpiresAt: time.Now().Add(oidcStateExpiry),
+	}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+			return
+		}
+		*value = random
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state.State, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("OIDC provider unavailable", "provider", provider.Name, "error", err)
+		c.JSON(http.StatusBadGateway, models.NewErrorResponse(
+			"Login provider is unavailable",
+			models.ErrCodeExternal,
+		))
+		return
+	}
+
+	// Drop abandoned logins while we are here
+	requestDB(c).Where("expires_at < ?", time.Now()).Delete(&models.OIDCState{})
+
+	if err := requestDB(c).Create(&state).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to start login",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	setOIDCStateCookie(c, provider.Name, state.State, int(oidcStateExpiry.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the flow. It always redirects to the frontend's
+// /auth/callback page with either a session token, a two-factor challenge
+// or an error in the URL fragment.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	provider, ok := h.providers[c.Param("provider")]
+	if !ok {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Unknown login provider", models.ErrCodeNotFound))
+		return
+	}
+
+	// The state must come back to the browser that started the login; the
+	// cookie is single use like the state
+	cookie, err := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, provider.Name, "", -1)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.Query("state"))) != 1 {
+		h.redirectError(c, oidcErrInvalidState)
+		return
+	}
+
+	// States are single use: delete it before doing anything else
+	var state models.OIDCState
+	result := requestDB(c).Where("state = ? AND provider = ?", c.Query("state"), provider.Name).First(&state)
+	if result.Error != nil || c.Query("state") == "" {
+		h.redirectError(c, oidcErrInvalidState)
+		return
+	}
+	if result := requestDB(c).Delete(&state); result.Error != nil || result.RowsAffected == 0 || !state.IsValid() {
+		h.redirectError(c, oidcErrInvalidState)
+		return
+	}
+
+	if providerErr := c.Query("error"); providerErr != "" || c.Query("code") == "" {
+		logging.FromContext(c.Request.Context()).Warn("OIDC provider returned an error", "provider", provider.Name, "error", providerErr)
+		h.redirectError(c, oidcErrProvider)
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokens, err := provider.Exchange(ctx, c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("OIDC code exchange failed", "provider", provider.Name, "error", err)
+		h.redirectError(c, oidcErrProvider)
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, state.Nonce)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Warn("OIDC ID token rejected", "provider", provider.Name, "error", err)
+		h.redirectError(c, oidcErrProvider)
+		return
+	}
+
+	user, err := resolveExternalUser(c.Request.Context(), provider.Name, claims)
+	if errors.Is(err, errOIDCEmailUnverified) {
+		h.redirectError(c, oidcErrEmailUnverified)
+		return
+	}
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to resolve OIDC user", "provider", provider.Name, "subject", claims.Subject, "error", err)
+		h.redirectError(c, oidcErrServer)
+		return
+	}
+
+	if user.IsLocked() {
+		h.redirectError(c, oidcErrAccountLocked)
+		return
+	}
+
+	fragment := url.Values{}
+	if user.IsTwoFactorEnabled() {
+		challenge, err := middleware.GenerateChallengeToken(user.ID, user.Email, h.cfg)
+		if err != nil {
+			h.redirectError(c, oidcErrServer)
+			return
+		}
+		fragment.Set("challenge_token", challenge)
+		fragment.Set("expires_in", strconv.Itoa(int(h.cfg.JWT.ChallengeExpiry.Seconds())))
+	} else {
+		token, err := middleware.GenerateToken(user.ID, user.Email, h.cfg)
+		if err != nil {
+			h.redirectError(c, oidcErrServer)
+			return
+		}
+		fragment.Set("token", token)
+	}
+
+	h.redirect(c, fragment)
+}
+
+// setOIDCStateCookie stores a login's state in the browser for the provider's
+// callback, or clears it when maxAge is negative
+func setOIDCStateCookie(c *gin.Context, provider, state string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth/oidc/" + provider + "/callback",
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// resolveExternalUser finds the user for an external identity. Unknown
+// identities are linked to the local account with the same email, or to a
+// new account if there is none. Linking by email requires the provider to
+// have verified the address.
+func resolveExternalUser(ctx context.Context, provider string, claims *oidc.IDClaims) (*models.User, error) {
+	db := database.DB.WithContext(ctx)
+	var user models.User
+
+	var identity models.ExternalIdentity
+	err := db.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&identity).Error
+	if err == nil {
+		if err := db.First(&user, identity.UserID).Error; err != nil {
+			return nil, err
+		}
+		if user.IsAnonymized() {
+			return nil, errors.New("linked account has been deleted")
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || !claims.EmailVerified {
+		return nil, errOIDCEmailUnverified
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Where("LOWER(email) = LOWER(?)", email).First(&user).Error
+		switch {
+		case err == nil:
+			if !user.IsEmailVerified() {
+				// Nobody proved they own this address, so whoever chose the
+				// password may not be the owner; make them reset it
+				if err := tx.Model(&user).Updates(map[string]interface{}{
+					"email_verified_at": now,
+					"password":          models.NoPassword,
+				}).Error; err != nil {
+					return err
+				}
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			name := strings.TrimSpace(claims.Name)
+			if name == "" {
+				name = strings.Split(email, "@")[0]
+			}
+			// Accounts created here have no password until the user sets
+			// one with POST /me/password or the forgot password flow
+			user = models.User{
+				Email:           email,
+				Name:            name,
+				Password:        models.NoPassword,
+				EmailVerifiedAt: &now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return tx.Create(&models.ExternalIdentity{
+			UserID:   user.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsAnonymized() {
+		return nil, errors.New("linked account has been deleted")
+	}
+	return &user, nil
+}
+
+// redirect sends the browser to the frontend callback page
+func (h *OIDCHandler) redirect(c *gin.Context, fragment url.Values) {
+	c.Redirect(http.StatusFound, h.cfg.App.FrontendURL+"/auth/callback#"+fragment.Encode())
+}
+
+// redirectError sends the browser to the frontend callback page with an error
+func (h *OIDCHandler) redirectError(c *gin.Context, code string) {
+	h.redirect(c, url.Values{"error": {code}})
+}
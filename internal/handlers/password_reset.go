@@ -3,21 +3,36 @@ package handlers
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/middleware"
 	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// passwordResetSentMessage is returned whether or not the account exists
+const passwordResetSentMessage = "If an account with that email exists, a password reset link has been sent."
+
 // PasswordResetHandler handles password reset operations
-type PasswordResetHandler struct{}
+type PasswordResetHandler struct {
+	cfg     *config.Config
+	mailer  mailer.Mailer
+	limiter *ratelimit.Limiter
+	policy  *password.Policy
+}
 
 // NewPasswordResetHandler creates a new password reset handler
-func NewPasswordResetHandler() *PasswordResetHandler {
-	return &PasswordResetHandler{}
+func NewPasswordResetHandler(cfg *config.Config, m mailer.Mailer, limiter *ratelimit.Limiter, policy *password.Policy) *PasswordResetHandler {
+	return &PasswordResetHandler{cfg: cfg, mailer: m, limiter: limiter, policy: policy}
 }
 
 // RequestPasswordResetRequest represents the request body
@@ -28,7 +43,7 @@ type RequestPasswordResetRequest struct {
 // ResetPasswordRequest represents the reset password request body
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 // RequestPasswordReset initiates a password reset request
@@ -39,12 +54,22 @@ func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
+	// Limit reset emails per address, whether or not the account exists
+	key := "password_reset:" + strings.ToLower(strings.TrimSpace(req.Email))
+	wait, err := h.limiter.Allow(c.Request.Context(), key, h.cfg.RateLimit.PasswordResetRequests, h.cfg.RateLimit.PasswordResetWindow)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to check password reset limit", "error", err)
+	} else if wait > 0 {
+		middleware.TooManyRequests(c, wait, models.ErrTooManyRequests)
+		return
+	}
+
 	// Find user by email
 	var user models.User
-	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := requestDB(c).Where("email = ?", req.Email).First(&user).Error; err != nil {
 		// Don't reveal if user exists or not for security
 		c.JSON(http.StatusOK, gin.H{
-			"message": "If an account with that email exists, a password reset link has been sent.",
+			"message": passwordResetSentMessage,
 		})
 		return
 	}
@@ -71,7 +96,7 @@ func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
 	}
 
 	// Invalidate any existing tokens for this user
-	database.DB.Model(&models.PasswordResetToken{}).
+	requestDB(c).Model(&models.PasswordResetToken{}).
 		Where("user_id = ? AND used = ?", user.ID, false).
 		Update("used", true)
 
@@ -83,7 +108,7 @@ func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
 		Used:      false,
 	}
 
-	if err := database.DB.Create(&resetToken).Error; err != nil {
+	if err := requestDB(c).Create(&resetToken).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to create reset token",
 			models.ErrCodeDatabase,
@@ -91,12 +116,24 @@ func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
 		return
 	}
 
-	// TODO: Send email with reset link containing the token
-	// For now, we'll return the token in the response (ONLY FOR DEVELOPMENT)
-	// In production, this should be sent via email
+	if err := h.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Dhukuti password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to choose a new password:\n\n%s/reset-password?token=%s\n\nThis link expires in 1 hour. If you didn't ask for this, you can ignore this email.\n",
+			user.Name, h.cfg.App.FrontendURL, token,
+		),
+	}); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to send password reset email", "target_user_id", user.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to send reset email",
+			models.ErrCodeExternal,
+		))
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Password reset link has been sent to your email.",
-		"token":   token, // REMOVE THIS IN PRODUCTION
+		"message": passwordResetSentMessage,
 	})
 }
 
@@ -110,7 +147,7 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 
 	// Find all unused, non-expired tokens
 	var tokens []models.PasswordResetToken
-	if err := database.DB.Where("used = ? AND expires_at > ?", false, time.Now()).
+	if err := requestDB(c).Where("used = ? AND expires_at > ?", false, time.Now()).
 		Find(&tokens).Error; err != nil {
 		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
 			"Invalid or expired reset token",
@@ -138,12 +175,17 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 
 	// Get the user
 	var user models.User
-	if err := database.DB.First(&user, validToken.UserID).Error; err != nil {
+	if err := requestDB(c).First(&user, validToken.UserID).Error; err != nil {
 		c.JSON(http.StatusNotFound, models.ErrUserNotFound)
 		return
 	}
 
-	// Update password
+	if !checkPasswordPolicy(c, h.policy, req.NewPassword, user.Email) {
+		return
+	}
+
+	// Update password; a successful reset also lifts any lockout
+	user.LockedUntil = nil
 	if err := user.HashPassword(req.NewPassword); err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to hash password",
@@ -152,7 +194,7 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Save(&user).Error; err != nil {
+	if err := requestDB(c).Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
 			"Failed to update password",
 			models.ErrCodeDatabase,
@@ -162,7 +204,12 @@ func (h *PasswordResetHandler) ResetPassword(c *gin.Context) {
 
 	// Mark token as used
 	validToken.Used = true
-	database.DB.Save(validToken)
+	requestDB(c).Save(validToken)
+
+	accountKey, _ := loginKeys(user.Email, "")
+	if err := h.limiter.Succeed(c.Request.Context(), accountKey); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to reset login failures", "target_user_id", user.ID, "error", err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Password has been reset successfully. You can now login with your new password.",
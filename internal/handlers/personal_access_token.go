@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxPersonalAccessTokens caps the number of active tokens per user
+const maxPersonalAccessTokens = 50
+
+// PersonalAccessTokenHandler manages the authenticated user's personal access tokens
+type PersonalAccessTokenHandler struct{}
+
+// NewPersonalAccessTokenHandler creates a new personal access token handler
+func NewPersonalAccessTokenHandler() *PersonalAccessTokenHandler {
+	return &PersonalAccessTokenHandler{}
+}
+
+// CreateTokenRequest represents a request to create a personal access token
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays *int     `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // omit for no expiry
+}
+
+// TokenResponse represents a personal access token in responses
+type TokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateTokenResponse includes the token itself, which is only shown once
+type CreateTokenResponse struct {
+	Token string `json:"token"`
+	TokenResponse
+}
+
+// CreateToken issues a new personal access token
+func (h *PersonalAccessTokenHandler) CreateToken(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Name cannot be empty", models.ErrCodeValidation))
+		return
+	}
+
+	// Deduplicate while keeping the caller's order
+	var scopes []string
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Unknown scope: "+scope+". Valid scopes are "+strings.Join(models.TokenScopes, ", "),
+				models.ErrCodeValidation,
+			))
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	var active int64
+	if err := requestDB(c).Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", user.ID, time.Now()).
+		Count(&active).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create token", models.ErrCodeDatabase))
+		return
+	}
+	if active >= maxPersonalAccessTokens {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Too many active tokens; revoke one before creating another",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+	plaintext := models.PersonalAccessTokenPrefix + secret
+
+	token := models.PersonalAccessToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: models.HashPersonalAccessToken(plaintext),
+		Hint:      plaintext[:len(models.PersonalAccessTokenPrefix)+6],
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := requestDB(c).Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create token", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateTokenResponse{
+		Token:         plaintext,
+		TokenResponse: newTokenResponse(&token),
+	})
+}
+
+// ListTokens returns the user's personal access tokens, newest first
+func (h *PersonalAccessTokenHandler) ListTokens(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var tokens []models.PersonalAccessToken
+	if err := requestDB(c).Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch tokens", models.ErrCodeDatabase))
+		return
+	}
+
+	response := make([]TokenResponse, 0, len(tokens))
+	for i := range tokens {
+		response = append(response, newTokenResponse(&tokens[i]))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeToken revokes one of the user's personal access tokens
+func (h *PersonalAccessTokenHandler) RevokeToken(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	tokenID, err := strconv.ParseUint(c.Param("token_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid token ID", models.ErrCodeInvalidInput))
+		return
+	}
+
+	var token models.PersonalAccessToken
+	if err := requestDB(c).Where("id = ? AND user_id = ?", tokenID, userID).First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Token not found", models.ErrCodeNotFound))
+		return
+	}
+
+	if token.RevokedAt == nil {
+		now := time.Now()
+		token.RevokedAt = &now
+		if err := requestDB(c).Model(&token).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to revoke token", models.ErrCodeDatabase))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(&token))
+}
+
+// newTokenResponse builds the public representation of a personal access token
+func newTokenResponse(token *models.PersonalAccessToken) TokenResponse {
+	return TokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Hint:       token.Hint,
+		Scopes:     token.ScopeList(),
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		RevokedAt:  token.RevokedAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
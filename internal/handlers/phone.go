@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/sms"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PhoneHandler handles adding and verifying phone numbers for text messages
+type PhoneHandler struct {
+	cfg     *config.Config
+	gateway sms.Gateway
+}
+
+// NewPhoneHandler creates a new phone handler
+func NewPhoneHandler(cfg *config.Config, gateway sms.Gateway) *PhoneHandler {
+	return &PhoneHandler{cfg: cfg, gateway: gateway}
+}
+
+// AddPhoneRequest represents a request to add or change a phone number
+type AddPhoneRequest struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+// VerifyPhoneRequest represents a request to confirm a phone number
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// AddPhone texts a verification code to a new phone number. The number on
+// the account only changes once the code is confirmed via POST /me/phone/verify.
+func (h *PhoneHandler) AddPhone(c *gin.Context) {
+	var req AddPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	phone, err := sms.NormalizePhone(req.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.IsPhoneVerified() && user.Phone == phone {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Phone number is already verified",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	// Throttle codes based on the one issued last
+	var pending models.PhoneVerification
+	if err := requestDB(c).Where("user_id = ?", user.ID).First(&pending).Error; err == nil {
+		if wait := h.cfg.SMS.CodeResendDelay - time.Since(pending.CreatedAt); wait > 0 {
+			middleware.TooManyRequests(c, wait, models.NewErrorResponse(
+				"Please wait before requesting another verification code",
+				models.ErrCodeRateLimited,
+			))
+			return
+		}
+	}
+
+	code, err := newPhoneCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	// A new code replaces any pending one
+	err = requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PhoneVerification{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PhoneVerification{
+			UserID:    user.ID,
+			Phone:     phone,
+			Code:      hashToken(code),
+			ExpiresAt: time.Now().Add(h.cfg.SMS.CodeExpiry),
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to store verification code",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	body, err := sms.Render(sms.TemplateVerificationCode, sms.VerificationCodeData{Code: code, Expiry: h.cfg.SMS.CodeExpiry})
+	if err == nil {
+		err = h.gateway.Send(sms.Message{To: phone, Body: body})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to send verification code",
+			models.ErrCodeExternal,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Verification code sent to " + phone,
+		"expires_in": int(h.cfg.SMS.CodeExpiry.Seconds()),
+	})
+}
+
+// VerifyPhone confirms a phone number with the texted code
+func (h *PhoneHandler) VerifyPhone(c *gin.Context) {
+	var req VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	invalid := models.NewErrorResponse("Invalid or expired verification code", models.ErrCodeInvalidToken)
+
+	var pending models.PhoneVerification
+	if err := requestDB(c).Where("user_id = ?", user.ID).First(&pending).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, invalid)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+	if !pending.IsValid(h.cfg.SMS.CodeMaxAttempts) {
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(req.Code)), []byte(pending.Code)) != 1 {
+		requestDB(c).Model(&pending).Update("attempts", gorm.Expr("attempts + 1"))
+		c.JSON(http.StatusBadRequest, invalid)
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"phone":             pending.Phone,
+			"phone_verified_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&pending).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to verify phone number",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Phone number verified successfully.",
+		"phone":   pending.Phone,
+	})
+}
+
+// RemovePhone removes the user's phone number, which stops text messages
+func (h *PhoneHandler) RemovePhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"phone":             "",
+			"phone_verified_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.PhoneVerification{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to remove phone number",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Phone number removed"})
+}
+
+// newPhoneCode returns a random six-digit code
+func newPhoneCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/password"
+	"github.com/gin-gonic/gin"
+)
+
+// avatarExtensions maps accepted avatar content types to file extensions
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// ProfileHandler handles self-service profile operations for the authenticated user
+type ProfileHandler struct {
+	cfg    *config.Config
+	mailer mailer.Mailer
+	policy *password.Policy
+	events events.Publisher
+}
+
+// NewProfileHandler creates a new profile handler
+func NewProfileHandler(cfg *config.Config, m mailer.Mailer, policy *password.Policy, publisher events.Publisher) *ProfileHandler {
+	return &ProfileHandler{cfg: cfg, mailer: m, policy: policy, events: publisher}
+}
+
+// UpdateProfileRequest represents a partial profile update
+type UpdateProfileRequest struct {
+	Name            *string `json:"name" binding:"omitempty,min=1,max=255"`
+	DigestFrequency *string `json:"digest_frequency" binding:"omitempty,oneof=off daily weekly"`
+	TimeZone        *string `json:"time_zone" binding:"omitempty,max=64"`
+}
+
+// ChangePasswordRequest represents a password change request
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"` // not needed for accounts without a password
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangeEmailRequest represents an email change request
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password"` // not needed for accounts without a password
+}
+
+// GetMe returns the authenticated user's profile
+func (h *ProfileHandler) GetMe(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// UpdateMe updates the authenticated user's profile fields
+func (h *ProfileHandler) UpdateMe(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Name cannot be empty", models.ErrCodeValidation))
+			return
+		}
+		user.Name = name
+		updates["name"] = name
+	}
+	if req.DigestFrequency != nil {
+		user.DigestFrequency = *req.DigestFrequency
+		updates["digest_frequency"] = user.DigestFrequency
+	}
+	if req.TimeZone != nil {
+		// "Local" would mean the server's zone
+		if _, err := time.LoadLocation(*req.TimeZone); err != nil || *req.TimeZone == "" || *req.TimeZone == "Local" {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Unknown time zone: "+*req.TimeZone, models.ErrCodeValidation))
+			return
+		}
+		user.TimeZone = *req.TimeZone
+		updates["time_zone"] = user.TimeZone
+	}
+
+	if len(updates) > 0 {
+		if err := requestDB(c).Model(user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+				"Failed to update profile",
+				models.ErrCodeDatabase,
+			))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// ChangePassword changes the password after checking the current one
+func (h *ProfileHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// Accounts without a password set their first one here
+	if !confirmPassword(user, req.CurrentPassword) {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
+			"Current password is incorrect",
+			models.ErrCodeAuth,
+		))
+		return
+	}
+
+	if !checkPasswordPolicy(c, h.policy, req.NewPassword, user.Email) {
+		return
+	}
+
+	if err := user.HashPassword(req.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to hash password",
+			models.ErrCodeInternal,
+		))
+		return
+	}
+
+	if err := requestDB(c).Model(user).Update("password", user.Password).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update password",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	if err := h.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your Dhukuti password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password for your account was just changed. If you didn't do this, reset your password immediately.\n",
+			user.Name,
+		),
+	}); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to send password change notification", "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ChangeEmail starts an email change. The address on the account only
+// changes once the new address is confirmed via POST /auth/verify-email.
+func (h *ProfileHandler) ChangeEmail(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !confirmPassword(user, req.Password) {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"New email is the same as the current one",
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	var existing models.User
+	if err := requestDB(c).Where("email = ?", newEmail).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"User with this email already exists",
+			models.ErrCodeAlreadyExists,
+		))
+		return
+	}
+
+	if err := sendVerificationEmail(c.Request.Context(), h.cfg, h.mailer, user, newEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to send verification email",
+			models.ErrCodeExternal,
+		))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Check your new email address to confirm the change.",
+	})
+}
+
+// UploadAvatar stores a new profile picture from the multipart "avatar" field
+func (h *ProfileHandler) UploadAvatar(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Avatar file is required", models.ErrCodeMissingField))
+		return
+	}
+
+	if fileHeader.Size > h.cfg.Storage.MaxAvatarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.NewErrorResponse(
+			fmt.Sprintf("Avatar must be at most %d KB", h.cfg.Storage.MaxAvatarSize/1024),
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Failed to read avatar", models.ErrCodeInvalidInput))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, h.cfg.Storage.MaxAvatarSize+1))
+	if err != nil || int64(len(data)) > h.cfg.Storage.MaxAvatarSize {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Failed to read avatar", models.ErrCodeInvalidInput))
+		return
+	}
+
+	// Trust the file contents rather than the client supplied content type
+	ext, allowed := avatarExtensions[http.DetectContentType(data)]
+	if !allowed {
+		c.JSON(http.StatusUnsupportedMediaType, models.NewErrorResponse(
+			"Avatar must be a PNG, JPEG, GIF or WebP image",
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	suffix, err := newToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	dir := filepath.Join(h.cfg.Storage.UploadDir, "avatars")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to create upload directory", "error", err)
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	filename := fmt.Sprintf("%d-%s%s", user.ID, suffix[:16], ext)
+	if err := os.WriteFile(filepath.Join(dir, filename), data, 0o644); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to write avatar", "error", err)
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	previous := user.AvatarURL
+	user.AvatarURL = "/uploads/avatars/" + filename
+	if err := requestDB(c).Model(user).Update("avatar_url", user.AvatarURL).Error; err != nil {
+		os.Remove(filepath.Join(dir, filename))
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to update avatar",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	// Remove the replaced file; it is no longer referenced
+	if strings.HasPrefix(previous, "/uploads/avatars/") {
+		os.Remove(filepath.Join(dir, filepath.Base(previous)))
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
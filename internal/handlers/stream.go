@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/stream"
+	"github.com/gin-gonic/gin"
+)
+
+// maxReplay caps the events replayed on reconnect; clients further behind
+// are told to reload
+const maxReplay = 1000
+
+// StreamHandler serves the real-time event stream
+type StreamHandler struct {
+	cfg *config.Config
+	hub *stream.Hub
+}
+
+// NewStreamHandler creates a new stream handler
+func NewStreamHandler(cfg *config.Config, hub *stream.Hub) *StreamHandler {
+	return &StreamHandler{cfg: cfg, hub: hub}
+}
+
+// CreateTicket issues a short-lived ticket for opening the stream from a
+// browser, where EventSource cannot send an Authorization header
+func (h *StreamHandler) CreateTicket(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	email, _ := c.Get("email")
+
+	ticket, err := middleware.GenerateStreamTicket(userID.(uint), email.(string), h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(h.cfg.Stream.TicketExpiry.Seconds()),
+	})
+}
+
+// Stream sends the events of the user's circles as Server-Sent Events.
+// Clients resume after a disconnect with the Last-Event-ID header (sent
+// automatically by EventSource) or the last_event_id query parameter.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var sent uint64
+	if lastEventID != "" {
+		var err error
+		if sent, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid last event ID", models.ErrCodeInvalidInput))
+			return
+		}
+	}
+
+	// Subscribe before replaying so nothing is missed in between; events
+	// seen in both are skipped by ID
+	sub := h.hub.Subscribe(userID.(uint))
+	defer h.hub.Unsubscribe(sub)
+
+	var replay []models.StreamEvent
+	reset := false
+	if lastEventID != "" {
+		var err error
+		replay, reset, err = stream.Replay(requestDB(c), userID.(uint), sent, maxReplay+1)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to load events", models.ErrCodeDatabase))
+			return
+		}
+		if len(replay) > maxReplay {
+			replay, reset = nil, true
+		}
+	}
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	// Ask clients to wait a few seconds before reconnecting
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if reset {
+		// Missed events are no longer available; the client should reload
+		// its state. Later events continue from here.
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range replay {
+		writeStreamEvent(c, event)
+		sent = event.ID
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.cfg.Stream.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			w.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return // fell behind; the client reconnects and resumes
+			}
+			if event.ID <= sent {
+				continue
+			}
+			writeStreamEvent(c, event)
+			sent = event.ID
+			w.Flush()
+		}
+	}
+}
+
+// writeStreamEvent writes one Server-Sent Event. Payloads are single-line JSON.
+func writeStreamEvent(c *gin.Context, event models.StreamEvent) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.EventType, event.Payload)
+}
@@ -0,0 +1,459 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/middleware"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/Sudan23/dhukuti/internal/totp"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount is the number of recovery codes issued at a time
+const recoveryCodeCount = 10
+
+// TwoFactorHandler handles TOTP two-factor authentication operations
+type TwoFactorHandler struct {
+	cfg     *config.Config
+	limiter *ratelimit.Limiter
+}
+
+// NewTwoFactorHandler creates a new two-factor handler
+func NewTwoFactorHandler(cfg *config.Config, limiter *ratelimit.Limiter) *TwoFactorHandler {
+	return &TwoFactorHandler{cfg: cfg, limiter: limiter}
+}
+
+// TwoFactorSetupResponse represents the enrollment details for an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorCodeRequest represents a request carrying a TOTP code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest represents a request to turn off two-factor authentication
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"` // not needed for accounts without a password
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// VerifyTwoFactorLoginRequest represents the second step of a two-factor login
+type VerifyTwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// RecoveryCodesResponse returns freshly generated recovery codes
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// Setup starts TOTP enrollment by generating a new secret for the user
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Two-factor authentication is already enabled",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	if err := requestDB(c).Model(user).Update("totp_secret", secret).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to start two-factor enrollment",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(h.cfg.App.Name, user.Email, secret),
+	})
+}
+
+// Enable confirms TOTP enrollment with a code from the authenticator app
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Two-factor authentication is already enabled",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Two-factor enrollment has not been started",
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	step, valid := totp.Validate(user.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidTwoFactorCode)
+		return
+	}
+
+	var codes []string
+	err := requestDB(c).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled_at": now,
+			"totp_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to enable two-factor authentication",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// Disable turns off two-factor authentication after re-checking both factors
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Two-factor authentication is not enabled",
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	if !confirmPassword(user, req.Password) {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
+		return
+	}
+
+	valid, err := verifySecondFactor(c.Request.Context(), user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidTwoFactorCode)
+		return
+	}
+
+	// Members of circles that require 2FA must keep it enabled
+	var requiringCircles int64
+	requestDB(c).Model(&models.Circle{}).
+		Joins("JOIN circle_members ON circle_members.circle_id = circles.id").
+		Where("circle_members.user_id = ? AND circle_members.deleted_at IS NULL AND circles.require_two_factor = ?", user.ID, true).
+		Count(&requiringCircles)
+	if requiringCircles > 0 {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"You belong to circles that require two-factor authentication",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	err = requestDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to disable two-factor authentication",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a TOTP code
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+			"Two-factor authentication is not enabled",
+			models.ErrCodeValidation,
+		))
+		return
+	}
+
+	valid, err := verifySecondFactor(c.Request.Context(), user, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+	if !valid {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidTwoFactorCode)
+		return
+	}
+
+	var codes []string
+	err = requestDB(c).Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to generate recovery codes",
+			models.ErrCodeDatabase,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// VerifyLogin completes a two-factor login and issues the session token
+func (h *TwoFactorHandler) VerifyLogin(c *gin.Context) {
+	var req VerifyTwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	claims, err := middleware.ParseChallengeToken(req.ChallengeToken, h.cfg)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.NewErrorResponse(
+			"Invalid or expired challenge token",
+			models.ErrCodeInvalidToken,
+		))
+		return
+	}
+
+	// Codes are short, so guessing is throttled per account
+	key := fmt.Sprintf("2fa:user:%d", claims.UserID)
+	if wait, err := h.limiter.Blocked(c.Request.Context(), key); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to check two-factor backoff", "error", err)
+	} else if wait > 0 {
+		middleware.TooManyRequests(c, wait, models.ErrTooManyRequests)
+		return
+	}
+
+	var user models.User
+	if err := requestDB(c).First(&user, claims.UserID).Error; err != nil || !user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidCredentials)
+		return
+	}
+
+	valid, err := verifySecondFactor(c.Request.Context(), &user, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+	if !valid {
+		if _, err := h.limiter.Fail(c.Request.Context(), key); err != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to record two-factor failure", "limit_key", key, "error", err)
+		}
+		c.JSON(http.StatusUnauthorized, models.ErrInvalidTwoFactorCode)
+		return
+	}
+
+	if err := h.limiter.Succeed(c.Request.Context(), key); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to reset two-factor failures", "limit_key", key, "error", err)
+	}
+
+	token, err := middleware.GenerateToken(user.ID, user.Email, h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse(
+			"Failed to generate token",
+			models.ErrCodeInternal,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, AuthResponse{
+		Token: token,
+		User:  newUserResponse(&user),
+	})
+}
+
+// requestDB returns the database handle for queries run for the request, so
+// they are logged with its request ID
+func requestDB(c *gin.Context) *gorm.DB {
+	return database.DB.WithContext(c.Request.Context())
+}
+
+// currentUser loads the authenticated user, writing an error response on failure
+func currentUser(c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+		return nil, false
+	}
+
+	var user models.User
+	if err := requestDB(c).First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrUserNotFound)
+		return nil, false
+	}
+
+	// Tokens issued before an account was deleted are no longer honoured
+	if user.IsAnonymized() {
+		c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+		return nil, false
+	}
+	return &user, true
+}
+
+// verifySecondFactor checks a TOTP code or, failing that, a recovery code.
+// Accepted TOTP steps and recovery codes are consumed so they cannot be replayed.
+func verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) (bool, error) {
+	db := database.DB.WithContext(ctx)
+	if code != "" {
+		step, valid := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !valid {
+			return false, nil
+		}
+
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_step < ?", user.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+
+	if recoveryCode != "" {
+		var codes []models.RecoveryCode
+		if err := db.Where("user_id = ? AND used_at IS NULL", user.ID).Find(&codes).Error; err != nil {
+			return false, err
+		}
+
+		normalized := normalizeRecoveryCode(recoveryCode)
+		for i := range codes {
+			if bcrypt.CompareHashAndPassword([]byte(codes[i].CodeHash), []byte(normalized)) != nil {
+				continue
+			}
+			result := db.Model(&models.RecoveryCode{}).
+				Where("id = ? AND used_at IS NULL", codes[i].ID).
+				Update("used_at", time.Now())
+			if result.Error != nil {
+				return false, result.Error
+			}
+			return result.RowsAffected == 1, nil
+		}
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and issues a new set,
+// returning the plaintext codes to show to the user once
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 10)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		encoded := strings.ToLower(base32.StdEncoding.EncodeToString(raw))[:10]
+		codes[i] = encoded[:5] + "-" + encoded[5:]
+
+		hashed, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(codes[i])), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: string(hashed)}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips formatting so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// confirmPassword checks the password the user gave to confirm a sensitive
+// change. Accounts without a password, e.g. ones created by signing in with
+// an identity provider, have none to give, so their session is enough.
+func confirmPassword(user *models.User, plain string) bool {
+	if !user.HasPassword() {
+		return true
+	}
+	return user.CheckPassword(plain) == nil
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhooksPerUser caps the number of webhooks a user can register
+const maxWebhooksPerUser = 20
+
+// WebhookHandler manages the authenticated user's webhook subscriptions
+type WebhookHandler struct {
+	cfg *config.Config
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(cfg *config.Config) *WebhookHandler {
+	return &WebhookHandler{cfg: cfg}
+}
+
+// CreateWebhookRequest represents a request to register a webhook
+type CreateWebhookRequest struct {
+	URL      string   `json:"url" binding:"required,url"`
+	CircleID *uint    `json:"circle_id"` // omit to receive events from all of the user's circles
+	Events   []string `json:"events"`    // omit to receive all events
+}
+
+// WebhookResponse represents a webhook in responses
+type WebhookResponse struct {
+	ID        uint      `json:"id"`
+	URL       string    `json:"url"`
+	CircleID  *uint     `json:"circle_id"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateWebhookResponse includes the signing secret, which is only shown once
+type CreateWebhookResponse struct {
+	Secret string `json:"secret"`
+	WebhookResponse
+}
+
+// CreateWebhook registers a webhook
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse(err.Error(), models.ErrCodeValidation))
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+
+	target, err := url.Parse(req.URL)
+	allowHTTP := h.cfg.Webhook.AllowPrivateNetworks
+	if err != nil || target.Host == "" || !(target.Scheme == "https" || (allowHTTP && target.Scheme == "http")) {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Webhook URL must be an https:// URL", models.ErrCodeValidation))
+		return
+	}
+
+	for _, eventType := range req.Events {
+		if !events.Type(eventType).IsValid() {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Unknown event type: "+eventType, models.ErrCodeValidation))
+			return
+		}
+	}
+
+	if req.CircleID != nil {
+		var member models.CircleMember
+		if err := requestDB(c).Where("circle_id = ? AND user_id = ? AND status = ?", *req.CircleID, userID, "active").First(&member).Error; err != nil {
+			c.JSON(http.StatusNotFound, models.NewErrorResponse("Circle not found or you are not an active member", models.ErrCodeNotFound))
+			return
+		}
+	}
+
+	var count int64
+	requestDB(c).Model(&models.Webhook{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxWebhooksPerUser {
+		c.JSON(http.StatusConflict, models.NewErrorResponse(
+			"Too many webhooks; delete one before adding another",
+			models.ErrCodeConflict,
+		))
+		return
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+		return
+	}
+
+	webhook := models.Webhook{
+		UserID:   userID.(uint),
+		CircleID: req.CircleID,
+		URL:      target.String(),
+		Secret:   "whsec_" + secret,
+		Events:   strings.Join(req.Events, " "),
+		Active:   true,
+	}
+	if err := requestDB(c).Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to create webhook", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateWebhookResponse{
+		Secret:          webhook.Secret,
+		WebhookResponse: newWebhookResponse(&webhook),
+	})
+}
+
+// ListWebhooks returns the user's webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var webhooks []models.Webhook
+	if err := requestDB(c).Where("user_id = ?", userID).Order("id").Find(&webhooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch webhooks", models.ErrCodeDatabase))
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(webhooks))
+	for i := range webhooks {
+		response = append(response, newWebhookResponse(&webhooks[i]))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook removes a webhook; queued deliveries to it are abandoned
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := requestDB(c).Delete(webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to delete webhook", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// ListDeliveries returns the most recent deliveries to a webhook
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := requestDB(c).Where("webhook_id = ?", webhook.ID).Order("id DESC").Limit(100).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to fetch deliveries", models.ErrCodeDatabase))
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverDelivery queues a delivery to be sent again from scratch
+func (h *WebhookHandler) RedeliverDelivery(c *gin.Context) {
+	webhook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+
+	result := requestDB(c).Model(&models.WebhookDelivery{}).
+		Where("id = ? AND webhook_id = ?", c.Param("delivery_id"), webhook.ID).
+		Updates(map[string]interface{}{
+			"status":          models.DeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.NewErrorResponse("Failed to queue delivery", models.ErrCodeDatabase))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Delivery not found", models.ErrCodeNotFound))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery queued"})
+}
+
+// findWebhook loads the :webhook_id webhook owned by the user, writing an
+// error response if there is none
+func (h *WebhookHandler) findWebhook(c *gin.Context) (*models.Webhook, bool) {
+	userID, _ := c.Get("user_id")
+
+	webhookID, err := strconv.ParseUint(c.Param("webhook_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.NewErrorResponse("Invalid webhook ID", models.ErrCodeInvalidInput))
+		return nil, false
+	}
+
+	var webhook models.Webhook
+	if err := requestDB(c).Where("id = ? AND user_id = ?", webhookID, userID).First(&webhook).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.NewErrorResponse("Webhook not found", models.ErrCodeNotFound))
+		return nil, false
+	}
+	return &webhook, true
+}
+
+// newWebhookResponse builds the public representation of a webhook
+func newWebhookResponse(webhook *models.Webhook) WebhookResponse {
+	return WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		CircleID:  webhook.CircleID,
+		Events:    webhook.EventList(),
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+	}
+}
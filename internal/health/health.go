@@ -0,0 +1,183 @@
+// Package health reports whether an API instance is alive and ready for
+// traffic. It also runs the background workers, so it knows whether they are
+// still running and can stop them on shutdown.
+package health
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"gorm.io/gorm"
+)
+
+// checkTimeout bounds each readiness check
+const checkTimeout = 2 * time.Second
+
+// Check results
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Report is the outcome of a probe: its status and the result of each check.
+// Probes are public, so a failed check is only reported as failed; why it
+// failed is logged.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// OK reports whether every check passed
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// worker is a background worker run by the Checker
+type worker struct {
+	name    string
+	running atomic.Bool
+	failed  atomic.Bool // set when the worker panicked
+}
+
+// Checker runs the probes and the background workers of one instance
+type Checker struct {
+	db           *gorm.DB
+	mu           sync.Mutex
+	workers      []*worker
+	wg           sync.WaitGroup
+	shuttingDown atomic.Bool
+}
+
+// New creates a Checker for an instance using db
+func New(db *gorm.DB) *Checker {
+	return &Checker{db: db}
+}
+
+// Go runs a background worker until ctx is cancelled. A worker that panics is
+// logged and reported as failed, which fails both probes so the instance is
+// restarted.
+func (c *Checker) Go(ctx context.Context, name string, run func(context.Context)) {
+	w := &worker{name: name}
+	w.running.Store(true)
+	c.mu.Lock()
+	c.workers = append(c.workers, w)
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer w.running.Store(false)
+		defer func() {
+			if err := recover(); err != nil {
+				w.failed.Store(true)
+				slog.Error("Background worker failed", "worker", name, "error", fmt.Sprint(err), "stack", string(debug.Stack()))
+			}
+		}()
+		run(ctx)
+	}()
+}
+
+// ShutDown marks the instance as shutting down. Readiness fails from then
+// on, so load balancers stop sending it new requests.
+func (c *Checker) ShutDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Wait waits for the workers to return after their context was cancelled,
+// or until ctx is done
+func (c *Checker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background workers still running: %w", ctx.Err())
+	}
+}
+
+// Live reports whether the instance works at all. It only fails when a
+// background worker has failed, which a restart fixes; an unreachable
+// database does not make it fail, as restarting would not help.
+func (c *Checker) Live() Report {
+	report := Report{Status: StatusOK, Checks: map[string]string{}}
+	for _, w := range c.snapshot() {
+		if w.failed.Load() {
+			report.fail("worker:" + w.name)
+		}
+	}
+	return report
+}
+
+// Ready reports whether the instance can serve requests: the database
+// answers, its schema is up to date, every background worker is running
+// and the instance isn't shutting down
+func (c *Checker) Ready(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	report := Report{Status: StatusOK, Checks: map[string]string{}}
+
+	if c.shuttingDown.Load() {
+		report.fail("shutdown")
+	}
+
+	sqlDB, err := c.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		slog.Warn("Readiness check failed", "check", "database", "error", err)
+		report.fail("database")
+	} else {
+		report.Checks["database"] = StatusOK
+	}
+
+	pending, err := database.PendingMigrations(c.db.WithContext(ctx))
+	switch {
+	case err != nil:
+		slog.Warn("Readiness check failed", "check", "migrations", "error", err)
+		report.fail("migrations")
+	case len(pending) > 0:
+		slog.Warn("Readiness check failed", "check", "migrations",
+			"error", fmt.Sprintf("%d pending, from %d_%s", len(pending), pending[0].Version, pending[0].Name))
+		report.fail("migrations")
+	default:
+		report.Checks["migrations"] = StatusOK
+	}
+
+	for _, w := range c.snapshot() {
+		key := "worker:" + w.name
+		// A failure was logged when the worker panicked
+		if w.failed.Load() {
+			report.fail(key)
+		} else if !w.running.Load() {
+			slog.Warn("Readiness check failed", "check", key, "error", "stopped")
+			report.fail(key)
+		} else {
+			report.Checks[key] = StatusOK
+		}
+	}
+	return report
+}
+
+// snapshot returns the workers started so far
+func (c *Checker) snapshot() []*worker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*worker(nil), c.workers...)
+}
+
+// fail records a failed check
+func (r *Report) fail(check string) {
+	r.Status = StatusFail
+	r.Checks[check] = StatusFail
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is how long a query may take before it is logged as slow
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger writes GORM's logs through the logger in the query's context,
+// so SQL run for a request carries its request ID. Queries are logged with
+// their placeholders: the values bound to them, which include password
+// hashes and tokens, are never logged.
+type GormLogger struct {
+	level gormlogger.LogLevel
+}
+
+// NewGormLogger creates a GORM logger. At gormlogger.Info every query is
+// logged at debug level; otherwise only failed and slow ones are.
+func NewGormLogger(level gormlogger.LogLevel) *GormLogger {
+	return &GormLogger{level: level}
+}
+
+// LogMode returns a copy of the logger at another level
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &GormLogger{level: level}
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Info {
+		FromContext(ctx).InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		FromContext(ctx).WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= gormlogger.Error {
+		FromContext(ctx).ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace logs a query once it has run
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	logger := FromContext(ctx)
+
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.ErrorContext(ctx, "Query failed", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds(), "error", err)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		logger.WarnContext(ctx, "Slow query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		logger.DebugContext(ctx, "Query", "sql", sql, "rows", rows, "duration_ms", elapsed.Milliseconds())
+	}
+}
+
+// ParamsFilter drops the values bound to a query before it is logged
+func (l *GormLogger) ParamsFilter(_ context.Context, sql string, _ ...interface{}) (string, []interface{}) {
+	return sql, nil
+}
@@ -0,0 +1,72 @@
+// Package logging sets up structured logging with log/slog. Each request
+// carries a logger in its context with the request ID and, once known, the
+// user and circle it is for. Code handling a request logs through
+// FromContext, so every line it writes, including the SQL it runs, can be
+// traced back to the request.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+)
+
+type contextKey struct{}
+
+// New creates a logger writing to w in the configured format and level,
+// with secrets redacted
+func New(w io.Writer, cfg config.LogConfig) *slog.Logger {
+	options := &slog.HandlerOptions{
+		Level:       parseLevel(cfg.Level),
+		ReplaceAttr: redactAttr,
+	}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, options))
+	}
+	return slog.New(slog.NewJSONHandler(w, options))
+}
+
+// Setup creates the logger writing to stdout and makes it the default, which
+// also sends lines written through the log package to it
+func Setup(cfg config.LogConfig) *slog.Logger {
+	logger := New(os.Stdout, cfg)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// WithLogger returns a copy of ctx carrying the logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger in ctx, or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger adds the attributes to every line
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// parseLevel maps a configured level name to its slog level
+func parseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, config.LogConfig{Level: "info", Format: "json"})
+
+	logger.Info("Calling https://example.com/cb?code=abc123&state=ok",
+		"password", "hunter2",
+		"refresh_token", "r-secret",
+		"Authorization", "Bearer header-secret",
+		"detail", "sent dhk_patsecret and eyJhbGciOi.eyJzdWIiOjF9.c2lnbmF0dXJl",
+		"error", errors.New("connect: host=db password=dbsecret"),
+		"conflict", "Key (email)=(taken@example.com) already exists.",
+		"violation", "Failing row contains (7, null, hidden@example.com).",
+		"email", "kept@example.com",
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"abc123", "hunter2", "r-secret", "header-secret", "dhk_patsecret", "eyJhbGciOi", "dbsecret", "taken@example.com", "hidden@example.com"} {
+		assert.NotContains(t, out, secret)
+	}
+	assert.Contains(t, out, "state=ok")
+	assert.Contains(t, out, "kept@example.com")
+	assert.Contains(t, out, `"password":"[REDACTED]"`)
+	assert.Contains(t, out, "Key (email)=([REDACTED]) already exists.")
+}
+
+func TestFromContext(t *testing.T) {
+	assert.NotNil(t, FromContext(context.Background()), "the default logger")
+
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf, config.LogConfig{Level: "info"}).With("request_id", "r1"))
+	ctx = With(ctx, "user_id", 7)
+	FromContext(ctx).Info("hello")
+
+	assert.Contains(t, buf.String(), `"request_id":"r1"`)
+	assert.Contains(t, buf.String(), `"user_id":7`)
+}
+
+func TestGormLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf, config.LogConfig{Level: "debug"}).With("request_id", "r1"))
+	query := func() (string, int64) { return "SELECT * FROM users WHERE email = ?", 1 }
+
+	NewGormLogger(gormlogger.Info).Trace(ctx, time.Now(), query, nil)
+	assert.Contains(t, buf.String(), `"request_id":"r1"`)
+	assert.Contains(t, buf.String(), `"msg":"Query"`)
+
+	buf.Reset()
+	NewGormLogger(gormlogger.Warn).Trace(ctx, time.Now(), query, nil)
+	NewGormLogger(gormlogger.Warn).Trace(ctx, time.Now(), query, gorm.ErrRecordNotFound)
+	assert.Empty(t, buf.String(), "fast queries and missing records are not logged")
+
+	NewGormLogger(gormlogger.Warn).Trace(ctx, time.Now().Add(-time.Second), query, nil)
+	assert.Contains(t, buf.String(), `"msg":"Slow query"`)
+
+	buf.Reset()
+	NewGormLogger(gormlogger.Warn).Trace(ctx, time.Now(), query, errors.New("boom"))
+	assert.Contains(t, buf.String(), `"level":"ERROR"`)
+
+	sql, vars := NewGormLogger(gormlogger.Info).ParamsFilter(ctx, "SELECT ?", "secret")
+	assert.Equal(t, "SELECT ?", sql)
+	assert.Empty(t, vars, "bound values are never logged")
+}
@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces secret values in log lines
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"api_key":       true,
+	"apikey":        true,
+	"code":          true,
+	"recovery_code": true,
+	"ticket":        true,
+	"dsn":           true,
+}
+
+// sensitiveSuffixes cover variants such as new_password and refresh_token
+var sensitiveSuffixes = []string{"_password", "_token", "_secret", "-token", "-key"}
+
+// secretPatterns find secrets inside free text such as messages and errors
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// Authorization header values
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + redacted},
+	// JSON web tokens
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redacted},
+	// Personal access tokens
+	{regexp.MustCompile(`\bdhk_[A-Za-z0-9_-]+`), redacted},
+	// Secrets in query strings and connection strings
+	{regexp.MustCompile(`(?i)\b(password|token|secret|code|ticket|api_key|key)=[^\s&"]+`), "$1=" + redacted},
+	// Values in Postgres constraint errors, such as Key (email)=(...)
+	{regexp.MustCompile(`\b(Key \([^)]*\))=\(.*\)`), "$1=(" + redacted + ")"},
+	{regexp.MustCompile(`\b(Failing row contains) \(.*\)`), "$1 (" + redacted + ")"},
+}
+
+// isSensitiveKey reports whether values logged under key are secret
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, suffix := range sensitiveSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactString removes secrets from free text
+func RedactString(s string) string {
+	for _, secret := range secretPatterns {
+		s = secret.pattern.ReplaceAllString(s, secret.replacement)
+	}
+	return s
+}
+
+// redactAttr is the handlers' ReplaceAttr. It hides the values of sensitive
+// keys and secrets in strings and errors, including the message itself.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.MessageKey && isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
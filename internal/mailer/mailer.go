@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/smtp"
+	"strings"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+)
+
+// Message represents an outgoing email
+type Message struct {
+	To      string
+	Subject string
+	Body    string // plain text
+	HTML    string // optional HTML alternative to Body
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns an SMTP mailer when SMTP is configured, otherwise a mailer
+// that only logs messages (useful for local development)
+func New(cfg *config.Config) Mailer {
+	if cfg.Mail.SMTPHost == "" {
+		return &LogMailer{}
+	}
+	return &SMTPMailer{
+		host:     cfg.Mail.SMTPHost,
+		port:     cfg.Mail.SMTPPort,
+		user:     cfg.Mail.SMTPUser,
+		password: cfg.Mail.SMTPPassword,
+		from:     cfg.Mail.From,
+	}
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	host     string
+	port     string
+	user     string
+	password string
+	from     string
+}
+
+// Send delivers the message via SMTP
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.user != "" {
+		auth = smtp.PlainAuth("", m.user, m.password, m.host)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(msg.Body)
+	} else {
+		// Clients show the last part they support, so HTML goes last
+		boundary, err := newBoundary()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary)
+		b.WriteString("\r\n")
+		for _, part := range []struct{ contentType, body string }{
+			{"text/plain", msg.Body},
+			{"text/html", msg.HTML},
+		} {
+			fmt.Fprintf(&b, "--%s\r\n", boundary)
+			fmt.Fprintf(&b, "Content-Type: %s; charset=\"utf-8\"\r\n", part.contentType)
+			b.WriteString("\r\n")
+			b.WriteString(part.body)
+			b.WriteString("\r\n")
+		}
+		fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	}
+
+	if err := smtp.SendMail(m.host+":"+m.port, auth, m.from, []string{msg.To}, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// newBoundary returns a random MIME multipart boundary
+func newBoundary() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "dhukuti-" + hex.EncodeToString(buf), nil
+}
+
+// LogMailer writes emails to the application log instead of sending them
+type LogMailer struct{}
+
+// Send logs the message
+func (m *LogMailer) Send(msg Message) error {
+	slog.Info("Email not sent: no SMTP server configured", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
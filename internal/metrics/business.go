@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// activeCircleWindow is how recently a circle must have had a contribution to
+// count as active
+const activeCircleWindow = 30 * 24 * time.Hour
+
+// queryTimeout bounds the queries run for one scrape
+const queryTimeout = 5 * time.Second
+
+// businessCollector reads business figures from the database on every
+// scrape. They describe the whole deployment rather than one instance, so
+// every instance reports the same values: aggregate them with max, not sum.
+type businessCollector struct {
+	db                 *gorm.DB
+	contributions      *prometheus.Desc
+	contributionAmount *prometheus.Desc
+	openProposals      *prometheus.Desc
+	pendingMembers     *prometheus.Desc
+	activeCircles      *prometheus.Desc
+}
+
+func newBusinessCollector(db *gorm.DB) *businessCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil)
+	}
+	return &businessCollector{
+		db:                 db,
+		contributions:      desc("contributions_recorded_total", "Contributions recorded in all circles."),
+		contributionAmount: desc("contributions_amount_total", "Sum of the amounts of all recorded contributions."),
+		openProposals:      desc("amount_proposals_open", "Circles with an amount change waiting for approval."),
+		pendingMembers:     desc("member_approvals_pending", "Invited members waiting for the circle's approval."),
+		activeCircles:      desc("circles_active", "Circles with a contribution in the last 30 days."),
+	}
+}
+
+func (b *businessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- b.contributions
+	ch <- b.contributionAmount
+	ch <- b.openProposals
+	ch <- b.pendingMembers
+	ch <- b.activeCircles
+}
+
+func (b *businessCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+	db := b.db.WithContext(ctx)
+
+	var totals struct {
+		Count  int64
+		Amount int64
+	}
+	if err := db.Model(&models.Contribution{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Scan(&totals).Error; err != nil {
+		b.fail(ch, err, b.contributions, b.contributionAmount)
+	} else {
+		ch <- prometheus.MustNewConstMetric(b.contributions, prometheus.CounterValue, float64(totals.Count))
+		ch <- prometheus.MustNewConstMetric(b.contributionAmount, prometheus.CounterValue, float64(totals.Amount))
+	}
+
+	recent := db.Model(&models.Contribution{}).Select("circle_id").Where("created_at >= ?", time.Now().Add(-activeCircleWindow))
+	b.count(ch, b.openProposals, db.Model(&models.Circle{}).Where("proposed_amount > 0"))
+	b.count(ch, b.pendingMembers, db.Model(&models.CircleMember{}).Where("status = ?", "pending"))
+	b.count(ch, b.activeCircles, db.Model(&models.Circle{}).Where("id IN (?)", recent))
+}
+
+// count reports the number of rows query matches as a gauge
+func (b *businessCollector) count(ch chan<- prometheus.Metric, desc *prometheus.Desc, query *gorm.DB) {
+	var n int64
+	if err := query.Count(&n).Error; err != nil {
+		b.fail(ch, err, desc)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(n))
+}
+
+// fail logs a failed query and reports its metrics as invalid, which leaves
+// them out of the scrape
+func (b *businessCollector) fail(ch chan<- prometheus.Metric, err error, descs ...*prometheus.Desc) {
+	slog.Error("Failed to collect business metrics", "error", err)
+	for _, desc := range descs {
+		ch <- prometheus.NewInvalidMetric(desc, err)
+	}
+}
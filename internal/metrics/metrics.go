@@ -0,0 +1,101 @@
+// Package metrics exposes the API's metrics in the Prometheus text format:
+// HTTP traffic per route, the database connection pool, and business figures
+// read from the database when the metrics are scraped.
+package metrics
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// namespace prefixes the names of the API's own metrics
+const namespace = "dhukuti"
+
+// Metrics holds the collectors of one API instance. Each instance has its own
+// registry, so several can run in one process, e.g. in tests.
+type Metrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New creates the collectors and registers them, including the Go runtime,
+// process and database pool collectors
+func New(db *gorm.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route template and status code.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.duration,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newBusinessCollector(db),
+	)
+	if sqlDB, err := db.DB(); err != nil {
+		slog.Warn("Database pool metrics unavailable", "error", err)
+	} else {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, namespace))
+	}
+	return m
+}
+
+// Middleware records the latency and status of every request. Requests are
+// labelled with their route template, e.g. /api/v1/circles/:id, so IDs don't
+// create a series each; requests matching no route share one label.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.duration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the metrics. When token is set, scrapers must send it as a
+// bearer token. A failing business query leaves its metrics out of the
+// response rather than failing the whole scrape.
+func (m *Metrics) Handler(token string) gin.HandlerFunc {
+	handler := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+	return func(c *gin.Context) {
+		if token != "" {
+			sent := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+				c.Abort()
+				return
+			}
+		}
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -7,24 +7,64 @@ import (
 	"time"
 
 	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// PurposeTwoFactorChallenge marks a token that only proves the password step
+// of a two-factor login and cannot be used as a session token
+const PurposeTwoFactorChallenge = "2fa_challenge"
+
+// PurposeStreamTicket marks a short-lived token that can only open the event
+// stream. Browsers cannot set headers on EventSource requests, so it is sent
+// in the query string instead of a session token.
+const PurposeStreamTicket = "stream_ticket"
+
 // Claims represents JWT claims
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Email  string `json:"email"`
+	UserID  uint   `json:"user_id"`
+	Email   string `json:"email"`
+	Purpose string `json:"purpose,omitempty"` // empty for session tokens
 	jwt.RegisteredClaims
 }
 
 // GenerateToken generates a JWT token for a user
 func GenerateToken(userID uint, email string, cfg *config.Config) (string, error) {
+	return signToken(userID, email, "", cfg.JWT.Expiry, cfg)
+}
+
+// GenerateChallengeToken generates a short-lived token proving the user passed
+// the password step of a two-factor login
+func GenerateChallengeToken(userID uint, email string, cfg *config.Config) (string, error) {
+	return signToken(userID, email, PurposeTwoFactorChallenge, cfg.JWT.ChallengeExpiry, cfg)
+}
+
+// ParseChallengeToken validates a two-factor challenge token and returns its claims
+func ParseChallengeToken(tokenString string, cfg *config.Config) (*Claims, error) {
+	claims, err := parseToken(tokenString, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeTwoFactorChallenge {
+		return nil, fmt.Errorf("not a two-factor challenge token")
+	}
+	return claims, nil
+}
+
+// GenerateStreamTicket generates a short-lived token for opening the event stream
+func GenerateStreamTicket(userID uint, email string, cfg *config.Config) (string, error) {
+	return signToken(userID, email, PurposeStreamTicket, cfg.Stream.TicketExpiry, cfg)
+}
+
+func signToken(userID uint, email, purpose string, expiry time.Duration, cfg *config.Config) (string, error) {
 	claims := Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:  userID,
+		Email:   email,
+		Purpose: purpose,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.JWT.Expiry)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -34,7 +74,26 @@ func GenerateToken(userID uint, email string, cfg *config.Config) (string, error
 	return token.SignedString([]byte(cfg.JWT.Secret))
 }
 
-// AuthMiddleware validates JWT tokens
+func parseToken(tokenString string, cfg *config.Config) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(cfg.JWT.Secret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return claims, nil
+}
+
+// AuthMiddleware validates JWT session tokens and personal access tokens.
+// Personal access tokens are limited to their scopes by RequireScope, and
+// rejected outright by RequireSession.
 func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -54,24 +113,99 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 
 		tokenString := parts[1]
 
-		// Parse and validate token
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JWT.Secret), nil
-		})
+		if strings.HasPrefix(tokenString, models.PersonalAccessTokenPrefix) {
+			authenticatePersonalAccessToken(c, tokenString)
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Parse and validate token; challenge tokens are not session tokens
+		claims, err := parseToken(tokenString, cfg)
+		if err != nil || claims.Purpose != "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			c.Abort()
 			return
 		}
 
+		user, ok := loadActiveUser(c, claims.UserID)
+		if !ok {
+			return
+		}
+
 		// Store user info in context
-		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
+		setUser(c, user.ID, user.Email)
 		c.Next()
 	}
 }
+
+// StreamAuth authenticates the event stream with either a stream ticket in
+// the ticket query parameter or, like AuthMiddleware, the Authorization header
+func StreamAuth(cfg *config.Config) gin.HandlerFunc {
+	headerAuth := AuthMiddleware(cfg)
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			headerAuth(c)
+			return
+		}
+
+		claims, err := parseToken(ticket, cfg)
+		if err != nil || claims.Purpose != PurposeStreamTicket {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+			c.Abort()
+			return
+		}
+
+		user, ok := loadActiveUser(c, claims.UserID)
+		if !ok {
+			return
+		}
+
+		setUser(c, user.ID, user.Email)
+		c.Next()
+	}
+}
+
+// loadActiveUser loads the user a token was issued to. Tokens of deleted
+// accounts stay valid until they expire, so every request checks the
+// account still exists; it answers 401 and reports false if not.
+func loadActiveUser(c *gin.Context, userID uint) (*models.User, bool) {
+	var user models.User
+	if err := database.DB.WithContext(c.Request.Context()).Select("id", "email", "anonymized_at").First(&user, userID).Error; err != nil || user.IsAnonymized() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return nil, false
+	}
+	return &user, true
+}
+
+// lastUsedInterval limits how often a token's last use is written back
+const lastUsedInterval = time.Minute
+
+// authenticatePersonalAccessToken validates a personal access token and
+// stores its owner and scopes in the context
+func authenticatePersonalAccessToken(c *gin.Context, tokenString string) {
+	var token models.PersonalAccessToken
+	err := database.DB.WithContext(c.Request.Context()).Where("token_hash = ?", models.HashPersonalAccessToken(tokenString)).First(&token).Error
+	if err != nil || !token.IsActive() {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	user, ok := loadActiveUser(c, token.UserID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > lastUsedInterval || token.LastUsedIP != c.ClientIP() {
+		database.DB.WithContext(c.Request.Context()).Model(&token).UpdateColumns(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": c.ClientIP(),
+		})
+	}
+
+	setUser(c, user.ID, user.Email)
+	c.Set("token_scopes", token.ScopeList())
+	c.Next()
+}
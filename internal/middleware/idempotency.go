@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyKeyHeader names the header clients set to make a request safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the size of the key column
+const maxIdempotencyKeyLength = 255
+
+// idempotencyLockTimeout is how long the first request is expected to take.
+// A key whose response still isn't stored by then belongs to a request whose
+// outcome is unknown, e.g. because its instance crashed after committing. It
+// is never handled again; retries are told to check before using a new key.
+const idempotencyLockTimeout = time.Minute
+
+// Idempotency makes a route safe to retry. A request with an Idempotency-Key
+// header is handled once per user and key; retries get the stored response,
+// marked with an Idempotent-Replayed header, until ttl has passed. Reusing a
+// key for a different request is rejected with 422, and a retry arriving
+// while the first request is still being handled, or whose outcome is
+// unknown, gets 409. Only successful responses are stored: the handlers roll
+// back whatever they started before answering with an error, so errors and
+// panics release the key and the client can retry with it. Requests without
+// the header are handled as usual. It must run after AuthMiddleware.
+func Idempotency(ttl time.Duration) gin.HandlerFunc {
+	var (
+		mu        sync.Mutex
+		lastSweep time.Time
+	)
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse(
+				"Idempotency-Key must be at most 255 characters",
+				models.ErrCodeInvalidInput,
+			))
+			c.Abort()
+			return
+		}
+
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.NewErrorResponse("Failed to read request body", models.ErrCodeInvalidInput))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		// Releasing or storing the key must not fail because the client went away
+		db := database.DB.WithContext(context.WithoutCancel(c.Request.Context()))
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		// Expired keys are deleted at most once a minute per instance
+		now := time.Now()
+		mu.Lock()
+		sweep := now.Sub(lastSweep) >= time.Minute
+		if sweep {
+			lastSweep = now
+		}
+		mu.Unlock()
+		if sweep {
+			db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{})
+		}
+
+		record, claimed, err := claimIdempotencyKey(c.Request.Context(), userID.(uint), key, fingerprint, ttl)
+		if err != nil {
+			// Fail closed: handling the request anyway could repeat it
+			logging.FromContext(c.Request.Context()).Error("Failed to claim idempotency key", "error", err)
+			c.JSON(http.StatusInternalServerError, models.ErrInternalServer)
+			c.Abort()
+			return
+		}
+
+		if !claimed {
+			switch {
+			case record.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, models.NewErrorResponse(
+					"This Idempotency-Key was already used for a different request",
+					models.ErrCodeConflict,
+				))
+			case !record.Completed() && record.CreatedAt.Before(time.Now().Add(-idempotencyLockTimeout)):
+				c.JSON(http.StatusConflict, models.NewErrorResponse(
+					"The outcome of the request with this Idempotency-Key is unknown; check it before retrying with a new key",
+					models.ErrCodeConflict,
+				))
+			case !record.Completed():
+				c.Header("Retry-After", "1")
+				c.JSON(http.StatusConflict, models.NewErrorResponse(
+					"A request with this Idempotency-Key is still being processed",
+					models.ErrCodeConflict,
+				))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.StatusCode, record.ContentType, record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			// Release the key after a panic so the request can be retried
+			if err := recover(); err != nil {
+				db.Delete(record)
+				panic(err)
+			}
+		}()
+
+		c.Next()
+
+		// Errors committed nothing, so they release the key too. A successful
+		// response follows a committed change, so the key is kept even if
+		// storing the response fails: retries then get 409 instead of
+		// repeating the change.
+		if writer.Status() < http.StatusOK || writer.Status() >= http.StatusMultipleChoices {
+			db.Delete(record)
+			return
+		}
+		if err := db.Model(record).Updates(map[string]interface{}{
+			"status_code":  writer.Status(),
+			"content_type": writer.Header().Get("Content-Type"),
+			"body":         writer.body.Bytes(),
+		}).Error; err != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to store idempotent response", "idempotency_key_id", record.ID, "error", err)
+		}
+	}
+}
+
+// claimIdempotencyKey records the key for a new request and reports true, or
+// returns the existing record if the key is in use. Expired records are
+// replaced.
+func claimIdempotencyKey(ctx context.Context, userID uint, key, fingerprint string, ttl time.Duration) (*models.IdempotencyKey, bool, error) {
+	db := database.DB.WithContext(ctx)
+
+	// A few attempts cover losing races against other retries of the key
+	for attempt := 0; attempt < 3; attempt++ {
+		now := time.Now()
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(ttl),
+		}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected == 1 {
+			return &record, true, nil
+		}
+
+		var existing models.IdempotencyKey
+		err := db.Where(&models.IdempotencyKey{UserID: userID, Key: key}).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // released in the meantime
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if existing.ExpiresAt.After(now) {
+			return &existing, false, nil
+		}
+		if err := db.Delete(&existing).Error; err != nil {
+			return nil, false, err
+		}
+	}
+	return nil, false, errors.New("idempotency key keeps changing")
+}
+
+// requestFingerprint identifies a request by its method, path and body
+func requestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, method+" "+path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -1,71 +1,102 @@
 package middleware
 
 import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// RequestLogger is a middleware that logs HTTP requests with request IDs
-func RequestLogger() gin.HandlerFunc {
+// RequestIDHeader carries the request ID. An ID sent by the client or a proxy
+// in front of the API is kept, so a request can be followed across them.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits incoming request IDs to short, printable tokens so
+// they can't be used to forge log lines
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestLogger gives each request an ID and a logger carrying it, and logs
+// the request once it has been handled. The logger is stored in the request's
+// context for logging.FromContext; it also names the trace, the circle on
+// circle routes and, once authenticated, the user. Only tracing.Middleware
+// may run before it.
+func RequestLogger(logger *slog.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Generate unique request ID
-		requestID := uuid.New().String()
-		c.Set("request_id", requestID)
-		c.Header("X-Request-ID", requestID)
+		start := time.Now()
 
-		// Start timer
-		startTime := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
 
-		// Log request
-		path := c.Request.URL.Path
-		method := c.Request.Method
-		clientIP := c.ClientIP()
+		attrs := []any{"request_id", requestID}
+		if span := trace.SpanContextFromContext(c.Request.Context()); span.IsValid() {
+			attrs = append(attrs, "trace_id", span.TraceID().String())
+		}
+		if strings.HasPrefix(c.FullPath(), "/api/v1/circles/:id") {
+			if circleID, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil {
+				attrs = append(attrs, "circle_id", circleID)
+			}
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(c.Request.Context(), logger.With(attrs...)))
 
-		// Process request
 		c.Next()
 
-		// Calculate latency
-		latency := time.Since(startTime)
-		statusCode := c.Writer.Status()
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
 
-		// Log response
+		// The query string is left out: it can carry tokens and stream tickets
+		fields := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("client_ip", c.ClientIP()),
+		}
 		if len(c.Errors) > 0 {
-			// Log errors
-			for _, e := range c.Errors {
-				gin.DefaultErrorWriter.Write([]byte(e.Error() + "\n"))
-			}
+			fields = append(fields, slog.String("errors", c.Errors.String()))
 		}
-
-		// Structured log output
-		gin.DefaultWriter.Write([]byte(
-			formatLogEntry(requestID, method, path, statusCode, latency, clientIP),
-		))
+		ctx := c.Request.Context()
+		logging.FromContext(ctx).LogAttrs(ctx, level, "Request handled", fields...)
 	}
 }
 
-func formatLogEntry(requestID, method, path string, status int, latency time.Duration, ip string) string {
-	return time.Now().Format("2006/01/02 15:04:05") +
-		" | " + requestID +
-		" | " + method +
-		" | " + path +
-		" | " + formatStatus(status) +
-		" | " + latency.String() +
-		" | " + ip + "\n"
+// Recovery turns a panic in a handler into a 500 response and logs it, with
+// its stack, through the request's logger
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		logging.FromContext(c.Request.Context()).Error("Panic while handling request",
+			"error", fmt.Sprint(err),
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrInternalServer)
+	})
 }
 
-func formatStatus(status int) string {
-	switch {
-	case status >= 500:
-		return "\033[31m" + string(rune(status)) + "\033[0m" // Red
-	case status >= 400:
-		return "\033[33m" + string(rune(status)) + "\033[0m" // Yellow
-	case status >= 300:
-		return "\033[36m" + string(rune(status)) + "\033[0m" // Cyan
-	case status >= 200:
-		return "\033[32m" + string(rune(status)) + "\033[0m" // Green
-	default:
-		return string(rune(status))
-	}
+// setUser stores the authenticated user in the context and adds them to the
+// request's logger
+func setUser(c *gin.Context, userID uint, email string) {
+	c.Set("user_id", userID)
+	c.Set("email", email)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user_id", userID))
 }
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit limits how often a single client IP can call the route
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	cfg := limiter.Config()
+	return func(c *gin.Context) {
+		key := "ip:" + c.FullPath() + ":" + c.ClientIP()
+
+		wait, err := limiter.Allow(c.Request.Context(), key, cfg.IPRequests, cfg.IPWindow)
+		if err != nil {
+			// Fail open: a broken limiter store should not take down login
+			logging.FromContext(c.Request.Context()).Error("Failed to check rate limit", "limit_key", key, "error", err)
+			c.Next()
+			return
+		}
+
+		if wait > 0 {
+			TooManyRequests(c, wait, models.ErrTooManyRequests)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TooManyRequests writes a 429 response with a Retry-After header
+func TooManyRequests(c *gin.Context, wait time.Duration, resp *models.ErrorResponse) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, resp)
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope lets personal access tokens through only if they were granted
+// the scope. Session tokens carry no scopes and are always allowed. It must
+// run after AuthMiddleware.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := tokenScopes(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		for _, granted := range scopes {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, models.NewErrorResponse(
+			"Token is missing the "+scope+" scope",
+			models.ErrCodePermission,
+		))
+		c.Abort()
+	}
+}
+
+// RequireSession rejects personal access tokens, keeping account management
+// out of reach of integration tokens. It must run after AuthMiddleware.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := tokenScopes(c); ok {
+			c.JSON(http.StatusForbidden, models.NewErrorResponse(
+				"This endpoint cannot be used with a personal access token",
+				models.ErrCodePermission,
+			))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// tokenScopes returns the scopes of the personal access token that
+// authenticated the request; ok is false for session tokens
+func tokenScopes(c *gin.Context) (scopes []string, ok bool) {
+	value, exists := c.Get("token_scopes")
+	if !exists {
+		return nil, false
+	}
+	scopes, ok = value.([]string)
+	return scopes, ok
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail rejects requests from users who have not yet
+// confirmed their email address. It must run after AuthMiddleware.
+func RequireVerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := c.Get("user_id")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := database.DB.WithContext(c.Request.Context()).Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrUnauthorized)
+			c.Abort()
+			return
+		}
+
+		if !user.IsEmailVerified() {
+			c.JSON(http.StatusForbidden, models.ErrEmailNotVerified)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -8,17 +8,23 @@ import (
 
 // Circle represents a group/circle in the system
 type Circle struct {
-	ID              uint           `gorm:"primarykey" json:"id"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
-	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
-	Name            string         `gorm:"not null" json:"name"`
-	Description     string         `json:"description"`
-	AmountPerMember uint           `gorm:"not null;default:0" json:"amount_per_member"`
-	ProposedAmount  uint           `gorm:"default:0" json:"proposed_amount"`
-	CreatorID       uint           `gorm:"not null" json:"creator_id"`
-	Creator         User           `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
-	Members         []User         `gorm:"many2many:circle_members;" json:"members,omitempty"`
+	ID                  uint           `gorm:"primarykey" json:"id"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+	Name                string         `gorm:"not null" json:"name"`
+	Description         string         `json:"description"`
+	AmountPerMember     uint           `gorm:"not null;default:0" json:"amount_per_member"`
+	ProposedAmount      uint           `gorm:"default:0" json:"proposed_amount"`
+	CreatorID           uint           `gorm:"not null" json:"creator_id"`
+	RequireTwoFactor    bool           `gorm:"not null;default:false" json:"require_two_factor"`
+	PaymentDueDay       int            `gorm:"not null;default:1" json:"payment_due_day"` // day of the month contributions are due, 1-28
+	RemindersEnabled    bool           `gorm:"not null;default:true" json:"reminders_enabled"`
+	ReminderDaysBefore  int            `gorm:"not null;default:3" json:"reminder_days_before"`  // days before the due date to remind; 0 for none
+	OverdueReminderDays int            `gorm:"not null;default:7" json:"overdue_reminder_days"` // days between overdue reminders; 0 for a single one
+	Version             uint           `gorm:"not null;default:1" json:"version"`               // incremented by every change
+	Creator             User           `gorm:"foreignKey:CreatorID" json:"creator,omitempty"`
+	Members             []User         `gorm:"many2many:circle_members;" json:"members,omitempty"`
 }
 
 // TableName specifies the table name for Circle
@@ -26,14 +32,24 @@ func (Circle) TableName() string {
 	return "circles"
 }
 
+// MonthsDue returns how many monthly contributions fall due between joining
+// a circle and now, counting the joining month and the current month
+func MonthsDue(joined, now time.Time) int {
+	if now.Before(joined) {
+		return 0
+	}
+	months := (now.Year()-joined.Year())*12 + int(now.Month()) - int(joined.Month())
+	return months + 1
+}
+
 // CircleMember represents the join table for circles and users
 type CircleMember struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
-	CircleID  uint           `gorm:"not null;index:idx_circle_user,priority:1;index:idx_circle_status,priority:1" json:"circle_id"`
-	UserID    uint           `gorm:"not null;index:idx_circle_user,priority:2" json:"user_id"`
-	Role      string         `gorm:"not null;default:'member'" json:"role"`                                      // admin, member
-	Status    string         `gorm:"not null;default:'active';index:idx_circle_status,priority:2" json:"status"` // pending, active
+	CircleID  uint           `gorm:"not null;uniqueIndex:idx_circle_user,priority:1;index:idx_circle_status,priority:1" json:"circle_id"`
+	UserID    uint           `gorm:"not null;uniqueIndex:idx_circle_user,priority:2" json:"user_id"`
+	Role      string         `gorm:"not null;default:'member';check:chk_circle_members_role,role IN ('admin', 'member')" json:"role"`
+	Status    string         `gorm:"not null;default:'active';index:idx_circle_status,priority:2;check:chk_circle_members_status,status IN ('pending', 'active')" json:"status"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
@@ -42,12 +58,14 @@ func (CircleMember) TableName() string {
 	return "circle_members"
 }
 
-// MemberApproval tracks individual member votes for a new applicant
+// MemberApproval tracks individual member votes for a new applicant.
+// Both users must be members of the circle; the foreign keys enforcing that
+// are declared in the migrations.
 type MemberApproval struct {
 	ID             uint           `gorm:"primarykey" json:"id"`
-	CircleID       uint           `gorm:"not null;index:idx_member_approval,priority:1" json:"circle_id"`
-	PendingUserID  uint           `gorm:"not null;index:idx_member_approval,priority:2" json:"pending_user_id"`
-	ApproverUserID uint           `gorm:"not null;index:idx_member_approval,priority:3" json:"approver_user_id"`
+	CircleID       uint           `gorm:"not null;uniqueIndex:idx_member_approval,priority:1" json:"circle_id"`
+	PendingUserID  uint           `gorm:"not null;uniqueIndex:idx_member_approval,priority:2" json:"pending_user_id"`
+	ApproverUserID uint           `gorm:"not null;uniqueIndex:idx_member_approval,priority:3" json:"approver_user_id"`
 	Approved       bool           `gorm:"default:false" json:"approved"`
 	CreatedAt      time.Time      `json:"created_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
@@ -64,13 +82,15 @@ type Contribution struct {
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// AmountApproval tracks individual member votes for a proposed amount change
+// AmountApproval tracks individual member votes for a proposed amount change.
+// The approver must be a member of the circle; the foreign key enforcing that
+// is declared in the migrations.
 type AmountApproval struct {
 	ID             uint           `gorm:"primarykey" json:"id"`
-	CircleID       uint           `gorm:"not null;index:idx_amount_approval,priority:1" json:"circle_id"`
+	CircleID       uint           `gorm:"not null;uniqueIndex:idx_amount_approval,priority:1" json:"circle_id"`
 	ProposerID     uint           `gorm:"not null" json:"proposer_id"`
 	ProposedAmount uint           `gorm:"not null" json:"proposed_amount"`
-	ApproverID     uint           `gorm:"not null;index:idx_amount_approval,priority:2" json:"approver_id"`
+	ApproverID     uint           `gorm:"not null;uniqueIndex:idx_amount_approval,priority:2" json:"approver_id"`
 	Approved       bool           `gorm:"default:false" json:"approved"`
 	CreatedAt      time.Time      `json:"created_at"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonthsDue(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		joined   time.Time
+		now      time.Time
+		expected int
+	}{
+		{"same month", date(2025, 3, 1), date(2025, 3, 31), 1},
+		{"next month", date(2025, 3, 31), date(2025, 4, 1), 2},
+		{"across years", date(2024, 11, 15), date(2025, 2, 1), 4},
+		{"joined in the future", date(2025, 5, 1), date(2025, 4, 1), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MonthsDue(tt.joined, tt.now))
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationToken represents a pending email address verification
+type EmailVerificationToken struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"`
+	Email     string         `gorm:"not null;default:''" json:"email"` // address being verified
+	Token     string         `gorm:"not null;uniqueIndex" json:"-"`    // SHA-256 of the emailed token
+	ExpiresAt time.Time      `gorm:"not null" json:"expires_at"`
+	Used      bool           `gorm:"default:false" json:"used"`
+	CreatedAt time.Time      `json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// IsValid checks if the token is still valid
+func (t *EmailVerificationToken) IsValid() bool {
+	return !t.Used && time.Now().Before(t.ExpiresAt)
+}
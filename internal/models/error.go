@@ -9,6 +9,8 @@ const (
 	ErrCodeUnauthorized ErrorCode = "UNAUTHORIZED"
 	ErrCodeInvalidToken ErrorCode = "INVALID_TOKEN"
 	ErrCodeExpiredToken ErrorCode = "EXPIRED_TOKEN"
+	ErrCodeUnverified   ErrorCode = "EMAIL_NOT_VERIFIED"
+	ErrCodeTwoFactor    ErrorCode = "TWO_FACTOR_REQUIRED"
 
 	// Validation errors
 	ErrCodeValidation   ErrorCode = "VALIDATION_ERROR"
@@ -24,6 +26,10 @@ const (
 	ErrCodeForbidden  ErrorCode = "FORBIDDEN"
 	ErrCodePermission ErrorCode = "PERMISSION_DENIED"
 
+	// Rate limiting errors
+	ErrCodeRateLimited   ErrorCode = "RATE_LIMITED"
+	ErrCodeAccountLocked ErrorCode = "ACCOUNT_LOCKED"
+
 	// Server errors
 	ErrCodeInternal ErrorCode = "INTERNAL_ERROR"
 	ErrCodeDatabase ErrorCode = "DATABASE_ERROR"
@@ -82,4 +88,29 @@ var (
 		Error: "You do not have permission to perform this action",
 		Code:  ErrCodeForbidden,
 	}
+
+	ErrEmailNotVerified = &ErrorResponse{
+		Error: "Please verify your email address before continuing",
+		Code:  ErrCodeUnverified,
+	}
+
+	ErrTwoFactorRequired = &ErrorResponse{
+		Error: "This circle requires two-factor authentication to be enabled on your account",
+		Code:  ErrCodeTwoFactor,
+	}
+
+	ErrTooManyRequests = &ErrorResponse{
+		Error: "Too many requests, please try again later",
+		Code:  ErrCodeRateLimited,
+	}
+
+	ErrAccountLocked = &ErrorResponse{
+		Error: "This account is temporarily locked after too many failed attempts. Check your email to unlock it",
+		Code:  ErrCodeAccountLocked,
+	}
+
+	ErrInvalidTwoFactorCode = &ErrorResponse{
+		Error: "Invalid two-factor authentication code",
+		Code:  ErrCodeAuth,
+	}
 )
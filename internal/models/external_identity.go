@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_external_identity_subject" json:"provider"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_external_identity_subject" json:"-"` // the provider's "sub" claim
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OIDCState holds the per-login values for an in-flight OpenID Connect
+// authorization request. It is consumed by the callback.
+type OIDCState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	State        string    `gorm:"not null;uniqueIndex" json:"-"`
+	Provider     string    `gorm:"not null" json:"provider"`
+	Nonce        string    `gorm:"not null" json:"-"`
+	CodeVerifier string    `gorm:"not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName pins the table name; GORM would otherwise use "o_id_c_states"
+func (OIDCState) TableName() string {
+	return "oidc_states"
+}
+
+// IsValid checks if the state has not expired
+func (s *OIDCState) IsValid() bool {
+	return time.Now().Before(s.ExpiresAt)
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey remembers a request sent with an Idempotency-Key header and
+// the response to it, so a retry gets the original response instead of
+// repeating the request
+type IdempotencyKey struct {
+	ID          uint   `gorm:"primarykey"`
+	UserID      uint   `gorm:"not null;uniqueIndex:idx_idempotency_user_key,priority:1"`
+	Key         string `gorm:"not null;size:255;uniqueIndex:idx_idempotency_user_key,priority:2"`
+	Fingerprint string `gorm:"not null"` // SHA-256 of the method, path and body
+	StatusCode  int    // zero while the first request is still being handled
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// TableName specifies the table name for IdempotencyKey
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Completed reports whether the response to the first request was stored
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
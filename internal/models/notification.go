@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Notification types
+const (
+	NotificationCircleInvite    = "circle_invite"    // invited to a circle, or accepted into one
+	NotificationVoteNeeded      = "vote_needed"      // a membership or amount vote awaits the user
+	NotificationPaymentDue      = "payment_due"      // a monthly contribution is due or overdue
+	NotificationProposalOutcome = "proposal_outcome" // an amount proposal was decided
+)
+
+// NotificationTypes lists every notification type
+var NotificationTypes = []string{
+	NotificationCircleInvite,
+	NotificationVoteNeeded,
+	NotificationPaymentDue,
+	NotificationProposalOutcome,
+}
+
+// IsValidNotificationType reports whether t is a known notification type
+func IsValidNotificationType(t string) bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Notification is an in-app message for one user
+type Notification struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_notification_source,priority:1;index:idx_notification_user_read,priority:1" json:"-"`
+	Type      string     `gorm:"not null" json:"type"`
+	CircleID  *uint      `gorm:"index" json:"circle_id"`
+	Title     string     `gorm:"not null" json:"title"`
+	Body      string     `gorm:"type:text" json:"body"`
+	SourceID  string     `gorm:"not null;uniqueIndex:idx_notification_source,priority:2" json:"-"` // what caused it, e.g. an event ID; one notification per source and user
+	ReadAt    *time.Time `gorm:"index:idx_notification_user_read,priority:2" json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// NotificationPreference records a user's choice for one notification type.
+// Types without a preference are delivered.
+type NotificationPreference struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_notification_preference" json:"-"`
+	Type      string    `gorm:"not null;uniqueIndex:idx_notification_preference" json:"type"`
+	InApp     bool      `gorm:"not null" json:"in_app"`
+	UpdatedAt time.Time `json:"-"`
+}
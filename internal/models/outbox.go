@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// Outbox message statuses
+const (
+	OutboxPending   = "pending"
+	OutboxPublished = "published"
+	OutboxDead      = "dead" // dead-lettered after the maximum number of attempts
+)
+
+// OutboxMessage is an event recorded in the same transaction as the change it
+// describes, waiting to be published to the registered sinks
+type OutboxMessage struct {
+	ID            uint64     `gorm:"primarykey" json:"id"`
+	EventID       string     `gorm:"not null;uniqueIndex" json:"event_id"`
+	EventType     string     `gorm:"not null" json:"event_type"`
+	CircleID      uint       `gorm:"not null;index:idx_outbox_circle_status,priority:1" json:"circle_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"not null;default:'pending';index:idx_outbox_circle_status,priority:2;index:idx_outbox_status_due,priority:1" json:"status"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index:idx_outbox_status_due,priority:2" json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	PublishedAt   *time.Time `json:"published_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName specifies the table name for OutboxMessage
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
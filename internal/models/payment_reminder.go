@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Payment reminder kinds
+const (
+	ReminderUpcoming = "upcoming" // the next contribution is due soon
+	ReminderOverdue  = "overdue"  // a contribution is past its due date
+)
+
+// PaymentReminder records a reminder sent to a member through one channel,
+// so the same reminder is never sent twice
+type PaymentReminder struct {
+	ID       uint      `gorm:"primarykey" json:"id"`
+	CircleID uint      `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:1" json:"circle_id"`
+	UserID   uint      `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:2;index" json:"user_id"`
+	Period   time.Time `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:3" json:"period"` // first day of the month the contribution is for
+	Kind     string    `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:4" json:"kind"`
+	Sequence int       `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:5" json:"sequence"` // counts repeated overdue reminders
+	Channel  string    `gorm:"not null;uniqueIndex:idx_payment_reminder,priority:6" json:"channel"`
+	SentAt   time.Time `gorm:"not null" json:"sent_at"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalAccessTokenHasScope(t *testing.T) {
+	token := &PersonalAccessToken{Scopes: ScopeCirclesRead + " " + ScopeVotesWrite}
+
+	assert.True(t, token.HasScope(ScopeCirclesRead))
+	assert.True(t, token.HasScope(ScopeVotesWrite))
+	assert.False(t, token.HasScope(ScopeContributionsWrite))
+	assert.False(t, token.HasScope("circles"))
+}
+
+func TestPersonalAccessTokenIsActive(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		token    PersonalAccessToken
+		expected bool
+	}{
+		{"no expiry", PersonalAccessToken{}, true},
+		{"not yet expired", PersonalAccessToken{ExpiresAt: &future}, true},
+		{"expired", PersonalAccessToken{ExpiresAt: &past}, false},
+		{"revoked", PersonalAccessToken{ExpiresAt: &future, RevokedAt: &past}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.token.IsActive())
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PhoneVerification is a one-time code texted to a phone number the user is
+// adding. Each user has at most one pending code.
+type PhoneVerification struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	UserID    uint      `gorm:"not null;uniqueIndex" json:"-"`
+	Phone     string    `gorm:"not null" json:"phone"`       // number being verified, E.164
+	Code      string    `gorm:"not null" json:"-"`           // SHA-256 of the texted code
+	Attempts  int       `gorm:"not null;default:0" json:"-"` // wrong codes entered so far
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// IsValid checks if the code can still be used
+func (v *PhoneVerification) IsValid(maxAttempts int) bool {
+	return v.Attempts < maxAttempts && time.Now().Before(v.ExpiresAt)
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// RateLimit stores a shared rate limit counter for the Postgres-backed limiter
+type RateLimit struct {
+	Key          string     `gorm:"primaryKey;size:255"`
+	Count        int        `gorm:"not null;default:0"`
+	ResetAt      time.Time  `gorm:"not null;index"`
+	BlockedUntil *time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for RateLimit
+func (RateLimit) TableName() string {
+	return "rate_limits"
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a single-use code that can replace a TOTP code at login
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"not null" json:"-"` // bcrypt hash of the code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SMSNotification records a text message sent to a user for an event, so
+// redelivered events do not text anyone twice
+type SMSNotification struct {
+	ID        uint   `gorm:"primarykey"`
+	UserID    uint   `gorm:"not null;uniqueIndex:idx_sms_notification,priority:1"`
+	SourceID  string `gorm:"not null;uniqueIndex:idx_sms_notification,priority:2"` // the event that caused it
+	CreatedAt time.Time
+}
+
+// TableName specifies the table name for SMSNotification
+func (SMSNotification) TableName() string {
+	return "sms_notifications"
+}
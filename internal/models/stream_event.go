@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// StreamEvent is a circle event in the real-time stream. IDs increase in
+// commit order, so clients resume after a disconnect by asking for the events
+// after the last ID they saw.
+type StreamEvent struct {
+	ID        uint64    `gorm:"primarykey" json:"id"`
+	EventID   string    `gorm:"not null;uniqueIndex" json:"event_id"`
+	EventType string    `gorm:"not null" json:"event_type"`
+	CircleID  uint      `gorm:"not null;index" json:"circle_id"`
+	Payload   string    `gorm:"type:text;not null" json:"payload"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName specifies the table name for StreamEvent
+func (StreamEvent) TableName() string {
+	return "stream_events"
+}
@@ -3,35 +3,111 @@ package models
 import (
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/Sudan23/dhukuti/internal/password"
 	"gorm.io/gorm"
 )
 
+// FormerMemberName replaces the name of users who deleted their account
+const FormerMemberName = "Former member"
+
+// Digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primarykey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string         `gorm:"not null" json:"-"`
-	Name      string         `gorm:"not null" json:"name"`
-	Circles   []Circle       `gorm:"many2many:circle_members;" json:"circles,omitempty"`
+	ID              uint           `gorm:"primarykey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Email           string         `gorm:"uniqueIndex;not null" json:"email"`
+	Password        string         `gorm:"not null" json:"-"`
+	Name            string         `gorm:"not null" json:"name"`
+	EmailVerifiedAt *time.Time     `json:"email_verified_at"`
+	AvatarURL       string         `json:"avatar_url"`
+	Phone           string         `gorm:"not null;default:''" json:"phone"` // E.164, set once verified
+	PhoneVerifiedAt *time.Time     `json:"phone_verified_at"`
+	DigestFrequency string         `gorm:"not null;default:'off'" json:"digest_frequency"` // off, daily or weekly
+	TimeZone        string         `gorm:"not null;default:'UTC'" json:"time_zone"`        // IANA name, e.g. Asia/Kathmandu
+	DigestSentAt    *time.Time     `json:"-"`                                              // scheduled time of the last digest
+	TOTPSecret      string         `json:"-"`                                              // base32 secret, set during enrollment
+	TOTPEnabledAt   *time.Time     `json:"two_factor_enabled_at"`                          // nil until enrollment is confirmed
+	TOTPLastStep    int64          `gorm:"default:0" json:"-"`                             // last accepted time step, prevents replay
+	LockedUntil     *time.Time     `json:"-"`                                              // set after repeated failed logins
+	AnonymizedAt    *time.Time     `json:"-"`                                              // set when the account is deleted
+	Circles         []Circle       `gorm:"many2many:circle_members;" json:"circles,omitempty"`
+}
+
+// NoPassword is stored for accounts without a password, e.g. ones created by
+// signing in with an identity provider. It is not a valid hash, so it never
+// matches.
+const NoPassword = "!"
+
+// HasPassword reports whether the user has set a password
+func (u *User) HasPassword() bool {
+	return u.Password != NoPassword
 }
 
 // HashPassword hashes the user's password
-func (u *User) HashPassword(password string) error {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (u *User) HashPassword(plain string) error {
+	hashedPassword, err := password.Hash(plain)
 	if err != nil {
 		return err
 	}
-	u.Password = string(hashedPassword)
+	u.Password = hashedPassword
 	return nil
 }
 
 // CheckPassword checks if the provided password matches the user's password
-func (u *User) CheckPassword(password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+func (u *User) CheckPassword(plain string) error {
+	return password.Verify(plain, u.Password)
+}
+
+// PasswordNeedsRehash reports whether the stored hash uses an outdated
+// algorithm or parameters
+func (u *User) PasswordNeedsRehash() bool {
+	return password.NeedsRehash(u.Password)
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsPhoneVerified reports whether the user has a confirmed phone number for
+// text messages
+func (u *User) IsPhoneVerified() bool {
+	return u.PhoneVerifiedAt != nil && u.Phone != ""
+}
+
+// Location returns the user's time zone, falling back to UTC
+func (u *User) Location() *time.Location {
+	if u.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsTwoFactorEnabled reports whether the user has confirmed TOTP enrollment
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != ""
+}
+
+// IsLocked reports whether the account is temporarily locked
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
+}
+
+// IsAnonymized reports whether the account has been deleted and anonymised
+func (u *User) IsAnonymized() bool {
+	return u.AnonymizedAt != nil
 }
 
 // TableName specifies the table name for User
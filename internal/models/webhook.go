@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed" // gave up after the maximum number of attempts
+)
+
+// Webhook is a user's subscription to circle events. A nil CircleID
+// subscribes to every circle the user is an active member of.
+type Webhook struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	UserID    uint           `gorm:"not null;index" json:"user_id"`
+	CircleID  *uint          `gorm:"index" json:"circle_id"`
+	URL       string         `gorm:"not null" json:"url"`
+	Secret    string         `gorm:"not null" json:"-"` // HMAC signing key, needed in plain text to sign
+	Events    string         `gorm:"not null" json:"-"` // space separated event types; empty for all
+	Active    bool           `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// EventList returns the subscribed event types; empty means all
+func (w *Webhook) EventList() []string {
+	return strings.Fields(w.Events)
+}
+
+// Subscribes reports whether the webhook wants events of the given type
+func (w *Webhook) Subscribes(eventType string) bool {
+	events := w.EventList()
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the delivery log entry for one event sent to one webhook
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	WebhookID      uint       `gorm:"not null;index" json:"webhook_id"`
+	EventID        string     `gorm:"not null;index" json:"event_id"`
+	EventType      string     `gorm:"not null" json:"event_type"`
+	Payload        string     `gorm:"type:text;not null" json:"-"`
+	Status         string     `gorm:"not null;default:'pending';index:idx_webhook_delivery_due,priority:1" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  time.Time  `gorm:"not null;index:idx_webhook_delivery_due,priority:2" json:"next_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,150 @@
+// Package notification creates in-app notifications for users
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Notify stores a notification unless the user turned its type off. It is
+// idempotent: a second notification with the same user and SourceID is
+// ignored. It reports whether a notification was created.
+func Notify(db *gorm.DB, n *models.Notification) (bool, error) {
+	enabled, err := Enabled(db, n.UserID, n.Type)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(n)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to create notification: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Enabled reports whether the user wants in-app notifications of a type
+func Enabled(db *gorm.DB, userID uint, notificationType string) (bool, error) {
+	var preference models.NotificationPreference
+	err := db.Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return preference.InApp, nil
+}
+
+// Sink turns circle events into notifications. It is an outbox sink.
+type Sink struct {
+	db *gorm.DB
+}
+
+// NewSink creates a notification sink
+func NewSink(db *gorm.DB) *Sink {
+	return &Sink{db: db}
+}
+
+// Name implements outbox.Sink
+func (s *Sink) Name() string {
+	return "notifications"
+}
+
+// Handle implements outbox.Sink
+func (s *Sink) Handle(ctx context.Context, event events.Event) error {
+	db := s.db.WithContext(ctx)
+
+	var circle models.Circle
+	if err := db.First(&circle, event.CircleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // circle deleted since
+		}
+		return err
+	}
+
+	circleID := circle.ID
+	notify := func(userID uint, notificationType, title, body string) error {
+		if userID == 0 {
+			return nil
+		}
+		_, err := Notify(db, &models.Notification{
+			UserID:   userID,
+			Type:     notificationType,
+			CircleID: &circleID,
+			Title:    title,
+			Body:     body,
+			SourceID: event.ID,
+		})
+		return err
+	}
+
+	switch event.Type {
+	case events.MemberAdded:
+		invitee := event.Uint("user_id")
+		if err := notify(invitee, models.NotificationCircleInvite,
+			"You've been invited to "+circle.Name,
+			"You will join once every member has approved you."); err != nil {
+			return err
+		}
+
+		var name string
+		db.Model(&models.User{}).Where("id = ?", invitee).Pluck("name", &name)
+		var voters []uint
+		if err := db.Model(&models.MemberApproval{}).
+			Where("circle_id = ? AND pending_user_id = ? AND approved = ?", circle.ID, invitee, false).
+			Pluck("approver_user_id", &voters).Error; err != nil {
+			return err
+		}
+		for _, voter := range voters {
+			if err := notify(voter, models.NotificationVoteNeeded,
+				name+" wants to join "+circle.Name,
+				"Approve them from the circle page."); err != nil {
+				return err
+			}
+		}
+
+	case events.MemberApproved:
+		return notify(event.Uint("user_id"), models.NotificationCircleInvite,
+			"Welcome to "+circle.Name,
+			"Every member approved you. You can now contribute and vote.")
+
+	case events.AmountProposed:
+		var voters []uint
+		if err := db.Model(&models.AmountApproval{}).
+			Where("circle_id = ? AND approved = ?", circle.ID, false).
+			Pluck("approver_id", &voters).Error; err != nil {
+			return err
+		}
+		for _, voter := range voters {
+			if err := notify(voter, models.NotificationVoteNeeded,
+				"New contribution amount proposed for "+circle.Name,
+				fmt.Sprintf("The proposal changes the monthly contribution from %d to %d.",
+					event.Uint("current_amount"), event.Uint("proposed_amount"))); err != nil {
+				return err
+			}
+		}
+
+	case events.AmountChanged:
+		var members []uint
+		if err := db.Model(&models.CircleMember{}).
+			Where("circle_id = ? AND status = ?", circle.ID, "active").
+			Pluck("user_id", &members).Error; err != nil {
+			return err
+		}
+		for _, member := range members {
+			if err := notify(member, models.NotificationProposalOutcome,
+				"Contribution amount changed in "+circle.Name,
+				fmt.Sprintf("All members approved. The monthly contribution is now %d.",
+					event.Uint("amount"))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
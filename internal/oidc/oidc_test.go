@@ -0,0 +1,108 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/oidc"
+	"github.com/Sudan23/dhukuti/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:8080/api/v1/auth/oidc/mock/callback"
+
+func newProvider(t *testing.T) (*oidctest.Provider, *oidc.Provider) {
+	mock := oidctest.NewProvider("dhukuti")
+	t.Cleanup(mock.Close)
+
+	provider := oidc.NewProvider(config.OIDCProviderConfig{
+		Name:     "mock",
+		Issuer:   mock.Issuer(),
+		ClientID: "dhukuti",
+		Scopes:   []string{"openid", "email"},
+	}, redirectURL, nil)
+	return mock, provider
+}
+
+// authorize follows the authorization URL and returns the code from the redirect
+func authorize(t *testing.T, authURL string) url.Values {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	_, provider := newProvider(t)
+	ctx := context.Background()
+
+	verifier, err := oidc.RandomString()
+	require.NoError(t, err)
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	require.NoError(t, err)
+
+	callback := authorize(t, authURL)
+	assert.Equal(t, "state-1", callback.Get("state"))
+
+	tokens, err := provider.Exchange(ctx, callback.Get("code"), verifier)
+	require.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(ctx, tokens.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "subject-1", claims.Subject)
+	assert.Equal(t, "user@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	_, provider := newProvider(t)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier-a")
+	require.NoError(t, err)
+
+	callback := authorize(t, authURL)
+	_, err = provider.Exchange(ctx, callback.Get("code"), "verifier-b")
+	assert.Error(t, err)
+}
+
+func TestVerifyIDTokenRejectsWrongNonce(t *testing.T) {
+	mock, provider := newProvider(t)
+
+	idToken, err := mock.SignIDToken("expected")
+	require.NoError(t, err)
+
+	_, err = provider.VerifyIDToken(context.Background(), idToken, "other")
+	assert.Error(t, err)
+}
+
+func TestVerifyIDTokenRejectsOtherAudience(t *testing.T) {
+	mock, provider := newProvider(t)
+	mock.ClientID = "someone-else"
+
+	idToken, err := mock.SignIDToken("nonce")
+	require.NoError(t, err)
+
+	_, err = provider.VerifyIDToken(context.Background(), idToken, "nonce")
+	assert.Error(t, err)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 Appendix B
+	assert.Equal(t,
+		"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"),
+	)
+}
@@ -0,0 +1,217 @@
+// Package outbox implements the transactional outbox pattern for circle
+// events.
+//
+// Handlers record events with Publisher in the same database transaction as
+// the change they describe, so an event exists if and only if the change
+// committed. The Dispatcher then hands each message to every registered Sink,
+// retrying failures with exponential backoff:
+//
+//   - delivery is at least once: a sink may see an event again after a crash
+//     or a failure in another sink, so sinks must be idempotent (deduplicate
+//     on the event ID)
+//   - events of one circle are published in the order they were recorded; a
+//     failing message holds back later messages of its circle until it
+//     succeeds or is dead-lettered
+//   - messages that still fail after the maximum number of attempts are
+//     marked dead and kept, with the last error, for inspection
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is the maximum number of messages claimed at once
+const batchSize = 50
+
+// Sink receives published events
+type Sink interface {
+	Name() string
+	Handle(ctx context.Context, event events.Event) error
+}
+
+// Publisher records events in the outbox
+type Publisher struct{}
+
+// NewPublisher creates an outbox publisher
+func NewPublisher() *Publisher {
+	return &Publisher{}
+}
+
+// Publish implements events.Publisher
+func (p *Publisher) Publish(tx *gorm.DB, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return tx.Create(&models.OutboxMessage{
+		EventID:       event.ID,
+		EventType:     string(event.Type),
+		CircleID:      event.CircleID,
+		Payload:       string(payload),
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// Dispatcher publishes outbox messages to the registered sinks
+type Dispatcher struct {
+	db    *gorm.DB
+	cfg   config.OutboxConfig
+	sinks []Sink
+}
+
+// NewDispatcher creates a dispatcher publishing to sinks
+func NewDispatcher(db *gorm.DB, cfg config.OutboxConfig, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{db: db, cfg: cfg, sinks: sinks}
+}
+
+// Register adds a sink
+func (d *Dispatcher) Register(sink Sink) {
+	d.sinks = append(d.sinks, sink)
+}
+
+// Run publishes messages until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Time{}
+	for {
+		// Keep going while there is a backlog
+		for {
+			n, err := d.DispatchOnce(ctx)
+			if err != nil {
+				slog.Error("Outbox dispatch failed", "error", err)
+			}
+			if err != nil || n == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) > time.Hour {
+			d.cleanup()
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchOnce publishes one batch of due messages and returns how many it
+// processed. Only the oldest pending message of each circle is eligible, which
+// keeps per-circle ordering. Claimed rows stay locked until the batch is done,
+// so concurrent dispatchers on other instances skip them.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	// SQLite has no row locks and a single connection, which the sinks
+	// need too; a single instance needs no claim
+	claim := d.db.Transaction
+	if !database.IsPostgres(d.db) {
+		claim = func(fn func(tx *gorm.DB) error, _ ...*sql.TxOptions) error { return fn(d.db) }
+	}
+
+	processed := 0
+	err := claim(func(tx *gorm.DB) error {
+		var messages []models.OutboxMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, time.Now()).
+			Where("NOT EXISTS (?)", tx.Table("outbox_messages AS earlier").
+				Select("1").
+				Where("earlier.circle_id = outbox_messages.circle_id AND earlier.status = ? AND earlier.id < outbox_messages.id", models.OutboxPending)).
+			Order("id").
+			Limit(batchSize).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+
+		for i := range messages {
+			if err := d.process(ctx, tx, &messages[i]); err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+// process hands one message to every sink and records the outcome
+func (d *Dispatcher) process(ctx context.Context, tx *gorm.DB, message *models.OutboxMessage) error {
+	var event events.Event
+	publishErr := json.Unmarshal([]byte(message.Payload), &event)
+	if publishErr != nil {
+		// A payload that cannot be decoded will never succeed
+		message.Attempts = d.cfg.MaxAttempts - 1
+		publishErr = fmt.Errorf("invalid payload: %w", publishErr)
+	} else {
+		for _, sink := range d.sinks {
+			if err := sink.Handle(ctx, event); err != nil {
+				publishErr = errors.Join(publishErr, fmt.Errorf("%s: %w", sink.Name(), err))
+			}
+		}
+	}
+
+	attempts := message.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts}
+	switch {
+	case publishErr == nil:
+		updates["status"] = models.OutboxPublished
+		updates["published_at"] = time.Now()
+		updates["last_error"] = ""
+	case attempts >= d.cfg.MaxAttempts:
+		updates["status"] = models.OutboxDead
+		updates["last_error"] = publishErr.Error()
+		slog.Error("Outbox message dead-lettered",
+			"message_id", message.ID, "event_type", message.EventType, "circle_id", message.CircleID,
+			"attempts", attempts, "error", publishErr)
+	default:
+		updates["last_error"] = publishErr.Error()
+		updates["next_attempt_at"] = time.Now().Add(backoff(d.cfg.BackoffBase, attempts))
+	}
+
+	return tx.Model(message).Updates(updates).Error
+}
+
+// cleanup deletes published messages past the retention period
+func (d *Dispatcher) cleanup() {
+	if d.cfg.Retention <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-d.cfg.Retention)
+	if err := d.db.Where("status = ? AND published_at < ?", models.OutboxPublished, cutoff).
+		Delete(&models.OutboxMessage{}).Error; err != nil {
+		slog.Error("Failed to delete old outbox messages", "error", err)
+	}
+}
+
+// backoff returns the delay before the retry following the given attempt,
+// doubling from base up to five minutes
+func backoff(base time.Duration, attempt int) time.Duration {
+	const maxDelay = 5 * time.Minute
+	delay := base
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,165 @@
+// Package password hashes and verifies user passwords and enforces the
+// password policy.
+//
+// Hashes use the PHC string format, which records the algorithm and its
+// parameters alongside the salt, e.g.
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+//
+// New hashes use Argon2id. Legacy bcrypt hashes ($2a$, $2b$, $2y$) still
+// verify, and NeedsRehash reports them so they can be upgraded at login.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrMismatch is returned when a password does not match the hash
+var ErrMismatch = errors.New("password does not match")
+
+// ErrUnknownFormat is returned for hashes in an unrecognised format
+var ErrUnknownFormat = errors.New("unknown password hash format")
+
+// Params are the Argon2id cost parameters
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follow the OWASP recommendation for Argon2id
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var (
+	mu     sync.RWMutex
+	params = DefaultParams
+)
+
+// Configure sets the parameters used for new hashes. Existing hashes with
+// other parameters are reported by NeedsRehash.
+func Configure(p Params) {
+	mu.Lock()
+	defer mu.Unlock()
+	params = p
+}
+
+func currentParams() Params {
+	mu.RLock()
+	defer mu.RUnlock()
+	return params
+}
+
+// Hash returns the encoded Argon2id hash of the password
+func Hash(password string) (string, error) {
+	p := currentParams()
+
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks a password against an encoded hash. It returns ErrMismatch
+// if the password is wrong.
+func Verify(password, encoded string) error {
+	if isBcrypt(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatch
+		}
+		return err
+	}
+
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, candidate) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash reports whether a hash uses another algorithm or other
+// parameters than new hashes would
+func NeedsRehash(encoded string) bool {
+	p, salt, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	current := currentParams()
+	return p.Memory != current.Memory ||
+		p.Iterations != current.Iterations ||
+		p.Parallelism != current.Parallelism ||
+		p.KeyLength != current.KeyLength ||
+		uint32(len(salt)) != current.SaltLength
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// decodeArgon2id parses $argon2id$v=19$m=...,t=...,p=...$salt$hash
+func decodeArgon2id(encoded string) (Params, []byte, []byte, error) {
+	var p Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownFormat
+	}
+
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+	return p, salt, key, nil
+}
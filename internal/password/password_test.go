@@ -0,0 +1,101 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastParams keeps the tests quick
+var fastParams = Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func useParams(t *testing.T, p Params) {
+	previous := currentParams()
+	Configure(p)
+	t.Cleanup(func() { Configure(previous) })
+}
+
+func TestHashAndVerify(t *testing.T) {
+	useParams(t, fastParams)
+
+	hash, err := Hash("correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	assert.NoError(t, Verify("correct horse battery staple", hash))
+	assert.ErrorIs(t, Verify("wrong", hash), ErrMismatch)
+
+	other, err := Hash("correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "salts should differ")
+}
+
+func TestVerifyLegacyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	assert.NoError(t, Verify("password123", string(legacy)))
+	assert.ErrorIs(t, Verify("wrong", string(legacy)), ErrMismatch)
+	assert.True(t, NeedsRehash(string(legacy)))
+}
+
+func TestVerifyUnknownFormat(t *testing.T) {
+	for _, encoded := range []string{"", "!", "plaintext", "$argon2i$v=19$m=1,t=1,p=1$c2FsdA$a2V5"} {
+		assert.ErrorIs(t, Verify("anything", encoded), ErrUnknownFormat, encoded)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	useParams(t, fastParams)
+
+	hash, err := Hash("password")
+	require.NoError(t, err)
+	assert.False(t, NeedsRehash(hash))
+
+	stronger := fastParams
+	stronger.Iterations = 2
+	useParams(t, stronger)
+	assert.True(t, NeedsRehash(hash))
+
+	// Old hashes still verify after the parameters change
+	assert.NoError(t, Verify("password", hash))
+}
+
+func TestPolicyValidate(t *testing.T) {
+	listPath := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(listPath, []byte("hunter2hunter2\n"), 0o644))
+
+	policy, err := NewPolicy(8, 64, listPath)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"acceptable", "plum-orbit-lantern", true},
+		{"too short", "abc12", false},
+		{"too long", strings.Repeat("x", 65), false},
+		{"built-in list", "Password123", false},
+		{"custom list", "hunter2hunter2", false},
+		{"email", "jane.doe@example.com", false},
+		{"email local part", "jane.doe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "jane.doe@example.com")
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				var policyErr *PolicyError
+				assert.ErrorAs(t, err, &policyErr)
+			}
+		})
+	}
+}
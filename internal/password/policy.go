@@ -0,0 +1,93 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// commonPasswords is the built-in list of breached passwords
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PolicyError describes why a password was rejected
+type PolicyError struct {
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Reason
+}
+
+// Policy decides which passwords users may choose
+type Policy struct {
+	MinLength int
+	MaxLength int
+	breached  map[string]struct{}
+}
+
+// NewPolicy creates a policy with the built-in breached password list, plus
+// the entries of breachedListPath (one password per line) if it is set
+func NewPolicy(minLength, maxLength int, breachedListPath string) (*Policy, error) {
+	policy := &Policy{
+		MinLength: minLength,
+		MaxLength: maxLength,
+		breached:  make(map[string]struct{}),
+	}
+
+	if err := policy.addBreached(strings.NewReader(commonPasswords)); err != nil {
+		return nil, err
+	}
+
+	if breachedListPath != "" {
+		f, err := os.Open(breachedListPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password list: %w", err)
+		}
+		defer f.Close()
+
+		if err := policy.addBreached(f); err != nil {
+			return nil, fmt.Errorf("failed to read breached password list: %w", err)
+		}
+	}
+
+	return policy, nil
+}
+
+func (p *Policy) addBreached(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.breached[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Validate checks a new password. email is the account's address, which may
+// not be used as the password. A *PolicyError is returned on rejection.
+func (p *Policy) Validate(password, email string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at least %d characters long", p.MinLength)}
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return &PolicyError{Reason: fmt.Sprintf("Password must be at most %d characters long", p.MaxLength)}
+	}
+
+	lower := strings.ToLower(password)
+	if email != "" && (lower == strings.ToLower(email) || lower == strings.ToLower(strings.Split(email, "@")[0])) {
+		return &PolicyError{Reason: "Password must not be your email address"}
+	}
+	if _, found := p.breached[lower]; found {
+		return &PolicyError{Reason: "This password has appeared in a data breach; please choose another"}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"gorm.io/gorm"
+)
+
+// Limiter applies request limits and failure backoff on top of a Store
+type Limiter struct {
+	store Store
+	cfg   config.RateLimitConfig
+	now   func() time.Time
+}
+
+// New creates a limiter using the store selected in the configuration
+func New(cfg *config.Config, db *gorm.DB) (*Limiter, error) {
+	var store Store
+	switch cfg.RateLimit.Store {
+	case "", "memory":
+		store = NewMemoryStore()
+	case "postgres":
+		store = NewPostgresStore(db)
+	default:
+		return nil, fmt.Errorf("unknown rate limit store: %s", cfg.RateLimit.Store)
+	}
+	return NewWithStore(store, cfg.RateLimit), nil
+}
+
+// NewWithStore creates a limiter backed by the given store
+func NewWithStore(store Store, cfg config.RateLimitConfig) *Limiter {
+	return &Limiter{store: store, cfg: cfg, now: time.Now}
+}
+
+// Config returns the limiter's configuration
+func (l *Limiter) Config() config.RateLimitConfig {
+	return l.cfg
+}
+
+// Allow counts a request against key and reports how long the caller must
+// wait if more than limit requests were made within the window, or if the
+// key is blocked. A zero duration means the request is allowed.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, window time.Duration) (time.Duration, error) {
+	if wait, err := l.Blocked(ctx, key); err != nil || wait > 0 {
+		return wait, err
+	}
+
+	entry, err := l.store.Increment(ctx, key, window)
+	if err != nil {
+		return 0, err
+	}
+	if entry.Count > limit {
+		return entry.ResetAt.Sub(l.now()), nil
+	}
+	return 0, nil
+}
+
+// Blocked returns the longest remaining block across the given keys
+func (l *Limiter) Blocked(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := l.now()
+	var longest time.Duration
+	for _, key := range keys {
+		entry, err := l.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := entry.BlockedUntil.Sub(now); wait > longest {
+			longest = wait
+		}
+	}
+	return longest, nil
+}
+
+// Fail records a failed attempt for key. Once more than FreeFailures have
+// been recorded within FailureWindow, the key is blocked for an exponentially
+// growing period. It returns the number of failures in the current window.
+func (l *Limiter) Fail(ctx context.Context, key string) (int, error) {
+	entry, err := l.store.Increment(ctx, key, l.cfg.FailureWindow)
+	if err != nil {
+		return 0, err
+	}
+
+	if delay := l.backoff(entry.Count); delay > 0 {
+		if err := l.store.Block(ctx, key, l.now().Add(delay)); err != nil {
+			return entry.Count, err
+		}
+	}
+	return entry.Count, nil
+}
+
+// Succeed clears recorded failures for key
+func (l *Limiter) Succeed(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+// backoff returns the block to apply after the given number of failures:
+// nothing for the first FreeFailures, then BackoffBase doubling each time,
+// capped at BackoffMax
+func (l *Limiter) backoff(failures int) time.Duration {
+	excess := failures - l.cfg.FreeFailures
+	if excess <= 0 {
+		return 0
+	}
+
+	delay := l.cfg.BackoffBase
+	for i := 1; i < excess; i++ {
+		delay *= 2
+		if delay >= l.cfg.BackoffMax {
+			return l.cfg.BackoffMax
+		}
+	}
+	if delay > l.cfg.BackoffMax {
+		return l.cfg.BackoffMax
+	}
+	return delay
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLimiter(now *time.Time) *Limiter {
+	store := NewMemoryStore()
+	store.now = func() time.Time { return *now }
+
+	limiter := NewWithStore(store, config.RateLimitConfig{
+		FreeFailures:  3,
+		BackoffBase:   time.Second,
+		BackoffMax:    time.Minute,
+		FailureWindow: time.Hour,
+	})
+	limiter.now = func() time.Time { return *now }
+	return limiter
+}
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newTestLimiter(&now)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		wait, err := limiter.Allow(ctx, "ip:1.2.3.4", 3, time.Minute)
+		assert.NoError(t, err)
+		assert.Zero(t, wait, "request %d should be allowed", i+1)
+	}
+
+	wait, err := limiter.Allow(ctx, "ip:1.2.3.4", 3, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, wait, "fourth request should wait for the window to end")
+
+	// Other keys are independent
+	wait, _ = limiter.Allow(ctx, "ip:5.6.7.8", 3, time.Minute)
+	assert.Zero(t, wait)
+
+	// A new window starts once the old one ends
+	now = now.Add(time.Minute)
+	wait, _ = limiter.Allow(ctx, "ip:1.2.3.4", 3, time.Minute)
+	assert.Zero(t, wait)
+}
+
+func TestLimiterBackoff(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newTestLimiter(&now)
+
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{1, 0},
+		{3, 0},
+		{4, time.Second},
+		{5, 2 * time.Second},
+		{6, 4 * time.Second},
+		{10, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, limiter.backoff(tt.failures), "failures=%d", tt.failures)
+	}
+}
+
+func TestLimiterFailAndSucceed(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	limiter := newTestLimiter(&now)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := limiter.Fail(ctx, "login:account:a@example.com")
+		assert.NoError(t, err)
+	}
+	wait, _ := limiter.Blocked(ctx, "login:account:a@example.com")
+	assert.Zero(t, wait, "free failures should not block")
+
+	count, err := limiter.Fail(ctx, "login:account:a@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, count)
+
+	wait, _ = limiter.Blocked(ctx, "login:ip:1.2.3.4", "login:account:a@example.com")
+	assert.Equal(t, time.Second, wait)
+
+	assert.NoError(t, limiter.Succeed(ctx, "login:account:a@example.com"))
+	wait, _ = limiter.Blocked(ctx, "login:account:a@example.com")
+	assert.Zero(t, wait, "success should clear the block")
+}
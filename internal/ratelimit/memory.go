@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process Store. Counters are not shared between
+// API instances, so use PostgresStore when running more than one replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]Entry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]Entry),
+		now:     time.Now,
+	}
+}
+
+// Get returns the current entry for key
+func (s *MemoryStore) Get(ctx context.Context, key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entries[key], nil
+}
+
+// Increment adds one to the counter for key
+func (s *MemoryStore) Increment(ctx context.Context, key string, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	entry := s.entries[key]
+	if !now.Before(entry.ResetAt) {
+		entry.Count = 0
+		entry.ResetAt = now.Add(window)
+	}
+	entry.Count++
+	s.entries[key] = entry
+
+	return entry, nil
+}
+
+// Block prevents the key from being used until the given time
+func (s *MemoryStore) Block(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	entry.BlockedUntil = until
+	s.entries[key] = entry
+
+	return nil
+}
+
+// Reset removes all state for key
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired entries at most once a minute so the map stays bounded.
+// Callers must hold the lock.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.ResetAt) && !now.Before(entry.BlockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
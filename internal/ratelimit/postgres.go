@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+)
+
+// PostgresStore keeps counters in the rate_limits table so limits are
+// shared across API instances
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore creates a store backed by the given database
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Get returns the current entry for key
+func (s *PostgresStore) Get(ctx context.Context, key string) (Entry, error) {
+	var row models.RateLimit
+	err := s.db.WithContext(ctx).Where("key = ?", key).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read rate limit: %w", err)
+	}
+	return toEntry(row), nil
+}
+
+// Increment adds one to the counter for key in a single upsert
+func (s *PostgresStore) Increment(ctx context.Context, key string, window time.Duration) (Entry, error) {
+	now := time.Now()
+	s.sweep(ctx, now)
+
+	var row models.RateLimit
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO rate_limits (key, count, reset_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			count = CASE WHEN rate_limits.reset_at <= ? THEN 1 ELSE rate_limits.count + 1 END,
+			reset_at = CASE WHEN rate_limits.reset_at <= ? THEN excluded.reset_at ELSE rate_limits.reset_at END
+		RETURNING key, count, reset_at, blocked_until`,
+		key, now.Add(window), now, now,
+	).Scan(&row).Error
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to increment rate limit: %w", err)
+	}
+	return toEntry(row), nil
+}
+
+// Block prevents the key from being used until the given time
+func (s *PostgresStore) Block(ctx context.Context, key string, until time.Time) error {
+	err := s.db.WithContext(ctx).Exec(`
+		INSERT INTO rate_limits (key, count, reset_at, blocked_until) VALUES (?, 0, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET blocked_until = excluded.blocked_until`,
+		key, time.Now(), until,
+	).Error
+	if err != nil {
+		return fmt.Errorf("failed to block rate limit key: %w", err)
+	}
+	return nil
+}
+
+// Reset removes all state for key
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	if err := s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.RateLimit{}).Error; err != nil {
+		return fmt.Errorf("failed to reset rate limit: %w", err)
+	}
+	return nil
+}
+
+// sweep deletes entries whose window and block have both ended, at most
+// once a minute per instance
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	s.db.WithContext(ctx).
+		Where("reset_at <= ? AND (blocked_until IS NULL OR blocked_until <= ?)", now, now).
+		Delete(&models.RateLimit{})
+}
+
+func toEntry(row models.RateLimit) Entry {
+	entry := Entry{Count: row.Count, ResetAt: row.ResetAt}
+	if row.BlockedUntil != nil {
+		entry.BlockedUntil = *row.BlockedUntil
+	}
+	return entry
+}
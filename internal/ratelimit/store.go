@@ -0,0 +1,27 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is the state of a single rate limit key
+type Entry struct {
+	Count        int       // events recorded in the current window
+	ResetAt      time.Time // when the current window ends
+	BlockedUntil time.Time // zero unless the key is blocked
+}
+
+// Store persists rate limit counters. Implementations must be safe for
+// concurrent use and make Increment atomic.
+type Store interface {
+	// Get returns the current entry for key, or a zero Entry if none exists
+	Get(ctx context.Context, key string) (Entry, error)
+	// Increment adds one to the counter for key, starting a new window of the
+	// given length if the previous one has ended, and returns the new entry
+	Increment(ctx context.Context, key string, window time.Duration) (Entry, error)
+	// Block prevents the key from being used until the given time
+	Block(ctx context.Context, key string, until time.Time) error
+	// Reset removes all state for key
+	Reset(ctx context.Context, key string) error
+}
@@ -0,0 +1,152 @@
+// Package reminder reminds members when their monthly contribution is due.
+//
+// The Scheduler runs in every API instance, but only the instance holding a
+// Postgres advisory lock computes and sends reminders. Every reminder sent is
+// recorded per channel in payment_reminders, which keeps a reminder from
+// being sent twice, even across a change of leader.
+package reminder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Sudan23/dhukuti/internal/mailer"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/notification"
+	"github.com/Sudan23/dhukuti/internal/sms"
+	"gorm.io/gorm"
+)
+
+// Reminder is one reminder to one member
+type Reminder struct {
+	ID     uint // the history record, unique per reminder and channel
+	Kind   string
+	User   models.User
+	Circle models.Circle
+	Due    Due
+}
+
+// Channel delivers reminders
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, r Reminder) error
+}
+
+// Message returns the title and text of a reminder
+func Message(r Reminder) (title, body string) {
+	month := r.Due.Period.Format("January 2006")
+	date := r.Due.DueDate.Format("2 January")
+
+	if r.Kind == models.ReminderOverdue {
+		title = "Your contribution to " + r.Circle.Name + " is overdue"
+		body = fmt.Sprintf("Your %d contribution for %s was due on %s.", r.Circle.AmountPerMember, month, date)
+		if r.Due.Outstanding > 1 {
+			body += fmt.Sprintf(" You have %d unpaid contributions.", r.Due.Outstanding)
+		}
+		return title, body
+	}
+
+	title = "Your contribution to " + r.Circle.Name + " is due soon"
+	body = fmt.Sprintf("Your %d contribution for %s is due on %s.", r.Circle.AmountPerMember, month, date)
+	return title, body
+}
+
+// InAppChannel sends reminders to the notification centre
+type InAppChannel struct {
+	db *gorm.DB
+}
+
+// NewInAppChannel creates the in-app reminder channel
+func NewInAppChannel(db *gorm.DB) *InAppChannel {
+	return &InAppChannel{db: db}
+}
+
+// Name implements Channel
+func (ch *InAppChannel) Name() string {
+	return "in_app"
+}
+
+// Send implements Channel
+func (ch *InAppChannel) Send(ctx context.Context, r Reminder) error {
+	title, body := Message(r)
+	circleID := r.Circle.ID
+	_, err := notification.Notify(ch.db.WithContext(ctx), &models.Notification{
+		UserID:   r.User.ID,
+		Type:     models.NotificationPaymentDue,
+		CircleID: &circleID,
+		Title:    title,
+		Body:     body,
+		SourceID: fmt.Sprintf("reminder_%d", r.ID),
+	})
+	return err
+}
+
+// EmailChannel emails reminders
+type EmailChannel struct {
+	mailer      mailer.Mailer
+	frontendURL string
+}
+
+// NewEmailChannel creates the email reminder channel
+func NewEmailChannel(m mailer.Mailer, frontendURL string) *EmailChannel {
+	return &EmailChannel{mailer: m, frontendURL: frontendURL}
+}
+
+// Name implements Channel
+func (ch *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send implements Channel
+func (ch *EmailChannel) Send(ctx context.Context, r Reminder) error {
+	if !r.User.IsEmailVerified() {
+		return nil
+	}
+
+	title, body := Message(r)
+	return ch.mailer.Send(mailer.Message{
+		To:      r.User.Email,
+		Subject: title,
+		Body: fmt.Sprintf("Hi %s,\n\n%s\n\nRecord your contribution at %s/circles/%d\n",
+			r.User.Name, body, ch.frontendURL, r.Circle.ID),
+	})
+}
+
+// SMSChannel texts reminders
+type SMSChannel struct {
+	gateway     sms.Gateway
+	frontendURL string
+}
+
+// NewSMSChannel creates the SMS reminder channel
+func NewSMSChannel(gateway sms.Gateway, frontendURL string) *SMSChannel {
+	return &SMSChannel{gateway: gateway, frontendURL: frontendURL}
+}
+
+// Name implements Channel
+func (ch *SMSChannel) Name() string {
+	return "sms"
+}
+
+// Send implements Channel
+func (ch *SMSChannel) Send(ctx context.Context, r Reminder) error {
+	if !r.User.IsPhoneVerified() {
+		return nil
+	}
+
+	template := sms.TemplatePaymentDue
+	if r.Kind == models.ReminderOverdue {
+		template = sms.TemplatePaymentOverdue
+	}
+	body, err := sms.Render(template, sms.ReminderData{
+		Circle:      r.Circle.Name,
+		Amount:      r.Circle.AmountPerMember,
+		DueDate:     r.Due.DueDate,
+		Outstanding: r.Due.Outstanding,
+		Link:        fmt.Sprintf("%s/circles/%d", ch.frontendURL, r.Circle.ID),
+	})
+	if err != nil {
+		return err
+	}
+	return ch.gateway.Send(sms.Message{To: r.User.Phone, Body: body})
+}
@@ -0,0 +1,100 @@
+package reminder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestNextDue(t *testing.T) {
+	tests := []struct {
+		name        string
+		joined      time.Time
+		dueDay      int
+		paid        int
+		now         time.Time
+		period      time.Time
+		dueDate     time.Time
+		outstanding int
+	}{
+		{
+			name:   "joined before the due day",
+			joined: date(2025, time.January, 3), dueDay: 10, paid: 0, now: date(2025, time.January, 5),
+			period: date(2025, time.January, 1), dueDate: date(2025, time.January, 10),
+		},
+		{
+			name:   "joined after the due day is due on joining",
+			joined: date(2025, time.January, 20), dueDay: 10, paid: 0, now: date(2025, time.January, 20),
+			period: date(2025, time.January, 1), dueDate: date(2025, time.January, 20),
+		},
+		{
+			name:   "paid up moves to next month",
+			joined: date(2025, time.January, 3), dueDay: 10, paid: 1, now: date(2025, time.January, 15),
+			period: date(2025, time.February, 1), dueDate: date(2025, time.February, 10),
+		},
+		{
+			name:   "due today is not overdue",
+			joined: date(2025, time.January, 3), dueDay: 10, paid: 1, now: date(2025, time.February, 10),
+			period: date(2025, time.February, 1), dueDate: date(2025, time.February, 10),
+		},
+		{
+			name:   "several months behind",
+			joined: date(2025, time.January, 3), dueDay: 10, paid: 1, now: date(2025, time.April, 11),
+			period: date(2025, time.February, 1), dueDate: date(2025, time.February, 10), outstanding: 3,
+		},
+		{
+			name:   "across a year boundary",
+			joined: date(2024, time.November, 1), dueDay: 1, paid: 2, now: date(2025, time.January, 2),
+			period: date(2025, time.January, 1), dueDate: date(2025, time.January, 1), outstanding: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due := NextDue(tt.joined, tt.dueDay, tt.paid, tt.now)
+			assert.Equal(t, day(tt.period), due.Period)
+			assert.Equal(t, day(tt.dueDate), due.DueDate)
+			assert.Equal(t, tt.outstanding, due.Outstanding)
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	circle := models.Circle{ReminderDaysBefore: 3, OverdueReminderDays: 7}
+	upcoming := Due{DueDate: day(date(2025, time.March, 10))}
+	overdue := Due{DueDate: day(date(2025, time.March, 10)), Outstanding: 1}
+
+	tests := []struct {
+		name     string
+		circle   models.Circle
+		due      Due
+		now      time.Time
+		kind     string
+		sequence int
+		ok       bool
+	}{
+		{"too early", circle, upcoming, date(2025, time.March, 6), "", 0, false},
+		{"days before", circle, upcoming, date(2025, time.March, 7), models.ReminderUpcoming, 0, true},
+		{"due today", circle, upcoming, date(2025, time.March, 10), models.ReminderUpcoming, 0, true},
+		{"no upcoming reminders", models.Circle{OverdueReminderDays: 7}, upcoming, date(2025, time.March, 10), "", 0, false},
+		{"first overdue", circle, overdue, date(2025, time.March, 11), models.ReminderOverdue, 0, true},
+		{"still first overdue", circle, overdue, date(2025, time.March, 16), models.ReminderOverdue, 0, true},
+		{"repeat overdue", circle, overdue, date(2025, time.March, 17), models.ReminderOverdue, 1, true},
+		{"single overdue reminder", models.Circle{}, overdue, date(2025, time.April, 30), models.ReminderOverdue, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, sequence, ok := schedule(tt.circle, tt.due, tt.now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.kind, kind)
+			assert.Equal(t, tt.sequence, sequence)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package reminder
+
+import "time"
+
+// Due describes where a member stands with their contributions to a circle.
+// Contributions pay for months in order, starting with the month the member
+// joined, so the next unpaid month is the one after the last paid one.
+type Due struct {
+	Period      time.Time // first day of the earliest unpaid month
+	DueDate     time.Time // when the contribution for Period is due
+	Outstanding int       // unpaid contributions whose due date has passed
+}
+
+// Overdue reports whether the earliest unpaid contribution is past its due date
+func (d Due) Overdue() bool {
+	return d.Outstanding > 0
+}
+
+// NextDue works out a member's earliest unpaid contribution on the given day.
+// dueDay is the circle's day of the month for payments; in the month the
+// member joined, a due date before they joined moves to the joining day.
+func NextDue(joined time.Time, dueDay, paid int, now time.Time) Due {
+	today := day(now)
+	joinedDay := day(joined)
+	first := time.Date(joinedDay.Year(), joinedDay.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	dueDate := func(i int) time.Time {
+		period := first.AddDate(0, i, 0)
+		date := time.Date(period.Year(), period.Month(), dueDay, 0, 0, 0, 0, time.UTC)
+		if i == 0 && date.Before(joinedDay) {
+			return joinedDay
+		}
+		return date
+	}
+
+	due := Due{
+		Period:  first.AddDate(0, paid, 0),
+		DueDate: dueDate(paid),
+	}
+	for i := paid; dueDate(i).Before(today); i++ {
+		due.Outstanding++
+	}
+	return due
+}
+
+// DaysBetween returns the number of whole days from a to b
+func DaysBetween(a, b time.Time) int {
+	return int(day(b).Sub(day(a)).Hours() / 24)
+}
+
+// day truncates t to midnight UTC
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
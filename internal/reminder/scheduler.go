@@ -0,0 +1,222 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// leaderLockKey is the advisory lock held by the instance sending reminders
+const leaderLockKey = 0x64686b0002
+
+// Scheduler computes due and overdue contributions and sends reminders
+type Scheduler struct {
+	db       *gorm.DB
+	cfg      config.ReminderConfig
+	channels []Channel
+}
+
+// NewScheduler creates a scheduler sending through channels
+func NewScheduler(db *gorm.DB, cfg config.ReminderConfig, channels ...Channel) *Scheduler {
+	return &Scheduler{db: db, cfg: cfg, channels: channels}
+}
+
+// Run competes for leadership until ctx is cancelled. The leader sends
+// reminders every interval; the others retry taking over at the same pace.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		if err := s.lead(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Reminder scheduler lost leadership", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.Interval):
+		}
+	}
+}
+
+// lead sends reminders for as long as it holds the leader lock. The lock is
+// tied to a dedicated connection, so it is released if this instance dies.
+func (s *Scheduler) lead(ctx context.Context) error {
+	// SQLite serves a single instance, which always leads
+	if !database.IsPostgres(s.db) {
+		return s.loop(ctx, func() error { return nil })
+	}
+
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var leader bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", leaderLockKey).Scan(&leader); err != nil {
+		return err
+	}
+	if !leader {
+		return nil
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", leaderLockKey)
+	slog.Info("This instance is now sending payment reminders")
+
+	// Losing the connection releases the lock
+	return s.loop(ctx, func() error { return conn.PingContext(ctx) })
+}
+
+// loop sends reminders every interval until ctx is cancelled or alive fails
+func (s *Scheduler) loop(ctx context.Context, alive func() error) error {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if sent, err := s.RunOnce(ctx, time.Now()); err != nil {
+			slog.Error("Reminder run failed", "error", err)
+		} else if sent > 0 {
+			slog.Info("Sent reminders", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := alive(); err != nil {
+			return err
+		}
+	}
+}
+
+// RunOnce sends the reminders due at now and returns how many were sent
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+
+	var memberships []struct {
+		CircleID uint
+		UserID   uint
+		JoinedAt time.Time
+		Paid     int
+	}
+	if err := db.Table("circle_members").
+		Select("circle_members.circle_id, circle_members.user_id, circle_members.created_at AS joined_at, "+
+			"(SELECT COUNT(*) FROM contributions WHERE contributions.circle_id = circle_members.circle_id "+
+			"AND contributions.user_id = circle_members.user_id AND contributions.deleted_at IS NULL) AS paid").
+		Joins("JOIN circles ON circles.id = circle_members.circle_id AND circles.deleted_at IS NULL").
+		Where("circle_members.status = ? AND circle_members.deleted_at IS NULL AND circles.reminders_enabled = ?", "active", true).
+		Scan(&memberships).Error; err != nil {
+		return 0, err
+	}
+	if len(memberships) == 0 {
+		return 0, nil
+	}
+
+	// Batch fetch circles and users
+	circleIDs := make([]uint, 0, len(memberships))
+	userIDs := make([]uint, 0, len(memberships))
+	for _, m := range memberships {
+		circleIDs = append(circleIDs, m.CircleID)
+		userIDs = append(userIDs, m.UserID)
+	}
+	var circles []models.Circle
+	if err := db.Where("id IN ?", circleIDs).Find(&circles).Error; err != nil {
+		return 0, err
+	}
+	var users []models.User
+	if err := db.Where("id IN ? AND anonymized_at IS NULL", userIDs).Find(&users).Error; err != nil {
+		return 0, err
+	}
+	circleMap := make(map[uint]models.Circle, len(circles))
+	for _, circle := range circles {
+		circleMap[circle.ID] = circle
+	}
+	userMap := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+
+	sent := 0
+	for _, m := range memberships {
+		circle, okCircle := circleMap[m.CircleID]
+		user, okUser := userMap[m.UserID]
+		if !okCircle || !okUser {
+			continue
+		}
+
+		due := NextDue(m.JoinedAt, circle.PaymentDueDay, m.Paid, now)
+		kind, sequence, ok := schedule(circle, due, now)
+		if !ok {
+			continue
+		}
+
+		for _, channel := range s.channels {
+			n, err := s.send(ctx, channel, Reminder{Kind: kind, User: user, Circle: circle, Due: due}, sequence, now)
+			if err != nil {
+				slog.Error("Failed to send reminder",
+					"kind", kind, "user_id", user.ID, "circle_id", circle.ID, "channel", channel.Name(), "error", err)
+				continue
+			}
+			sent += n
+		}
+	}
+	return sent, nil
+}
+
+// schedule decides whether a reminder is due for a member today. Overdue
+// reminders repeat every OverdueReminderDays; sequence numbers the repeats
+// so each is sent once.
+func schedule(circle models.Circle, due Due, now time.Time) (kind string, sequence int, ok bool) {
+	if due.Overdue() {
+		if circle.OverdueReminderDays <= 0 {
+			return models.ReminderOverdue, 0, true
+		}
+		late := DaysBetween(due.DueDate, now)
+		return models.ReminderOverdue, late / circle.OverdueReminderDays, true
+	}
+
+	if until := DaysBetween(now, due.DueDate); circle.ReminderDaysBefore > 0 && until <= circle.ReminderDaysBefore {
+		return models.ReminderUpcoming, 0, true
+	}
+	return "", 0, false
+}
+
+// send records a reminder and sends it through one channel. A reminder that
+// is already recorded is skipped; one that fails to send is forgotten so it
+// is retried on the next run.
+func (s *Scheduler) send(ctx context.Context, channel Channel, r Reminder, sequence int, now time.Time) (int, error) {
+	db := s.db.WithContext(ctx)
+
+	record := models.PaymentReminder{
+		CircleID: r.Circle.ID,
+		UserID:   r.User.ID,
+		Period:   r.Due.Period,
+		Kind:     r.Kind,
+		Sequence: sequence,
+		Channel:  channel.Name(),
+		SentAt:   now,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, nil
+	}
+
+	r.ID = record.ID
+	if err := channel.Send(ctx, r); err != nil {
+		return 0, errors.Join(err, db.Delete(&record).Error)
+	}
+	return 1, nil
+}
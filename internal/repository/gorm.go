@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Gorm implements Repository on a GORM database
+type Gorm struct {
+	db        *gorm.DB
+	publisher events.Publisher
+}
+
+// NewGorm creates a repository on db that publishes events through publisher.
+// db may be a transaction, e.g. to run services inside an enclosing one.
+func NewGorm(db *gorm.DB, publisher events.Publisher) *Gorm {
+	return &Gorm{db: db, publisher: publisher}
+}
+
+// WithContext implements Repository
+func (r *Gorm) WithContext(ctx context.Context) Repository {
+	return &Gorm{db: r.db.WithContext(ctx), publisher: r.publisher}
+}
+
+// Transaction implements Repository
+func (r *Gorm) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Gorm{db: tx, publisher: r.publisher})
+	})
+}
+
+// Publish implements Repository
+func (r *Gorm) Publish(event events.Event) error {
+	return r.publisher.Publish(r.db, event)
+}
+
+// Users implements Repository
+func (r *Gorm) Users() UserRepository { return gormUsers{r.db} }
+
+// Circles implements Repository
+func (r *Gorm) Circles() CircleRepository { return gormCircles{r.db} }
+
+// Members implements Repository
+func (r *Gorm) Members() MemberRepository { return gormMembers{r.db} }
+
+// Approvals implements Repository
+func (r *Gorm) Approvals() ApprovalRepository { return gormApprovals{r.db} }
+
+// Contributions implements Repository
+func (r *Gorm) Contributions() ContributionRepository { return gormContributions{r.db} }
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// conflict maps unique and foreign key violations to ErrConflict. The
+// database must be opened with TranslateError for GORM to recognise them.
+func conflict(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+	return err
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) Get(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) ListByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r gormUsers) Create(user *models.User) error {
+	return conflict(r.db.Create(user).Error)
+}
+
+func (r gormUsers) Update(id uint, updates map[string]interface{}) error {
+	return conflict(r.db.Model(&models.User{}).Where("id = ?", id).Updates(updates).Error)
+}
+
+type gormCircles struct{ db *gorm.DB }
+
+func (r gormCircles) Get(id uint) (*models.Circle, error) {
+	var circle models.Circle
+	if err := r.db.First(&circle, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &circle, nil
+}
+
+// Lock takes a row lock with SELECT ... FOR UPDATE. SQLite has no row locks
+// and drops the clause; it allows one writer at a time anyway.
+func (r gormCircles) Lock(id uint) (*models.Circle, error) {
+	var circle models.Circle
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&circle, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &circle, nil
+}
+
+func (r gormCircles) GetForMember(id, userID uint) (*models.Circle, error) {
+	var circle models.Circle
+	// Join with members to verify membership efficiently
+	if err := r.db.
+		Joins("JOIN circle_members ON circle_members.circle_id = circles.id").
+		Where("circles.id = ? AND circle_members.user_id = ?", id, userID).
+		Preload("Members").
+		First(&circle).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &circle, nil
+}
+
+func (r gormCircles) ListForMember(userID uint) ([]models.Circle, error) {
+	var circles []models.Circle
+	err := r.db.
+		Joins("JOIN circle_members ON circle_members.circle_id = circles.id").
+		Where("circle_members.user_id = ?", userID).
+		Preload("Members").
+		Find(&circles).Error
+	return circles, err
+}
+
+func (r gormCircles) Create(circle *models.Circle) error {
+	return conflict(r.db.Create(circle).Error)
+}
+
+func (r gormCircles) Update(circle *models.Circle, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+	result := r.db.Model(&models.Circle{}).
+		Where("id = ? AND version = ?", circle.ID, circle.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: circle %d has changed since version %d", ErrConflict, circle.ID, circle.Version)
+	}
+	circle.Version++
+	return nil
+}
+
+type gormMembers struct{ db *gorm.DB }
+
+func (r gormMembers) Get(circleID, userID uint) (*models.CircleMember, error) {
+	var member models.CircleMember
+	if err := r.db.Where("circle_id = ? AND user_id = ?", circleID, userID).First(&member).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &member, nil
+}
+
+func (r gormMembers) ListByCircles(circleIDs []uint) ([]models.CircleMember, error) {
+	var members []models.CircleMember
+	if len(circleIDs) == 0 {
+		return members, nil
+	}
+	err := r.db.Where("circle_id IN ?", circleIDs).Find(&members).Error
+	return members, err
+}
+
+func (r gormMembers) ListActive(circleID uint) ([]models.CircleMember, error) {
+	var members []models.CircleMember
+	err := r.db.Where("circle_id = ? AND status = ?", circleID, "active").Find(&members).Error
+	return members, err
+}
+
+func (r gormMembers) Create(member *models.CircleMember) error {
+	return conflict(r.db.Create(member).Error)
+}
+
+func (r gormMembers) Activate(circleID, userID uint) (bool, error) {
+	result := r.db.Model(&models.CircleMember{}).
+		Where("circle_id = ? AND user_id = ? AND status = ?", circleID, userID, "pending").
+		Update("status", "active")
+	return result.RowsAffected > 0, result.Error
+}
+
+type gormApprovals struct{ db *gorm.DB }
+
+func (r gormApprovals) CreateMemberApproval(approval *models.MemberApproval) error {
+	return conflict(r.db.Create(approval).Error)
+}
+
+func (r gormApprovals) ApproveMember(circleID, pendingUserID, approverID uint) (bool, error) {
+	result := r.db.Model(&models.MemberApproval{}).
+		Where("circle_id = ? AND pending_user_id = ? AND approver_user_id = ?", circleID, pendingUserID, approverID).
+		Update("approved", true)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormApprovals) CountMemberApprovals(circleID, pendingUserID uint) (required, approved int64, err error) {
+	if err = r.db.Model(&models.MemberApproval{}).
+		Where("circle_id = ? AND pending_user_id = ?", circleID, pendingUserID).
+		Count(&required).Error; err != nil {
+		return 0, 0, err
+	}
+	err = r.db.Model(&models.MemberApproval{}).
+		Where("circle_id = ? AND pending_user_id = ? AND approved = ?", circleID, pendingUserID, true).
+		Count(&approved).Error
+	return required, approved, err
+}
+
+func (r gormApprovals) PendingMemberApprovals(circleIDs []uint, approverID uint) ([]models.MemberApproval, error) {
+	var approvals []models.MemberApproval
+	if len(circleIDs) == 0 {
+		return approvals, nil
+	}
+	err := r.db.Where("circle_id IN ? AND approver_user_id = ? AND approved = ?", circleIDs, approverID, false).
+		Find(&approvals).Error
+	return approvals, err
+}
+
+func (r gormApprovals) ListAmountApprovals(circleID uint) ([]models.AmountApproval, error) {
+	var approvals []models.AmountApproval
+	err := r.db.Where("circle_id = ?", circleID).Find(&approvals).Error
+	return approvals, err
+}
+
+func (r gormApprovals) PendingAmountApprovals(circleIDs []uint, approverID uint) ([]models.AmountApproval, error) {
+	var approvals []models.AmountApproval
+	if len(circleIDs) == 0 {
+		return approvals, nil
+	}
+	err := r.db.Where("circle_id IN ? AND approver_id = ? AND approved = ?", circleIDs, approverID, false).
+		Find(&approvals).Error
+	return approvals, err
+}
+
+func (r gormApprovals) ReplaceAmountApprovals(circleID uint, approvals []models.AmountApproval) error {
+	if err := r.DeleteAmountApprovals(circleID); err != nil {
+		return err
+	}
+	if len(approvals) == 0 {
+		return nil
+	}
+	return conflict(r.db.Create(&approvals).Error)
+}
+
+func (r gormApprovals) ApproveAmount(circleID, approverID uint) (bool, error) {
+	result := r.db.Model(&models.AmountApproval{}).
+		Where("circle_id = ? AND approver_id = ? AND approved = ?", circleID, approverID, false).
+		Update("approved", true)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r gormApprovals) DeleteAmountApprovals(circleID uint) error {
+	return r.db.Unscoped().Where("circle_id = ?", circleID).Delete(&models.AmountApproval{}).Error
+}
+
+type gormContributions struct{ db *gorm.DB }
+
+func (r gormContributions) Create(contribution *models.Contribution) error {
+	return conflict(r.db.Create(contribution).Error)
+}
+
+func (r gormContributions) ListByCircle(circleID uint) ([]models.Contribution, error) {
+	var contributions []models.Contribution
+	err := r.db.Where("circle_id = ?", circleID).Order("created_at DESC").Find(&contributions).Error
+	return contributions, err
+}
@@ -0,0 +1,103 @@
+// Package repository defines the storage the domain services depend on, and
+// its GORM implementation.
+//
+// Services reach the store only through these interfaces, so their rules can
+// be tested against an in-memory store and run against another database.
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+)
+
+// Errors returned by stores
+var (
+	// ErrNotFound is returned when a record does not exist
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned when a write violates a unique or foreign key
+	// constraint, e.g. a concurrent request added the same row first
+	ErrConflict = errors.New("record conflicts with existing data")
+)
+
+// Repository gives access to all stores
+type Repository interface {
+	// WithContext returns a repository whose queries use ctx
+	WithContext(ctx context.Context) Repository
+	// Transaction runs fn atomically. fn must only use the repository it is
+	// given; the transaction is rolled back if fn returns an error.
+	Transaction(ctx context.Context, fn func(tx Repository) error) error
+	// Publish records a circle event. Inside a transaction the event is only
+	// published if the transaction commits.
+	Publish(event events.Event) error
+
+	Users() UserRepository
+	Circles() CircleRepository
+	Members() MemberRepository
+	Approvals() ApprovalRepository
+	Contributions() ContributionRepository
+}
+
+// UserRepository stores users
+type UserRepository interface {
+	Get(id uint) (*models.User, error)
+	GetByEmail(email string) (*models.User, error)
+	ListByIDs(ids []uint) ([]models.User, error)
+	Create(user *models.User) error
+	Update(id uint, updates map[string]interface{}) error
+}
+
+// CircleRepository stores circles
+type CircleRepository interface {
+	Get(id uint) (*models.Circle, error)
+	// GetForMember returns a circle with its members if userID belongs to it
+	GetForMember(id, userID uint) (*models.Circle, error)
+	// ListForMember returns the circles userID belongs to with their members
+	ListForMember(userID uint) ([]models.Circle, error)
+	// Lock returns a circle and locks it until the transaction ends, so
+	// changes to the same circle take turns. Call it before reading the
+	// members or votes the change depends on.
+	Lock(id uint) (*models.Circle, error)
+	Create(circle *models.Circle) error
+	// Update applies updates to circle and increments its version. It returns
+	// ErrConflict if the circle has changed since it was read.
+	Update(circle *models.Circle, updates map[string]interface{}) error
+}
+
+// MemberRepository stores circle memberships
+type MemberRepository interface {
+	Get(circleID, userID uint) (*models.CircleMember, error)
+	ListByCircles(circleIDs []uint) ([]models.CircleMember, error)
+	ListActive(circleID uint) ([]models.CircleMember, error)
+	Create(member *models.CircleMember) error
+	// Activate turns a pending membership active and reports whether it did
+	Activate(circleID, userID uint) (bool, error)
+}
+
+// ApprovalRepository stores the votes on new members and amount changes
+type ApprovalRepository interface {
+	CreateMemberApproval(approval *models.MemberApproval) error
+	// ApproveMember records approverID's vote and reports whether they had one
+	ApproveMember(circleID, pendingUserID, approverID uint) (bool, error)
+	CountMemberApprovals(circleID, pendingUserID uint) (required, approved int64, err error)
+	// PendingMemberApprovals returns the member votes approverID has not cast
+	PendingMemberApprovals(circleIDs []uint, approverID uint) ([]models.MemberApproval, error)
+
+	ListAmountApprovals(circleID uint) ([]models.AmountApproval, error)
+	// PendingAmountApprovals returns the amount votes approverID has not cast
+	PendingAmountApprovals(circleIDs []uint, approverID uint) ([]models.AmountApproval, error)
+	// ReplaceAmountApprovals discards a circle's amount votes and stores new ones
+	ReplaceAmountApprovals(circleID uint, approvals []models.AmountApproval) error
+	// ApproveAmount records approverID's pending vote and reports whether they had one
+	ApproveAmount(circleID, approverID uint) (bool, error)
+	DeleteAmountApprovals(circleID uint) error
+}
+
+// ContributionRepository stores contributions
+type ContributionRepository interface {
+	Create(contribution *models.Contribution) error
+	// ListByCircle returns a circle's contributions, newest first
+	ListByCircle(circleID uint) ([]models.Contribution, error)
+}
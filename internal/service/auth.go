@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// AuthService implements Auth
+type AuthService struct {
+	repo repository.Repository
+}
+
+// NewAuthService creates an auth service
+func NewAuthService(repo repository.Repository) *AuthService {
+	return &AuthService{repo: repo}
+}
+
+// Register implements Auth. The password must already satisfy the policy.
+func (s *AuthService) Register(ctx context.Context, email, name, password string) (*models.User, error) {
+	repo := s.repo.WithContext(ctx)
+
+	if _, err := repo.Users().GetByEmail(email); err == nil {
+		return nil, ErrEmailTaken
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	user := models.User{Email: email, Name: name}
+	if err := user.HashPassword(password); err != nil {
+		return nil, err
+	}
+	if err := repo.Users().Create(&user); errors.Is(err, repository.ErrConflict) {
+		// A concurrent registration took the address first
+		return nil, ErrEmailTaken
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Login implements Auth
+func (s *AuthService) Login(ctx context.Context, email, password string) (*models.User, error) {
+	repo := s.repo.WithContext(ctx)
+
+	user, err := repo.Users().GetByEmail(email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.IsLocked() {
+		return user, ErrAccountLocked
+	}
+	if err := user.CheckPassword(password); err != nil {
+		return user, ErrInvalidCredentials
+	}
+
+	// Upgrade legacy bcrypt and outdated Argon2id hashes while we have the password
+	if user.PasswordNeedsRehash() {
+		if err := user.HashPassword(password); err != nil {
+			logging.FromContext(ctx).Error("Failed to rehash password", "target_user_id", user.ID, "error", err)
+		} else if err := repo.Users().Update(user.ID, map[string]interface{}{"password": user.Password}); err != nil {
+			logging.FromContext(ctx).Error("Failed to store rehashed password", "target_user_id", user.ID, "error", err)
+		}
+	}
+	return user, nil
+}
@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// CreateCircleInput describes a new circle
+type CreateCircleInput struct {
+	Name            string
+	Description     string
+	AmountPerMember uint
+}
+
+// ReminderSettingsInput changes a circle's payment schedule and reminders;
+// nil fields are left unchanged
+type ReminderSettingsInput struct {
+	PaymentDueDay       *int
+	RemindersEnabled    *bool
+	ReminderDaysBefore  *int
+	OverdueReminderDays *int
+}
+
+// CircleView is a circle as seen by one of its members
+type CircleView struct {
+	Circle              models.Circle
+	Members             []MemberView
+	PendingApprovals    []uint       // pending members waiting for the viewer's vote
+	NeedsAmountApproval bool         // the viewer has yet to vote on the proposed amount
+	AmountApprovals     []AmountVote // votes of the active members; only set by Get
+}
+
+// MemberView is a member of a circle
+type MemberView struct {
+	ID     uint
+	Email  string
+	Name   string
+	Role   string
+	Status string
+}
+
+// AmountVote is a member's vote on the proposed amount
+type AmountVote struct {
+	UserID   uint
+	UserName string
+	Approved bool
+}
+
+// CircleService implements Circles
+type CircleService struct {
+	repo repository.Repository
+}
+
+// NewCircleService creates a circle service
+func NewCircleService(repo repository.Repository) *CircleService {
+	return &CircleService{repo: repo}
+}
+
+// Create implements Circles. The creator becomes the circle's first admin.
+func (s *CircleService) Create(ctx context.Context, creatorID uint, input CreateCircleInput) (*models.Circle, error) {
+	circle := models.Circle{
+		Name:            input.Name,
+		Description:     input.Description,
+		AmountPerMember: input.AmountPerMember,
+		CreatorID:       creatorID,
+	}
+
+	err := s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		if err := tx.Circles().Create(&circle); err != nil {
+			return err
+		}
+
+		// Add creator as active admin member
+		return tx.Members().Create(&models.CircleMember{
+			CircleID: circle.ID,
+			UserID:   creatorID,
+			Role:     "admin",
+			Status:   "active",
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &circle, nil
+}
+
+// Get implements Circles
+func (s *CircleService) Get(ctx context.Context, circleID, userID uint) (*CircleView, error) {
+	repo := s.repo.WithContext(ctx)
+
+	circle, err := repo.Circles().GetForMember(circleID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCircleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	memberships, err := repo.Members().ListByCircles([]uint{circle.ID})
+	if err != nil {
+		return nil, err
+	}
+	memberVotes, err := repo.Approvals().PendingMemberApprovals([]uint{circle.ID}, userID)
+	if err != nil {
+		return nil, err
+	}
+	amountVotes, err := repo.Approvals().ListAmountApprovals(circle.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	view := newCircleView(*circle, memberships, memberVotes)
+
+	approved := make(map[uint]bool)
+	for _, vote := range amountVotes {
+		approved[vote.ApproverID] = vote.Approved
+		if vote.ApproverID == userID && !vote.Approved {
+			view.NeedsAmountApproval = true
+		}
+	}
+
+	// Every active member is listed; members without a vote have not approved
+	for _, member := range view.Members {
+		if member.Status == "active" {
+			view.AmountApprovals = append(view.AmountApprovals, AmountVote{
+				UserID:   member.ID,
+				UserName: member.Name,
+				Approved: approved[member.ID],
+			})
+		}
+	}
+	return &view, nil
+}
+
+// List implements Circles. Members and votes are fetched for all circles at
+// once rather than per circle.
+func (s *CircleService) List(ctx context.Context, userID uint) ([]CircleView, error) {
+	repo := s.repo.WithContext(ctx)
+
+	circles, err := repo.Circles().ListForMember(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect all circle IDs for batch queries
+	circleIDs := make([]uint, len(circles))
+	for i, circle := range circles {
+		circleIDs[i] = circle.ID
+	}
+
+	memberships, err := repo.Members().ListByCircles(circleIDs)
+	if err != nil {
+		return nil, err
+	}
+	memberVotes, err := repo.Approvals().PendingMemberApprovals(circleIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	amountVotes, err := repo.Approvals().PendingAmountApprovals(circleIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group by circle ID
+	membershipsByCircle := make(map[uint][]models.CircleMember)
+	for _, m := range memberships {
+		membershipsByCircle[m.CircleID] = append(membershipsByCircle[m.CircleID], m)
+	}
+	memberVotesByCircle := make(map[uint][]models.MemberApproval)
+	for _, vote := range memberVotes {
+		memberVotesByCircle[vote.CircleID] = append(memberVotesByCircle[vote.CircleID], vote)
+	}
+	needsAmountVote := make(map[uint]bool)
+	for _, vote := range amountVotes {
+		needsAmountVote[vote.CircleID] = true
+	}
+
+	views := make([]CircleView, len(circles))
+	for i, circle := range circles {
+		views[i] = newCircleView(circle, membershipsByCircle[circle.ID], memberVotesByCircle[circle.ID])
+		views[i].NeedsAmountApproval = needsAmountVote[circle.ID]
+	}
+	return views, nil
+}
+
+// newCircleView combines a circle and its members' roles and statuses
+func newCircleView(circle models.Circle, memberships []models.CircleMember, memberVotes []models.MemberApproval) CircleView {
+	statusMap := make(map[uint]string)
+	roleMap := make(map[uint]string)
+	for _, ms := range memberships {
+		statusMap[ms.UserID] = ms.Status
+		roleMap[ms.UserID] = ms.Role
+	}
+
+	view := CircleView{Circle: circle, Members: make([]MemberView, len(circle.Members))}
+	for i, member := range circle.Members {
+		view.Members[i] = MemberView{
+			ID:     member.ID,
+			Email:  member.Email,
+			Name:   member.Name,
+			Role:   roleMap[member.ID],
+			Status: statusMap[member.ID],
+		}
+	}
+	for _, vote := range memberVotes {
+		view.PendingApprovals = append(view.PendingApprovals, vote.PendingUserID)
+	}
+	return view
+}
+
+// ProposeAmount implements Circles. Any previous proposal is discarded, and
+// the proposer's own vote is counted as approval.
+func (s *CircleService) ProposeAmount(ctx context.Context, circleID, userID, amount uint) error {
+	return s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		circle, err := lockCircle(tx, circleID)
+		if err != nil {
+			return err
+		}
+
+		if err := requireAdmin(tx, circleID, userID); err != nil {
+			return err
+		}
+
+		if err := tx.Circles().Update(circle, map[string]interface{}{"proposed_amount": amount}); err != nil {
+			return err
+		}
+
+		activeMembers, err := tx.Members().ListActive(circleID)
+		if err != nil {
+			return err
+		}
+		approvals := make([]models.AmountApproval, len(activeMembers))
+		for i, m := range activeMembers {
+			approvals[i] = models.AmountApproval{
+				CircleID:       circleID,
+				ProposerID:     userID,
+				ProposedAmount: amount,
+				ApproverID:     m.UserID,
+				Approved:       m.UserID == userID,
+			}
+		}
+		if err := tx.Approvals().ReplaceAmountApprovals(circleID, approvals); err != nil {
+			return err
+		}
+
+		return tx.Publish(events.New(events.AmountProposed, circleID, userID, map[string]interface{}{
+			"current_amount":  circle.AmountPerMember,
+			"proposed_amount": amount,
+			"proposed_by":     userID,
+		}))
+	})
+}
+
+// ApproveAmount implements Circles
+func (s *CircleService) ApproveAmount(ctx context.Context, circleID, userID uint) error {
+	return s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		// A new proposal can't replace the votes while they are counted
+		circle, err := lockCircle(tx, circleID)
+		if errors.Is(err, ErrCircleNotFound) {
+			return ErrApprovalNotFound
+		} else if err != nil {
+			return err
+		}
+
+		found, err := tx.Approvals().ApproveAmount(circleID, userID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrApprovalNotFound
+		}
+
+		if err := tx.Publish(events.New(events.VoteCast, circleID, userID, map[string]interface{}{
+			"vote": "amount",
+		})); err != nil {
+			return err
+		}
+
+		return CompleteAmountChange(ctx, tx, circle, userID)
+	})
+}
+
+// CompleteAmountChange applies the proposed amount once all remaining voters
+// have approved it and publishes amount_changed. actorID is the user whose
+// action completed the vote. Call it inside the transaction that changed the
+// votes, with the circle it locked.
+func CompleteAmountChange(ctx context.Context, tx repository.Repository, circle *models.Circle, actorID uint) error {
+	votes, err := tx.Approvals().ListAmountApprovals(circle.ID)
+	if err != nil {
+		return err
+	}
+	if !allApproved(votes) {
+		return nil
+	}
+
+	logging.FromContext(ctx).Info("Amount change approved", "amount", votes[0].ProposedAmount)
+
+	previousAmount := circle.AmountPerMember
+	// A map makes sure the zero proposed_amount is written
+	if err := tx.Circles().Update(circle, map[string]interface{}{
+		"amount_per_member": votes[0].ProposedAmount,
+		"proposed_amount":   0,
+	}); err != nil {
+		return err
+	}
+
+	// The votes are no longer needed
+	if err := tx.Approvals().DeleteAmountApprovals(circle.ID); err != nil {
+		return err
+	}
+
+	// Published in the transaction so the event is only recorded if the change commits
+	return tx.Publish(events.New(events.AmountChanged, circle.ID, actorID, map[string]interface{}{
+		"previous_amount": previousAmount,
+		"amount":          votes[0].ProposedAmount,
+	}))
+}
+
+// allApproved reports whether there are votes and all of them approve
+func allApproved(votes []models.AmountApproval) bool {
+	for _, vote := range votes {
+		if !vote.Approved {
+			return false
+		}
+	}
+	return len(votes) > 0
+}
+
+// UpdateSecurity implements Circles. An admin can only require two-factor
+// authentication once they have it themselves.
+func (s *CircleService) UpdateSecurity(ctx context.Context, circleID, userID uint, requireTwoFactor bool) error {
+	return s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		circle, err := lockCircle(tx, circleID)
+		if err != nil {
+			return err
+		}
+
+		if err := requireAdmin(tx, circleID, userID); err != nil {
+			return err
+		}
+
+		if requireTwoFactor {
+			admin, err := tx.Users().Get(userID)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return err
+			}
+			if admin == nil || !admin.IsTwoFactorEnabled() {
+				return ErrTwoFactorNotEnabled
+			}
+		}
+
+		return tx.Circles().Update(circle, map[string]interface{}{"require_two_factor": requireTwoFactor})
+	})
+}
+
+// UpdateReminders implements Circles
+func (s *CircleService) UpdateReminders(ctx context.Context, circleID, userID uint, input ReminderSettingsInput) (*models.Circle, error) {
+	var circle *models.Circle
+	err := s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		var err error
+		if circle, err = lockCircle(tx, circleID); err != nil {
+			return err
+		}
+
+		if err := requireAdmin(tx, circleID, userID); err != nil {
+			return err
+		}
+		return updateReminders(tx, circle, input)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return circle, nil
+}
+
+// updateReminders applies the set fields of input to circle
+func updateReminders(tx repository.Repository, circle *models.Circle, input ReminderSettingsInput) error {
+	updates := map[string]interface{}{}
+	if input.PaymentDueDay != nil {
+		updates["payment_due_day"] = *input.PaymentDueDay
+	}
+	if input.RemindersEnabled != nil {
+		updates["reminders_enabled"] = *input.RemindersEnabled
+	}
+	if input.ReminderDaysBefore != nil {
+		updates["reminder_days_before"] = *input.ReminderDaysBefore
+	}
+	if input.OverdueReminderDays != nil {
+		updates["overdue_reminder_days"] = *input.OverdueReminderDays
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := tx.Circles().Update(circle, updates); err != nil {
+		return err
+	}
+
+	// Reread the circle to return what was stored
+	updated, err := tx.Circles().Get(circle.ID)
+	if err != nil {
+		return err
+	}
+	*circle = *updated
+	return nil
+}
+
+// lockCircle locks a circle for the rest of the transaction. Every change to a
+// circle's settings, members or votes takes the lock first, so concurrent
+// changes run one after the other and each sees the result of the last.
+func lockCircle(tx repository.Repository, circleID uint) (*models.Circle, error) {
+	circle, err := tx.Circles().Lock(circleID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrCircleNotFound
+	}
+	return circle, err
+}
+
+// requireAdmin returns ErrNotAdmin unless the user is an admin of the circle
+func requireAdmin(repo repository.Repository, circleID, userID uint) error {
+	member, err := repo.Members().Get(circleID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotAdmin
+	}
+	if err != nil {
+		return err
+	}
+	if member.Role != "admin" {
+		return ErrNotAdmin
+	}
+	return nil
+}
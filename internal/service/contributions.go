@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// ContributionView is a contribution with the member who made it
+type ContributionView struct {
+	models.Contribution
+	UserName  string
+	UserEmail string // empty for deleted accounts
+}
+
+// ContributionService implements Contributions
+type ContributionService struct {
+	repo repository.Repository
+}
+
+// NewContributionService creates a contribution service
+func NewContributionService(repo repository.Repository) *ContributionService {
+	return &ContributionService{repo: repo}
+}
+
+// Record implements Contributions. Only active members can contribute, and
+// they pay the circle's current amount.
+func (s *ContributionService) Record(ctx context.Context, circleID, userID uint) (*models.Contribution, error) {
+	var contribution models.Contribution
+	err := s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		// The amount can't change while the contribution is recorded
+		circle, err := lockCircle(tx, circleID)
+		if err != nil {
+			return err
+		}
+
+		member, err := tx.Members().Get(circleID, userID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && member.Status != "active") {
+			return ErrNotActiveMember
+		}
+		if err != nil {
+			return err
+		}
+
+		contribution = models.Contribution{
+			CircleID: circleID,
+			UserID:   userID,
+			Amount:   circle.AmountPerMember,
+		}
+		if err := tx.Contributions().Create(&contribution); err != nil {
+			return err
+		}
+
+		return tx.Publish(events.New(events.ContributionRecorded, circleID, userID, map[string]interface{}{
+			"contribution_id": contribution.ID,
+			"user_id":         contribution.UserID,
+			"amount":          contribution.Amount,
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &contribution, nil
+}
+
+// List implements Contributions. Any member of the circle, pending or
+// active, can see its contributions.
+func (s *ContributionService) List(ctx context.Context, circleID, userID uint) ([]ContributionView, error) {
+	repo := s.repo.WithContext(ctx)
+
+	if _, err := repo.Members().Get(circleID, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrNotMember
+		}
+		return nil, err
+	}
+
+	contributions, err := repo.Contributions().ListByCircle(circleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Batch fetch the contributors
+	seen := make(map[uint]bool)
+	userIDs := make([]uint, 0)
+	for _, contribution := range contributions {
+		if !seen[contribution.UserID] {
+			userIDs = append(userIDs, contribution.UserID)
+			seen[contribution.UserID] = true
+		}
+	}
+	users, err := repo.Users().ListByIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	userMap := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+
+	views := make([]ContributionView, len(contributions))
+	for i, contribution := range contributions {
+		user := userMap[contribution.UserID]
+		views[i] = ContributionView{Contribution: contribution, UserName: user.Name}
+		if !user.IsAnonymized() {
+			views[i].UserEmail = user.Email
+		}
+	}
+	return views, nil
+}
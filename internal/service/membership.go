@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// MembershipService implements Membership
+type MembershipService struct {
+	repo repository.Repository
+}
+
+// NewMembershipService creates a membership service
+func NewMembershipService(repo repository.Repository) *MembershipService {
+	return &MembershipService{repo: repo}
+}
+
+// AddMember implements Membership. Only admins can invite, and only users
+// with a verified email address can be invited. Every active member gets a
+// vote; the inviter's own vote counts as approval.
+func (s *MembershipService) AddMember(ctx context.Context, circleID, inviterID, userID uint, role string) error {
+	return s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		if _, err := lockCircle(tx, circleID); err != nil {
+			return err
+		}
+
+		if err := requireAdmin(tx, circleID, inviterID); err != nil {
+			return err
+		}
+
+		user, err := tx.Users().Get(userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if !user.IsEmailVerified() {
+			return ErrEmailNotVerified
+		}
+
+		if _, err := tx.Members().Get(circleID, userID); err == nil {
+			return ErrAlreadyMember
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		// Default role to member if not specified
+		if role == "" {
+			role = "member"
+		}
+
+		if err := tx.Members().Create(&models.CircleMember{
+			CircleID: circleID,
+			UserID:   userID,
+			Role:     role,
+			Status:   "pending",
+		}); errors.Is(err, repository.ErrConflict) {
+			// A concurrent invitation inserted the row first
+			return ErrAlreadyMember
+		} else if err != nil {
+			return err
+		}
+
+		activeMembers, err := tx.Members().ListActive(circleID)
+		if err != nil {
+			return err
+		}
+		for _, m := range activeMembers {
+			if err := tx.Approvals().CreateMemberApproval(&models.MemberApproval{
+				CircleID:       circleID,
+				PendingUserID:  userID,
+				ApproverUserID: m.UserID,
+				Approved:       m.UserID == inviterID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Publish(events.New(events.MemberAdded, circleID, inviterID, map[string]interface{}{
+			"user_id":    userID,
+			"role":       role,
+			"invited_by": inviterID,
+		})); err != nil {
+			return err
+		}
+
+		// The inviter may have been the only vote needed
+		return CompleteApproval(tx, circleID, userID, inviterID)
+	})
+}
+
+// ApproveMember implements Membership
+func (s *MembershipService) ApproveMember(ctx context.Context, circleID, pendingUserID, approverID uint) error {
+	return s.repo.Transaction(ctx, func(tx repository.Repository) error {
+		// Votes cast together are counted one after the other, so exactly one
+		// of them completes the approval
+		if _, err := lockCircle(tx, circleID); errors.Is(err, ErrCircleNotFound) {
+			return ErrApprovalNotFound
+		} else if err != nil {
+			return err
+		}
+
+		found, err := tx.Approvals().ApproveMember(circleID, pendingUserID, approverID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrApprovalNotFound
+		}
+
+		if err := tx.Publish(events.New(events.VoteCast, circleID, approverID, map[string]interface{}{
+			"vote":    "member",
+			"user_id": pendingUserID,
+		})); err != nil {
+			return err
+		}
+
+		return CompleteApproval(tx, circleID, pendingUserID, approverID)
+	})
+}
+
+// CompleteApproval activates a pending user once all members have approved
+// them and publishes member_approved. actorID is the user whose action
+// completed the approval. Call it inside the transaction that changed the
+// votes, after locking the circle.
+func CompleteApproval(tx repository.Repository, circleID, pendingUserID, actorID uint) error {
+	required, approved, err := tx.Approvals().CountMemberApprovals(circleID, pendingUserID)
+	if err != nil {
+		return err
+	}
+	if required == 0 || required != approved {
+		return nil
+	}
+
+	activated, err := tx.Members().Activate(circleID, pendingUserID)
+	if err != nil || !activated {
+		return err
+	}
+
+	return tx.Publish(events.New(events.MemberApproved, circleID, actorID, map[string]interface{}{
+		"user_id": pendingUserID,
+	}))
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// memory is an in-memory repository.Repository for testing the services.
+// Transactions work on a copy of the store that replaces it on commit.
+type memory struct {
+	users           []models.User
+	circles         []models.Circle
+	members         []models.CircleMember
+	memberApprovals []models.MemberApproval
+	amountApprovals []models.AmountApproval
+	contributions   []models.Contribution
+	events          []events.Event
+	nextID          uint
+}
+
+func newMemory() *memory {
+	return &memory{nextID: 1}
+}
+
+func (m *memory) id() uint {
+	m.nextID++
+	return m.nextID
+}
+
+func (m *memory) clone() *memory {
+	return &memory{
+		users:           append([]models.User(nil), m.users...),
+		circles:         append([]models.Circle(nil), m.circles...),
+		members:         append([]models.CircleMember(nil), m.members...),
+		memberApprovals: append([]models.MemberApproval(nil), m.memberApprovals...),
+		amountApprovals: append([]models.AmountApproval(nil), m.amountApprovals...),
+		contributions:   append([]models.Contribution(nil), m.contributions...),
+		events:          append([]events.Event(nil), m.events...),
+		nextID:          m.nextID,
+	}
+}
+
+func (m *memory) WithContext(ctx context.Context) repository.Repository { return m }
+
+func (m *memory) Transaction(ctx context.Context, fn func(tx repository.Repository) error) error {
+	tx := m.clone()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*m = *tx
+	return nil
+}
+
+func (m *memory) Publish(event events.Event) error {
+	m.events = append(m.events, event)
+	return nil
+}
+
+func (m *memory) Users() repository.UserRepository                 { return memoryUsers{m} }
+func (m *memory) Circles() repository.CircleRepository             { return memoryCircles{m} }
+func (m *memory) Members() repository.MemberRepository             { return memoryMembers{m} }
+func (m *memory) Approvals() repository.ApprovalRepository         { return memoryApprovals{m} }
+func (m *memory) Contributions() repository.ContributionRepository { return memoryContributions{m} }
+
+// eventTypes lists the types of the published events in order
+func (m *memory) eventTypes() []events.Type {
+	types := make([]events.Type, len(m.events))
+	for i, event := range m.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+type memoryUsers struct{ m *memory }
+
+func (r memoryUsers) Get(id uint) (*models.User, error) {
+	for i := range r.m.users {
+		if r.m.users[i].ID == id {
+			user := r.m.users[i]
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r memoryUsers) GetByEmail(email string) (*models.User, error) {
+	for i := range r.m.users {
+		if r.m.users[i].Email == email {
+			user := r.m.users[i]
+			return &user, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r memoryUsers) ListByIDs(ids []uint) ([]models.User, error) {
+	var users []models.User
+	for _, id := range ids {
+		if user, err := r.Get(id); err == nil {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (r memoryUsers) Create(user *models.User) error {
+	user.ID = r.m.id()
+	r.m.users = append(r.m.users, *user)
+	return nil
+}
+
+func (r memoryUsers) Update(id uint, updates map[string]interface{}) error {
+	for i := range r.m.users {
+		if r.m.users[i].ID == id {
+			if password, ok := updates["password"]; ok {
+				r.m.users[i].Password = password.(string)
+			}
+		}
+	}
+	return nil
+}
+
+type memoryCircles struct{ m *memory }
+
+func (r memoryCircles) Get(id uint) (*models.Circle, error) {
+	for i := range r.m.circles {
+		if r.m.circles[i].ID == id {
+			circle := r.m.circles[i]
+			return &circle, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+// withMembers loads the users belonging to a circle, like Preload("Members")
+func (r memoryCircles) withMembers(circle models.Circle) models.Circle {
+	circle.Members = nil
+	for _, member := range r.m.members {
+		if member.CircleID == circle.ID {
+			if user, err := (memoryUsers{r.m}).Get(member.UserID); err == nil {
+				circle.Members = append(circle.Members, *user)
+			}
+		}
+	}
+	return circle
+}
+
+func (r memoryCircles) GetForMember(id, userID uint) (*models.Circle, error) {
+	if _, err := (memoryMembers{r.m}).Get(id, userID); err != nil {
+		return nil, err
+	}
+	circle, err := r.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	*circle = r.withMembers(*circle)
+	return circle, nil
+}
+
+func (r memoryCircles) ListForMember(userID uint) ([]models.Circle, error) {
+	var circles []models.Circle
+	for _, circle := range r.m.circles {
+		if _, err := (memoryMembers{r.m}).Get(circle.ID, userID); err == nil {
+			circles = append(circles, r.withMembers(circle))
+		}
+	}
+	return circles, nil
+}
+
+// Lock is Get: the services' tests don't run transactions concurrently
+func (r memoryCircles) Lock(id uint) (*models.Circle, error) {
+	return r.Get(id)
+}
+
+func (r memoryCircles) Create(circle *models.Circle) error {
+	circle.ID = r.m.id()
+	circle.Version = 1
+	r.m.circles = append(r.m.circles, *circle)
+	return nil
+}
+
+func (r memoryCircles) Update(updated *models.Circle, updates map[string]interface{}) error {
+	for i := range r.m.circles {
+		circle := &r.m.circles[i]
+		if circle.ID != updated.ID {
+			continue
+		}
+		if circle.Version != updated.Version {
+			return repository.ErrConflict
+		}
+		circle.Version++
+		updated.Version++
+		for column, value := range updates {
+			switch column {
+			case "amount_per_member":
+				circle.AmountPerMember = value.(uint)
+			case "proposed_amount":
+				switch amount := value.(type) {
+				case uint:
+					circle.ProposedAmount = amount
+				case int:
+					circle.ProposedAmount = uint(amount)
+				}
+			case "require_two_factor":
+				circle.RequireTwoFactor = value.(bool)
+			case "payment_due_day":
+				circle.PaymentDueDay = value.(int)
+			case "reminders_enabled":
+				circle.RemindersEnabled = value.(bool)
+			case "reminder_days_before":
+				circle.ReminderDaysBefore = value.(int)
+			case "overdue_reminder_days":
+				circle.OverdueReminderDays = value.(int)
+			}
+		}
+	}
+	return nil
+}
+
+type memoryMembers struct{ m *memory }
+
+func (r memoryMembers) Get(circleID, userID uint) (*models.CircleMember, error) {
+	for i := range r.m.members {
+		if r.m.members[i].CircleID == circleID && r.m.members[i].UserID == userID {
+			member := r.m.members[i]
+			return &member, nil
+		}
+	}
+	return nil, repository.ErrNotFound
+}
+
+func (r memoryMembers) ListByCircles(circleIDs []uint) ([]models.CircleMember, error) {
+	var members []models.CircleMember
+	for _, member := range r.m.members {
+		for _, id := range circleIDs {
+			if member.CircleID == id {
+				members = append(members, member)
+			}
+		}
+	}
+	return members, nil
+}
+
+func (r memoryMembers) ListActive(circleID uint) ([]models.CircleMember, error) {
+	var members []models.CircleMember
+	for _, member := range r.m.members {
+		if member.CircleID == circleID && member.Status == "active" {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+func (r memoryMembers) Create(member *models.CircleMember) error {
+	member.ID = r.m.id()
+	r.m.members = append(r.m.members, *member)
+	return nil
+}
+
+func (r memoryMembers) Activate(circleID, userID uint) (bool, error) {
+	for i := range r.m.members {
+		member := &r.m.members[i]
+		if member.CircleID == circleID && member.UserID == userID && member.Status == "pending" {
+			member.Status = "active"
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memoryApprovals struct{ m *memory }
+
+func (r memoryApprovals) CreateMemberApproval(approval *models.MemberApproval) error {
+	approval.ID = r.m.id()
+	r.m.memberApprovals = append(r.m.memberApprovals, *approval)
+	return nil
+}
+
+func (r memoryApprovals) ApproveMember(circleID, pendingUserID, approverID uint) (bool, error) {
+	for i := range r.m.memberApprovals {
+		approval := &r.m.memberApprovals[i]
+		if approval.CircleID == circleID && approval.PendingUserID == pendingUserID && approval.ApproverUserID == approverID {
+			approval.Approved = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryApprovals) CountMemberApprovals(circleID, pendingUserID uint) (required, approved int64, err error) {
+	for _, approval := range r.m.memberApprovals {
+		if approval.CircleID == circleID && approval.PendingUserID == pendingUserID {
+			required++
+			if approval.Approved {
+				approved++
+			}
+		}
+	}
+	return required, approved, nil
+}
+
+func (r memoryApprovals) PendingMemberApprovals(circleIDs []uint, approverID uint) ([]models.MemberApproval, error) {
+	var approvals []models.MemberApproval
+	for _, approval := range r.m.memberApprovals {
+		for _, id := range circleIDs {
+			if approval.CircleID == id && approval.ApproverUserID == approverID && !approval.Approved {
+				approvals = append(approvals, approval)
+			}
+		}
+	}
+	return approvals, nil
+}
+
+func (r memoryApprovals) ListAmountApprovals(circleID uint) ([]models.AmountApproval, error) {
+	var approvals []models.AmountApproval
+	for _, approval := range r.m.amountApprovals {
+		if approval.CircleID == circleID {
+			approvals = append(approvals, approval)
+		}
+	}
+	return approvals, nil
+}
+
+func (r memoryApprovals) PendingAmountApprovals(circleIDs []uint, approverID uint) ([]models.AmountApproval, error) {
+	var approvals []models.AmountApproval
+	for _, approval := range r.m.amountApprovals {
+		for _, id := range circleIDs {
+			if approval.CircleID == id && approval.ApproverID == approverID && !approval.Approved {
+				approvals = append(approvals, approval)
+			}
+		}
+	}
+	return approvals, nil
+}
+
+func (r memoryApprovals) ReplaceAmountApprovals(circleID uint, approvals []models.AmountApproval) error {
+	if err := r.DeleteAmountApprovals(circleID); err != nil {
+		return err
+	}
+	for _, approval := range approvals {
+		approval.ID = r.m.id()
+		r.m.amountApprovals = append(r.m.amountApprovals, approval)
+	}
+	return nil
+}
+
+func (r memoryApprovals) ApproveAmount(circleID, approverID uint) (bool, error) {
+	for i := range r.m.amountApprovals {
+		approval := &r.m.amountApprovals[i]
+		if approval.CircleID == circleID && approval.ApproverID == approverID && !approval.Approved {
+			approval.Approved = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r memoryApprovals) DeleteAmountApprovals(circleID uint) error {
+	kept := r.m.amountApprovals[:0]
+	for _, approval := range r.m.amountApprovals {
+		if approval.CircleID != circleID {
+			kept = append(kept, approval)
+		}
+	}
+	r.m.amountApprovals = kept
+	return nil
+}
+
+type memoryContributions struct{ m *memory }
+
+func (r memoryContributions) Create(contribution *models.Contribution) error {
+	contribution.ID = r.m.id()
+	r.m.contributions = append(r.m.contributions, *contribution)
+	return nil
+}
+
+func (r memoryContributions) ListByCircle(circleID uint) ([]models.Contribution, error) {
+	var contributions []models.Contribution
+	for _, contribution := range r.m.contributions {
+		if contribution.CircleID == circleID {
+			contributions = append(contributions, contribution)
+		}
+	}
+	// IDs increase, so the highest is the newest
+	sort.Slice(contributions, func(i, j int) bool { return contributions[i].ID > contributions[j].ID })
+	return contributions, nil
+}
@@ -0,0 +1,70 @@
+// Package service holds the domain rules for circles, membership,
+// contributions and authentication. Services work on a repository.Repository
+// and report failures as the errors below, which handlers map to responses.
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/Sudan23/dhukuti/internal/repository"
+)
+
+// Domain errors
+var (
+	ErrCircleNotFound      = errors.New("circle not found")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrNotMember           = errors.New("not a member of the circle")
+	ErrNotActiveMember     = errors.New("not an active member of the circle")
+	ErrNotAdmin            = errors.New("not an admin of the circle")
+	ErrAlreadyMember       = errors.New("user is already a member of the circle")
+	ErrEmailNotVerified    = errors.New("email address is not verified")
+	ErrApprovalNotFound    = errors.New("no pending vote found")
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+	ErrEmailTaken          = errors.New("email address is already registered")
+	ErrInvalidCredentials  = errors.New("invalid email or password")
+	ErrAccountLocked       = errors.New("account is temporarily locked")
+	// ErrConflict is returned when a write loses a race with another request,
+	// e.g. two votes for the same row, and a database constraint rejects it
+	ErrConflict = repository.ErrConflict
+)
+
+// Circles manages circles and their contribution amount
+type Circles interface {
+	Create(ctx context.Context, creatorID uint, input CreateCircleInput) (*models.Circle, error)
+	// Get returns a circle as seen by one of its members
+	Get(ctx context.Context, circleID, userID uint) (*CircleView, error)
+	// List returns the circles the user belongs to
+	List(ctx context.Context, userID uint) ([]CircleView, error)
+	// ProposeAmount starts a vote on a new contribution amount
+	ProposeAmount(ctx context.Context, circleID, userID, amount uint) error
+	// ApproveAmount casts the user's vote on the proposed amount and applies
+	// it once every active member has approved
+	ApproveAmount(ctx context.Context, circleID, userID uint) error
+	UpdateSecurity(ctx context.Context, circleID, userID uint, requireTwoFactor bool) error
+	UpdateReminders(ctx context.Context, circleID, userID uint, input ReminderSettingsInput) (*models.Circle, error)
+}
+
+// Membership manages invitations and the votes on them
+type Membership interface {
+	// AddMember invites a user; they join once every active member approves
+	AddMember(ctx context.Context, circleID, inviterID, userID uint, role string) error
+	// ApproveMember casts approverID's vote on a pending member
+	ApproveMember(ctx context.Context, circleID, pendingUserID, approverID uint) error
+}
+
+// Contributions records and lists contributions
+type Contributions interface {
+	Record(ctx context.Context, circleID, userID uint) (*models.Contribution, error)
+	List(ctx context.Context, circleID, userID uint) ([]ContributionView, error)
+}
+
+// Auth registers users and checks their credentials
+type Auth interface {
+	Register(ctx context.Context, email, name, password string) (*models.User, error)
+	// Login checks a password. When the account exists the user is returned
+	// alongside ErrInvalidCredentials or ErrAccountLocked, so callers can
+	// track failures per account.
+	Login(ctx context.Context, email, password string) (*models.User, error)
+}
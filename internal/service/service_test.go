@@ -0,0 +1,342 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixture is a circle whose creator is its admin
+type fixture struct {
+	repo    *memory
+	circle  *models.Circle
+	admin   uint
+	members []uint // active members besides the admin
+}
+
+func addUser(t *testing.T, repo *memory, email string, verified bool) uint {
+	t.Helper()
+	user := models.User{Email: email, Name: email}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	require.NoError(t, repo.Users().Create(&user))
+	return user.ID
+}
+
+// newFixture creates a circle with an admin and extra active members
+func newFixture(t *testing.T, extraMembers int) *fixture {
+	t.Helper()
+	repo := newMemory()
+	f := &fixture{repo: repo, admin: addUser(t, repo, "admin@example.com", true)}
+
+	circle, err := NewCircleService(repo).Create(context.Background(), f.admin, CreateCircleInput{Name: "Savers", AmountPerMember: 100})
+	require.NoError(t, err)
+	f.circle = circle
+
+	for i := 0; i < extraMembers; i++ {
+		id := addUser(t, repo, string(rune('a'+i))+"@example.com", true)
+		require.NoError(t, repo.Members().Create(&models.CircleMember{CircleID: circle.ID, UserID: id, Role: "member", Status: "active"}))
+		f.members = append(f.members, id)
+	}
+	return f
+}
+
+func (f *fixture) status(t *testing.T, userID uint) string {
+	t.Helper()
+	member, err := f.repo.Members().Get(f.circle.ID, userID)
+	require.NoError(t, err)
+	return member.Status
+}
+
+func TestAddMember(t *testing.T) {
+	tests := []struct {
+		name         string
+		extraMembers int
+		status       string
+		events       []events.Type
+	}{
+		{
+			name:   "admin alone approves on inviting",
+			status: "active",
+			events: []events.Type{events.MemberAdded, events.MemberApproved},
+		},
+		{
+			name:         "other members must vote",
+			extraMembers: 2,
+			status:       "pending",
+			events:       []events.Type{events.MemberAdded},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, tt.extraMembers)
+			invitee := addUser(t, f.repo, "new@example.com", true)
+
+			err := NewMembershipService(f.repo).AddMember(context.Background(), f.circle.ID, f.admin, invitee, "")
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, f.status(t, invitee))
+			assert.Equal(t, tt.events, f.repo.eventTypes())
+
+			member, err := f.repo.Members().Get(f.circle.ID, invitee)
+			require.NoError(t, err)
+			assert.Equal(t, "member", member.Role)
+		})
+	}
+}
+
+func TestAddMemberErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, f *fixture) (circleID, inviterID, userID uint)
+		err   error
+	}{
+		{
+			name: "unknown circle",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				return 999, f.admin, addUser(t, f.repo, "new@example.com", true)
+			},
+			err: ErrCircleNotFound,
+		},
+		{
+			name: "inviter is not an admin",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				return f.circle.ID, f.members[0], addUser(t, f.repo, "new@example.com", true)
+			},
+			err: ErrNotAdmin,
+		},
+		{
+			name: "inviter is not a member",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				outsider := addUser(t, f.repo, "outsider@example.com", true)
+				return f.circle.ID, outsider, addUser(t, f.repo, "new@example.com", true)
+			},
+			err: ErrNotAdmin,
+		},
+		{
+			name: "unknown user",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				return f.circle.ID, f.admin, 999
+			},
+			err: ErrUserNotFound,
+		},
+		{
+			name: "unverified user",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				return f.circle.ID, f.admin, addUser(t, f.repo, "new@example.com", false)
+			},
+			err: ErrEmailNotVerified,
+		},
+		{
+			name: "already a member",
+			setup: func(t *testing.T, f *fixture) (uint, uint, uint) {
+				return f.circle.ID, f.admin, f.members[0]
+			},
+			err: ErrAlreadyMember,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, 1)
+			circleID, inviterID, userID := tt.setup(t, f)
+			members := len(f.repo.members)
+
+			err := NewMembershipService(f.repo).AddMember(context.Background(), circleID, inviterID, userID, "member")
+			assert.ErrorIs(t, err, tt.err)
+			assert.Len(t, f.repo.members, members, "nothing is stored")
+			assert.Empty(t, f.repo.events)
+		})
+	}
+}
+
+func TestApproveMember(t *testing.T) {
+	f := newFixture(t, 2)
+	invitee := addUser(t, f.repo, "new@example.com", true)
+	membership := NewMembershipService(f.repo)
+	ctx := context.Background()
+
+	require.NoError(t, membership.AddMember(ctx, f.circle.ID, f.admin, invitee, "member"))
+
+	// Outsiders have no vote
+	outsider := addUser(t, f.repo, "outsider@example.com", true)
+	assert.ErrorIs(t, membership.ApproveMember(ctx, f.circle.ID, invitee, outsider), ErrApprovalNotFound)
+
+	require.NoError(t, membership.ApproveMember(ctx, f.circle.ID, invitee, f.members[0]))
+	assert.Equal(t, "pending", f.status(t, invitee))
+
+	require.NoError(t, membership.ApproveMember(ctx, f.circle.ID, invitee, f.members[1]))
+	assert.Equal(t, "active", f.status(t, invitee))
+
+	assert.Equal(t, []events.Type{
+		events.MemberAdded,
+		events.VoteCast,
+		events.VoteCast,
+		events.MemberApproved,
+	}, f.repo.eventTypes())
+	assert.Equal(t, f.members[1], f.repo.events[3].ActorID, "the last voter completes the approval")
+}
+
+func TestAmountConsensus(t *testing.T) {
+	f := newFixture(t, 2)
+	circles := NewCircleService(f.repo)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, circles.ProposeAmount(ctx, f.circle.ID, f.members[0], 200), ErrNotAdmin)
+	require.NoError(t, circles.ProposeAmount(ctx, f.circle.ID, f.admin, 200))
+
+	// The proposer has voted already
+	assert.ErrorIs(t, circles.ApproveAmount(ctx, f.circle.ID, f.admin), ErrApprovalNotFound)
+
+	view, err := circles.Get(ctx, f.circle.ID, f.members[0])
+	require.NoError(t, err)
+	assert.True(t, view.NeedsAmountApproval)
+	assert.Equal(t, uint(200), view.Circle.ProposedAmount)
+	assert.Len(t, view.AmountApprovals, 3)
+
+	require.NoError(t, circles.ApproveAmount(ctx, f.circle.ID, f.members[0]))
+	circle, err := f.repo.Circles().Get(f.circle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(100), circle.AmountPerMember, "unchanged until everyone approves")
+
+	require.NoError(t, circles.ApproveAmount(ctx, f.circle.ID, f.members[1]))
+	circle, err = f.repo.Circles().Get(f.circle.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uint(200), circle.AmountPerMember)
+	assert.Zero(t, circle.ProposedAmount)
+	assert.Empty(t, f.repo.amountApprovals, "votes are discarded once applied")
+
+	assert.Equal(t, []events.Type{
+		events.AmountProposed,
+		events.VoteCast,
+		events.VoteCast,
+		events.AmountChanged,
+	}, f.repo.eventTypes())
+}
+
+func TestProposeAmountReplacesVotes(t *testing.T) {
+	f := newFixture(t, 1)
+	circles := NewCircleService(f.repo)
+	ctx := context.Background()
+
+	require.NoError(t, circles.ProposeAmount(ctx, f.circle.ID, f.admin, 200))
+	require.NoError(t, circles.ProposeAmount(ctx, f.circle.ID, f.admin, 300))
+
+	votes, err := f.repo.Approvals().ListAmountApprovals(f.circle.ID)
+	require.NoError(t, err)
+	require.Len(t, votes, 2)
+	for _, vote := range votes {
+		assert.Equal(t, uint(300), vote.ProposedAmount)
+		assert.Equal(t, vote.ApproverID == f.admin, vote.Approved)
+	}
+}
+
+func TestUpdateSecurity(t *testing.T) {
+	f := newFixture(t, 1)
+	circles := NewCircleService(f.repo)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, circles.UpdateSecurity(ctx, f.circle.ID, f.members[0], false), ErrNotAdmin)
+	assert.ErrorIs(t, circles.UpdateSecurity(ctx, f.circle.ID, f.admin, true), ErrTwoFactorNotEnabled)
+
+	now := time.Now()
+	f.repo.users[0].TOTPSecret = "secret"
+	f.repo.users[0].TOTPEnabledAt = &now
+	require.NoError(t, circles.UpdateSecurity(ctx, f.circle.ID, f.admin, true))
+
+	circle, err := f.repo.Circles().Get(f.circle.ID)
+	require.NoError(t, err)
+	assert.True(t, circle.RequireTwoFactor)
+}
+
+func TestRecordContribution(t *testing.T) {
+	f := newFixture(t, 1)
+	pending := addUser(t, f.repo, "pending@example.com", true)
+	require.NoError(t, f.repo.Members().Create(&models.CircleMember{CircleID: f.circle.ID, UserID: pending, Role: "member", Status: "pending"}))
+	outsider := addUser(t, f.repo, "outsider@example.com", true)
+	contributions := NewContributionService(f.repo)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		circleID uint
+		userID   uint
+		err      error
+	}{
+		{name: "active member", circleID: f.circle.ID, userID: f.members[0]},
+		{name: "pending member", circleID: f.circle.ID, userID: pending, err: ErrNotActiveMember},
+		{name: "outsider", circleID: f.circle.ID, userID: outsider, err: ErrNotActiveMember},
+		{name: "unknown circle", circleID: 999, userID: f.members[0], err: ErrCircleNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contribution, err := contributions.Record(ctx, tt.circleID, tt.userID)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, uint(100), contribution.Amount)
+		})
+	}
+
+	// Pending members can see the contributions, outsiders can't
+	views, err := contributions.List(ctx, f.circle.ID, pending)
+	require.NoError(t, err)
+	require.Len(t, views, 1)
+	assert.Equal(t, "a@example.com", views[0].UserEmail)
+
+	_, err = contributions.List(ctx, f.circle.ID, outsider)
+	assert.ErrorIs(t, err, ErrNotMember)
+}
+
+func TestAuth(t *testing.T) {
+	repo := newMemory()
+	auth := NewAuthService(repo)
+	ctx := context.Background()
+
+	user, err := auth.Register(ctx, "ana@example.com", "Ana", "correct horse battery staple")
+	require.NoError(t, err)
+	assert.NotEqual(t, "correct horse battery staple", user.Password)
+
+	_, err = auth.Register(ctx, "ana@example.com", "Ana", "another password")
+	assert.ErrorIs(t, err, ErrEmailTaken)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		user     bool // whether the account is returned
+		err      error
+	}{
+		{name: "correct password", email: "ana@example.com", password: "correct horse battery staple", user: true},
+		{name: "wrong password", email: "ana@example.com", password: "wrong", user: true, err: ErrInvalidCredentials},
+		{name: "unknown email", email: "bob@example.com", password: "wrong", err: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := auth.Login(ctx, tt.email, tt.password)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.user, user != nil)
+		})
+	}
+
+	locked := time.Now().Add(time.Minute)
+	repo.users[0].LockedUntil = &locked
+	_, err = auth.Login(ctx, "ana@example.com", "correct horse battery staple")
+	assert.ErrorIs(t, err, ErrAccountLocked)
+}
@@ -0,0 +1,34 @@
+package sms
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidPhone is returned for numbers that are not in E.164 format
+var ErrInvalidPhone = errors.New("phone number must be in international format, e.g. +9779812345678")
+
+// e164 matches a plus sign followed by a country code and up to 15 digits
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// NormalizePhone returns number in E.164 format. Spaces, dashes, dots and
+// parentheses are removed, and a leading 00 is read as the international
+// prefix; numbers without a country code are rejected.
+func NormalizePhone(number string) (string, error) {
+	number = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(number))
+
+	if strings.HasPrefix(number, "00") {
+		number = "+" + number[2:]
+	}
+	if !e164.MatchString(number) {
+		return "", ErrInvalidPhone
+	}
+	return number, nil
+}
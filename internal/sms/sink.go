@@ -0,0 +1,118 @@
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sink texts approval requests to members with a verified phone number. It
+// is an outbox sink.
+type Sink struct {
+	db          *gorm.DB
+	gateway     Gateway
+	frontendURL string
+}
+
+// NewSink creates an SMS sink
+func NewSink(db *gorm.DB, gateway Gateway, frontendURL string) *Sink {
+	return &Sink{db: db, gateway: gateway, frontendURL: frontendURL}
+}
+
+// Name implements outbox.Sink
+func (s *Sink) Name() string {
+	return "sms"
+}
+
+// Handle implements outbox.Sink
+func (s *Sink) Handle(ctx context.Context, event events.Event) error {
+	db := s.db.WithContext(ctx)
+
+	var circle models.Circle
+	if err := db.First(&circle, event.CircleID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // circle deleted since
+		}
+		return err
+	}
+	link := fmt.Sprintf("%s/circles/%d", s.frontendURL, circle.ID)
+
+	var (
+		voters   []uint
+		template string
+		data     interface{}
+	)
+	switch event.Type {
+	case events.MemberAdded:
+		applicant := event.Uint("user_id")
+		if err := db.Model(&models.MemberApproval{}).
+			Where("circle_id = ? AND pending_user_id = ? AND approved = ?", circle.ID, applicant, false).
+			Pluck("approver_user_id", &voters).Error; err != nil {
+			return err
+		}
+		var name string
+		db.Model(&models.User{}).Where("id = ?", applicant).Pluck("name", &name)
+		template = TemplateMemberApproval
+		data = MemberApprovalData{Circle: circle.Name, Applicant: name, Link: link}
+
+	case events.AmountProposed:
+		if err := db.Model(&models.AmountApproval{}).
+			Where("circle_id = ? AND approved = ?", circle.ID, false).
+			Pluck("approver_id", &voters).Error; err != nil {
+			return err
+		}
+		template = TemplateAmountApproval
+		data = AmountApprovalData{
+			Circle:         circle.Name,
+			CurrentAmount:  event.Uint("current_amount"),
+			ProposedAmount: event.Uint("proposed_amount"),
+			Link:           link,
+		}
+
+	default:
+		return nil
+	}
+	if len(voters) == 0 {
+		return nil
+	}
+
+	body, err := Render(template, data)
+	if err != nil {
+		return err
+	}
+
+	var users []models.User
+	if err := db.Where("id IN ? AND phone_verified_at IS NOT NULL AND phone <> '' AND anonymized_at IS NULL", voters).
+		Find(&users).Error; err != nil {
+		return err
+	}
+	for _, user := range users {
+		if err := s.send(db, user, event.ID, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send texts one user unless they were already texted for the event. A
+// message that fails is forgotten so the redelivered event retries it.
+func (s *Sink) send(db *gorm.DB, user models.User, sourceID, body string) error {
+	record := models.SMSNotification{UserID: user.ID, SourceID: sourceID}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	if err := s.gateway.Send(Message{To: user.Phone, Body: body}); err != nil {
+		return errors.Join(err, db.Delete(&record).Error)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package sms sends text messages through an SMS gateway
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+)
+
+// Message represents an outgoing text message
+type Message struct {
+	To   string // E.164 phone number
+	Body string
+}
+
+// Gateway sends text messages
+type Gateway interface {
+	Send(msg Message) error
+}
+
+// New returns an HTTP gateway when one is configured, otherwise a gateway
+// that only logs messages (useful for local development)
+func New(cfg *config.Config) Gateway {
+	if cfg.SMS.GatewayURL == "" {
+		return &LogGateway{}
+	}
+	return NewHTTPGateway(cfg.SMS.GatewayURL, cfg.SMS.APIKey, cfg.SMS.From)
+}
+
+// HTTPGateway sends text messages through a provider's HTTP API. Each message
+// is POSTed as JSON with the fields from, to and body, authenticated with a
+// bearer API key; any 2xx response means the provider accepted it.
+type HTTPGateway struct {
+	url    string
+	apiKey string
+	from   string
+	client *http.Client
+}
+
+// NewHTTPGateway creates a gateway posting to url
+func NewHTTPGateway(url, apiKey, from string) *HTTPGateway {
+	return &HTTPGateway{
+		url:    url,
+		apiKey: apiKey,
+		from:   from,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send delivers the message to the gateway
+func (g *HTTPGateway) Send(msg Message) error {
+	payload, err := json.Marshal(map[string]string{
+		"from": g.from,
+		"to":   msg.To,
+		"body": msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, g.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to send SMS: gateway returned %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+// LogGateway writes text messages to the application log instead of sending them
+type LogGateway struct{}
+
+// Send logs the message
+func (g *LogGateway) Send(msg Message) error {
+	slog.Info("Text message not sent: no SMS gateway configured", "to", msg.To, "body", msg.Body)
+	return nil
+}
@@ -0,0 +1,91 @@
+package sms
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/sms/smstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPGateway(t *testing.T) {
+	fake := smstest.NewGateway("secret")
+	defer fake.Close()
+
+	gateway := NewHTTPGateway(fake.URL(), "secret", "Dhukuti")
+	require.NoError(t, gateway.Send(Message{To: "+9779812345678", Body: "Hello"}))
+	assert.Equal(t, []smstest.Message{{From: "Dhukuti", To: "+9779812345678", Body: "Hello"}}, fake.Messages())
+
+	fake.FailWith(http.StatusServiceUnavailable)
+	assert.Error(t, gateway.Send(Message{To: "+9779812345678", Body: "Hello again"}))
+
+	wrongKey := NewHTTPGateway(fake.URL(), "wrong", "Dhukuti")
+	fake.FailWith(http.StatusAccepted)
+	assert.Error(t, wrongKey.Send(Message{To: "+9779812345678", Body: "Hello"}))
+	assert.Len(t, fake.Messages(), 1)
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		valid bool
+	}{
+		{"+9779812345678", "+9779812345678", true},
+		{"+977 981-234-5678", "+9779812345678", true},
+		{"+44 (20) 7946.0958", "+442079460958", true},
+		{"00977 9812345678", "+9779812345678", true},
+		{"9812345678", "", false},
+		{"+0123456789", "", false},
+		{"+12345", "", false},
+		{"+1234567890123456", "", false},
+		{"+97798123abc", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := NormalizePhone(tt.input)
+		if !tt.valid {
+			assert.ErrorIs(t, err, ErrInvalidPhone, tt.input)
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got)
+	}
+}
+
+func TestRender(t *testing.T) {
+	due := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+	}{
+		{"verification code", TemplateVerificationCode, VerificationCodeData{Code: "123456", Expiry: 10 * time.Minute},
+			"Your Dhukuti verification code is 123456. It expires in 10m0s. Don't share it with anyone."},
+		{"payment due", TemplatePaymentDue, ReminderData{Circle: "Family", Amount: 5000, DueDate: due, Link: "https://d.example/circles/1"},
+			"Dhukuti: your 5000 contribution to Family is due on 5 Mar. https://d.example/circles/1"},
+		{"payment overdue", TemplatePaymentOverdue, ReminderData{Circle: "Family", Amount: 5000, DueDate: due, Outstanding: 1, Link: "L"},
+			"Dhukuti: your 5000 contribution to Family was due on 5 Mar. L"},
+		{"several payments overdue", TemplatePaymentOverdue, ReminderData{Circle: "Family", Amount: 5000, DueDate: due, Outstanding: 2, Link: "L"},
+			"Dhukuti: your 5000 contribution to Family was due on 5 Mar. 2 payments are unpaid. L"},
+		{"member approval", TemplateMemberApproval, MemberApprovalData{Circle: "Family", Applicant: "Sita", Link: "L"},
+			"Dhukuti: Sita wants to join Family. Please approve them: L"},
+		{"amount approval", TemplateAmountApproval, AmountApprovalData{Circle: "Family", CurrentAmount: 5000, ProposedAmount: 6000, Link: "L"},
+			"Dhukuti: a change of the Family contribution from 5000 to 6000 needs your vote: L"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.template, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.False(t, strings.Contains(got, "\n"))
+		})
+	}
+
+	_, err := Render("unknown", nil)
+	assert.Error(t, err)
+}
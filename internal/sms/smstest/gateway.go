@@ -0,0 +1,85 @@
+// Package smstest provides a fake SMS gateway for tests
+package smstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Message is a text message received by the gateway
+type Message struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Body string `json:"body"`
+}
+
+// Gateway is an SMS provider backed by httptest. It accepts messages in the
+// format sent by sms.HTTPGateway and records them.
+type Gateway struct {
+	Server *httptest.Server
+	APIKey string
+
+	mu       sync.Mutex
+	messages []Message
+	status   int
+}
+
+// NewGateway starts a gateway that requires the given API key
+func NewGateway(apiKey string) *Gateway {
+	g := &Gateway{APIKey: apiKey, status: http.StatusAccepted}
+	g.Server = httptest.NewServer(http.HandlerFunc(g.handle))
+	return g
+}
+
+// URL returns the endpoint messages are posted to
+func (g *Gateway) URL() string {
+	return g.Server.URL + "/messages"
+}
+
+// Close shuts the gateway down
+func (g *Gateway) Close() {
+	g.Server.Close()
+}
+
+// Messages returns the messages received so far
+func (g *Gateway) Messages() []Message {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Message(nil), g.messages...)
+}
+
+// FailWith makes the gateway reject messages with status, e.g. to simulate
+// an outage. A 2xx status makes it accept them again.
+func (g *Gateway) FailWith(status int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.status = status
+}
+
+func (g *Gateway) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/messages" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+g.APIKey {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.To == "" || msg.Body == "" {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.status < 200 || g.status > 299 {
+		http.Error(w, "unavailable", g.status)
+		return
+	}
+	g.messages = append(g.messages, msg)
+	w.WriteHeader(g.status)
+}
@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template names
+const (
+	TemplateVerificationCode = "verification_code"
+	TemplatePaymentDue       = "payment_due"
+	TemplatePaymentOverdue   = "payment_overdue"
+	TemplateMemberApproval   = "member_approval"
+	TemplateAmountApproval   = "amount_approval"
+)
+
+// VerificationCodeData fills TemplateVerificationCode
+type VerificationCodeData struct {
+	Code   string
+	Expiry time.Duration
+}
+
+// ReminderData fills TemplatePaymentDue and TemplatePaymentOverdue
+type ReminderData struct {
+	Circle      string
+	Amount      uint
+	DueDate     time.Time
+	Outstanding int // unpaid contributions past their due date
+	Link        string
+}
+
+// MemberApprovalData fills TemplateMemberApproval
+type MemberApprovalData struct {
+	Circle    string
+	Applicant string
+	Link      string
+}
+
+// AmountApprovalData fills TemplateAmountApproval
+type AmountApprovalData struct {
+	Circle         string
+	CurrentAmount  uint
+	ProposedAmount uint
+	Link           string
+}
+
+// templates are kept short; messages over 160 characters are split by most
+// gateways and charged per part
+var templates = template.Must(template.New("sms").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("2 Jan") },
+}).Parse(`
+{{define "verification_code"}}Your Dhukuti verification code is {{.Code}}. It expires in {{.Expiry}}. Don't share it with anyone.{{end}}
+{{define "payment_due"}}Dhukuti: your {{.Amount}} contribution to {{.Circle}} is due on {{date .DueDate}}. {{.Link}}{{end}}
+{{define "payment_overdue"}}Dhukuti: your {{.Amount}} contribution to {{.Circle}} was due on {{date .DueDate}}.{{if gt .Outstanding 1}} {{.Outstanding}} payments are unpaid.{{end}} {{.Link}}{{end}}
+{{define "member_approval"}}Dhukuti: {{.Applicant}} wants to join {{.Circle}}. Please approve them: {{.Link}}{{end}}
+{{define "amount_approval"}}Dhukuti: a change of the {{.Circle}} contribution from {{.CurrentAmount}} to {{.ProposedAmount}} needs your vote: {{.Link}}{{end}}
+`))
+
+// Render fills the named template with data
+func Render(name string, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := templates.ExecuteTemplate(&b, name, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
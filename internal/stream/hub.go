@@ -0,0 +1,246 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+const (
+	// subscriptionBuffer is how many events a slow client may fall behind
+	// before it is disconnected; it then resumes from its last event ID
+	subscriptionBuffer = 64
+
+	// pollInterval bounds how long an event can go unnoticed if a
+	// notification is lost, e.g. while reconnecting to the database
+	pollInterval = 30 * time.Second
+
+	// sqlitePollInterval is how often the table is read on SQLite, which
+	// has no notifications
+	sqlitePollInterval = time.Second
+
+	// catchUpBatch is the number of events read from the table at once
+	catchUpBatch = 500
+)
+
+// Subscription receives the events of one connected client. C is closed when
+// the client falls too far behind or the subscription is cancelled.
+type Subscription struct {
+	UserID uint
+	C      <-chan models.StreamEvent
+
+	c      chan models.StreamEvent
+	closed bool
+}
+
+// Hub fans stream events out to the subscribers connected to this instance
+type Hub struct {
+	db  *gorm.DB
+	dsn string
+	cfg config.StreamConfig
+
+	mu          sync.Mutex
+	subscribers map[uint]map[*Subscription]struct{}
+	lastID      uint64
+}
+
+// NewHub creates a hub listening on the database at dsn
+func NewHub(db *gorm.DB, dsn string, cfg config.StreamConfig) *Hub {
+	return &Hub{
+		db:          db,
+		dsn:         dsn,
+		cfg:         cfg,
+		subscribers: make(map[uint]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe registers a client of the user
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	c := make(chan models.StreamEvent, subscriptionBuffer)
+	sub := &Subscription{UserID: userID, C: c, c: c}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[userID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a client
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// DisconnectAll ends every client's stream, so the server can shut down;
+// clients reconnect to another instance and resume from their last event
+func (h *Hub) DisconnectAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, subs := range h.subscribers {
+		for sub := range subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// remove deletes a subscription and closes its channel. Callers must hold
+// the lock.
+func (h *Hub) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.c)
+
+	delete(h.subscribers[sub.UserID], sub)
+	if len(h.subscribers[sub.UserID]) == 0 {
+		delete(h.subscribers, sub.UserID)
+	}
+}
+
+// Run listens for new events until ctx is cancelled, reconnecting to the
+// database when the connection drops
+func (h *Hub) Run(ctx context.Context) {
+	// Start from the current end of the stream; older events are only replayed
+	// to clients that ask for them
+	if err := h.db.Model(&models.StreamEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&h.lastID).Error; err != nil {
+		slog.Error("Failed to read stream position", "error", err)
+	}
+
+	lastCleanup := time.Time{}
+	for ctx.Err() == nil {
+		err := h.listen(ctx, func() {
+			if time.Since(lastCleanup) > time.Hour {
+				if err := cleanup(h.db, h.cfg.Retention); err != nil {
+					slog.Error("Failed to delete old stream events", "error", err)
+				}
+				lastCleanup = time.Now()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			slog.Warn("Stream listener stopped; reconnecting", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}
+}
+
+// listen holds a dedicated connection in LISTEN mode and catches up with the
+// table whenever a notification arrives or pollInterval passes without one
+func (h *Hub) listen(ctx context.Context, idle func()) error {
+	if !database.IsPostgres(h.db) {
+		return h.poll(ctx, idle)
+	}
+
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	for {
+		// Events appended while the listener was not connected are picked up here
+		if err := h.catchUp(ctx); err != nil {
+			return err
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, pollInterval)
+		_, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			idle()
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// poll catches up with the table every sqlitePollInterval, for databases
+// without notifications
+func (h *Hub) poll(ctx context.Context, idle func()) error {
+	ticker := time.NewTicker(sqlitePollInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.catchUp(ctx); err != nil {
+			return err
+		}
+		idle()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// catchUp broadcasts every event after the last one seen
+func (h *Hub) catchUp(ctx context.Context) error {
+	for {
+		var batch []models.StreamEvent
+		if err := h.db.WithContext(ctx).Where("id > ?", h.lastID).Order("id").Limit(catchUpBatch).Find(&batch).Error; err != nil {
+			return err
+		}
+
+		for _, event := range batch {
+			h.broadcast(ctx, event)
+			h.lastID = event.ID
+		}
+		if len(batch) < catchUpBatch {
+			return nil
+		}
+	}
+}
+
+// broadcast sends an event to the connected members of its circle
+func (h *Hub) broadcast(ctx context.Context, event models.StreamEvent) {
+	h.mu.Lock()
+	idle := len(h.subscribers) == 0
+	h.mu.Unlock()
+	if idle {
+		return
+	}
+
+	// Pending members are included so invitees see their approval happen
+	var members []uint
+	if err := h.db.WithContext(ctx).Model(&models.CircleMember{}).
+		Where("circle_id = ?", event.CircleID).
+		Pluck("user_id", &members).Error; err != nil {
+		slog.Error("Failed to load circle members for stream event", "circle_id", event.CircleID, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, userID := range members {
+		for sub := range h.subscribers[userID] {
+			select {
+			case sub.c <- event:
+			default:
+				// Too far behind; the client reconnects and replays from its
+				// last event ID
+				h.remove(sub)
+			}
+		}
+	}
+}
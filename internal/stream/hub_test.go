@@ -0,0 +1,40 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsubscribeClosesChannelOnce(t *testing.T) {
+	hub := NewHub(nil, "", config.StreamConfig{})
+
+	first := hub.Subscribe(1)
+	second := hub.Subscribe(1)
+	assert.Len(t, hub.subscribers[1], 2)
+
+	hub.Unsubscribe(first)
+	hub.Unsubscribe(first)
+	_, open := <-first.C
+	assert.False(t, open)
+	assert.Len(t, hub.subscribers[1], 1)
+
+	hub.Unsubscribe(second)
+	assert.NotContains(t, hub.subscribers, uint(1))
+}
+
+func TestDisconnectAllClosesEveryChannel(t *testing.T) {
+	hub := NewHub(nil, "", config.StreamConfig{})
+
+	subs := []*Subscription{hub.Subscribe(1), hub.Subscribe(1), hub.Subscribe(2)}
+	hub.DisconnectAll()
+	for _, sub := range subs {
+		_, open := <-sub.C
+		assert.False(t, open)
+	}
+	assert.Empty(t, hub.subscribers)
+
+	// Clients that already left are not closed twice
+	hub.Unsubscribe(subs[0])
+}
@@ -0,0 +1,111 @@
+// Package stream pushes circle events to connected users in real time.
+//
+// The Sink appends each event to the stream_events table and announces it
+// with Postgres NOTIFY. Every API instance runs a Hub that LISTENs for these
+// announcements and forwards the events to its own connected subscribers,
+// so a user sees every event whichever instance they are connected to.
+// On SQLite, which serves a single instance, the Hub polls the table instead.
+// Events keep their stream ID, which clients use to resume after a
+// disconnect.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/database"
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Channel is the Postgres notification channel for new stream events
+const Channel = "dhukuti_stream"
+
+// appendLockKey is the advisory lock serialising appends to the stream
+const appendLockKey = 0x64686b0001
+
+// Sink appends events to the stream. It is an outbox sink.
+type Sink struct {
+	db *gorm.DB
+}
+
+// NewSink creates a stream sink
+func NewSink(db *gorm.DB) *Sink {
+	return &Sink{db: db}
+}
+
+// Name implements outbox.Sink
+func (s *Sink) Name() string {
+	return "stream"
+}
+
+// Handle implements outbox.Sink
+func (s *Sink) Handle(ctx context.Context, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Appends are serialised so IDs become visible in increasing order;
+		// otherwise a client resuming after ID n could miss a smaller ID
+		// committed after it. SQLite serialises all writes already.
+		postgres := database.IsPostgres(tx)
+		if postgres {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", appendLockKey).Error; err != nil {
+				return err
+			}
+		}
+
+		row := models.StreamEvent{
+			EventID:   event.ID,
+			EventType: string(event.Type),
+			CircleID:  event.CircleID,
+			Payload:   string(payload),
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil // already appended
+		}
+
+		if !postgres {
+			return nil // hubs poll for new events
+		}
+
+		// Delivered to listeners when the transaction commits
+		return tx.Exec("SELECT pg_notify(?, ?)", Channel, strconv.FormatUint(row.ID, 10)).Error
+	})
+}
+
+// Replay returns up to limit events after afterID from the circles the user
+// belongs to. reset is true if events after afterID have already been
+// deleted, in which case the client has missed events and should reload.
+func Replay(db *gorm.DB, userID uint, afterID uint64, limit int) (replayed []models.StreamEvent, reset bool, err error) {
+	var oldest uint64
+	if err := db.Model(&models.StreamEvent{}).Select("COALESCE(MIN(id), 0)").Scan(&oldest).Error; err != nil {
+		return nil, false, err
+	}
+	if oldest > afterID+1 {
+		reset = true
+	}
+
+	err = db.Where("id > ?", afterID).
+		Where("circle_id IN (?)", db.Model(&models.CircleMember{}).Select("circle_id").Where("user_id = ?", userID)).
+		Order("id").
+		Limit(limit).
+		Find(&replayed).Error
+	return replayed, reset, err
+}
+
+// cleanup deletes events past the retention period
+func cleanup(db *gorm.DB, retention time.Duration) error {
+	return db.Where("created_at < ?", time.Now().Add(-retention)).Delete(&models.StreamEvent{}).Error
+}
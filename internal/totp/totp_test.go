@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret is the SHA1 seed from RFC 6238 Appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAtRFCVectors(t *testing.T) {
+	// RFC 6238 publishes 8-digit codes; the 6-digit code is the low-order digits
+	tests := []struct {
+		unix     int64
+		expected string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := CodeAt(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.expected, code, "unix time %d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Unix(1700000000, 0)
+	code, err := CodeAt(secret, Step(now))
+	assert.NoError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// Previous step is accepted within the skew window
+	_, ok = Validate(secret, code, now.Add(Period*time.Second))
+	assert.True(t, ok, "code from the previous step should be accepted")
+
+	// Codes outside the window are rejected
+	_, ok = Validate(secret, code, now.Add(3*Period*time.Second))
+	assert.False(t, ok, "stale code should be rejected")
+
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok, "short code should be rejected")
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Dhukuti", "user@example.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Dhukuti:user@example.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Dhukuti")
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"errors"
+
+	"github.com/Sudan23/dhukuti/internal/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// spanKey stores a query's span on the statement between the callbacks
+const spanKey = "tracing:span"
+
+// Plugin is a GORM plugin that runs every query in a client span, a child of
+// the span in the query's context. Spans carry the SQL with its placeholders;
+// like the logs, they never contain the values bound to it, and errors are
+// redacted as they would be in the logs.
+type Plugin struct{}
+
+// NewPlugin creates the tracing plugin; register it with db.Use
+func NewPlugin() *Plugin {
+	return &Plugin{}
+}
+
+// Name identifies the plugin to GORM
+func (p *Plugin) Name() string {
+	return "tracing"
+}
+
+// Initialize wraps each of GORM's query callbacks in a span
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", startSpan("INSERT")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", endSpan),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", startSpan("SELECT")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", endSpan),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", startSpan("UPDATE")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", endSpan),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startSpan("DELETE")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", endSpan),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", startSpan("ROW")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", endSpan),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", startSpan("RAW")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", endSpan),
+	)
+}
+
+// startSpan returns a callback starting the span of a query
+func startSpan(operation string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		name := operation
+		if tx.Statement.Table != "" {
+			name += " " + tx.Statement.Table
+		}
+		_, span := tracer().Start(tx.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				dbSystem(tx),
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(tx.Statement.Table),
+			),
+		)
+		tx.InstanceSet(spanKey, span)
+	}
+}
+
+// endSpan records the outcome of a query and ends its span
+func endSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		// Constraint errors quote the values that conflicted
+		message := logging.RedactString(tx.Error.Error())
+		span.RecordError(errors.New(message))
+		span.SetStatus(codes.Error, message)
+	}
+}
+
+// dbSystem names the database in the semantic conventions' terms
+func dbSystem(tx *gorm.DB) attribute.KeyValue {
+	switch tx.Dialector.Name() {
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "sqlite":
+		return semconv.DBSystemSqlite
+	default:
+		return semconv.DBSystemKey.String(tx.Dialector.Name())
+	}
+}
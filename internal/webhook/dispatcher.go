@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/config"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchSize is the number of deliveries claimed per poll
+const batchSize = 20
+
+// errBlockedAddress is returned when a webhook resolves to a private address
+var errBlockedAddress = errors.New("webhook address is not publicly routable")
+
+// Dispatcher sends queued webhook deliveries, retrying failures with
+// exponential backoff. Several API instances can run one each; deliveries
+// are claimed with row locks so each is sent by one instance at a time.
+type Dispatcher struct {
+	db     *gorm.DB
+	cfg    config.WebhookConfig
+	client *http.Client
+}
+
+// NewDispatcher creates a dispatcher
+func NewDispatcher(db *gorm.DB, cfg config.WebhookConfig) *Dispatcher {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivateNetworks {
+		// Checked after DNS resolution, so hostnames cannot sneak past it
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+				return errBlockedAddress
+			}
+			return nil
+		}
+	}
+
+	return &Dispatcher{
+		db:  db,
+		cfg: cfg,
+		client: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+			// Redirects are reported as failures rather than followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Run delivers due webhooks until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue claims and sends the deliveries that are due
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	deliveries, err := d.claim()
+	if err != nil {
+		slog.Error("Failed to claim webhook deliveries", "error", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+}
+
+// claim locks a batch of due deliveries and pushes their next attempt past
+// the request timeout, so other instances skip them while they are in flight
+func (d *Dispatcher) claim() ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+			Order("next_attempt_at").
+			Limit(batchSize).
+			Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", time.Now().Add(2*d.cfg.Timeout)).Error
+	})
+	return deliveries, err
+}
+
+// deliver sends one delivery and records the outcome
+func (d *Dispatcher) deliver(ctx context.Context, delivery *models.WebhookDelivery) {
+	var webhook models.Webhook
+	if err := d.db.First(&webhook, delivery.WebhookID).Error; err != nil || !webhook.Active {
+		d.db.Model(delivery).Updates(map[string]interface{}{
+			"status":     models.DeliveryFailed,
+			"last_error": "webhook was deleted or disabled",
+		})
+		return
+	}
+
+	status, err := d.send(ctx, &webhook, delivery)
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"response_status": status,
+		"last_error":      "",
+	}
+	switch {
+	case err == nil:
+		updates["status"] = models.DeliverySucceeded
+		updates["delivered_at"] = time.Now()
+	case attempts >= d.cfg.MaxAttempts:
+		updates["status"] = models.DeliveryFailed
+		updates["last_error"] = err.Error()
+		slog.Warn("Giving up on webhook delivery", "delivery_id", delivery.ID, "webhook_id", webhook.ID, "attempts", attempts, "error", err)
+	default:
+		updates["last_error"] = err.Error()
+		updates["next_attempt_at"] = time.Now().Add(Backoff(d.cfg.BackoffBase, attempts))
+	}
+
+	if err := d.db.Model(delivery).Updates(updates).Error; err != nil {
+		slog.Error("Failed to record webhook delivery result", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the payload, returning the response status
+func (d *Dispatcher) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Dhukuti-Webhooks/1.0")
+	req.Header.Set("X-Dhukuti-Event", delivery.EventType)
+	req.Header.Set("X-Dhukuti-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d: %s", resp.StatusCode, snippet)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return resp.StatusCode, nil
+}
+
+// Backoff returns the delay before the retry following the given attempt:
+// base, 2×base, 4×base, ... capped at one day
+func Backoff(base time.Duration, attempt int) time.Duration {
+	const maxDelay = 24 * time.Hour
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// isPublic reports whether ip is a publicly routable unicast address
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
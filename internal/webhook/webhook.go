@@ -0,0 +1,146 @@
+// Package webhook delivers circle events to user-registered HTTP endpoints.
+//
+// Each request carries the event as JSON and an X-Dhukuti-Signature header of
+// the form
+//
+//	t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// keyed with the webhook's secret. Receivers should recompute the HMAC and
+// reject requests with old timestamps to prevent replays; see Verify.
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sudan23/dhukuti/internal/events"
+	"github.com/Sudan23/dhukuti/internal/models"
+	"gorm.io/gorm"
+)
+
+// SignatureHeader carries the payload signature
+const SignatureHeader = "X-Dhukuti-Signature"
+
+// ErrInvalidSignature is returned by Verify for unsigned, forged or stale requests
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header value for a payload sent at timestamp
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + ts + ",v1=" + computeHMAC(secret, ts, body)
+}
+
+// Verify checks a signature header against the payload, rejecting
+// signatures older than tolerance
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := computeHMAC(secret, ts, body)
+	for _, signature := range signatures {
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func computeHMAC(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sink queues a delivery for every webhook subscribed to an event. The
+// Dispatcher sends them. It is an outbox sink and is idempotent: an event
+// handed over twice is only queued once per webhook.
+type Sink struct {
+	db *gorm.DB
+}
+
+// NewSink creates a webhook sink
+func NewSink(db *gorm.DB) *Sink {
+	return &Sink{db: db}
+}
+
+// Name implements outbox.Sink
+func (s *Sink) Name() string {
+	return "webhooks"
+}
+
+// Handle implements outbox.Sink
+func (s *Sink) Handle(ctx context.Context, event events.Event) error {
+	db := s.db.WithContext(ctx)
+
+	// Only webhooks of current active members receive circle events
+	var webhooks []models.Webhook
+	err := db.Where("active = ? AND (circle_id = ? OR circle_id IS NULL)", true, event.CircleID).
+		Where("user_id IN (?)", db.Model(&models.CircleMember{}).
+			Select("user_id").
+			Where("circle_id = ? AND status = ?", event.CircleID, "active")).
+		Where("id NOT IN (?)", db.Model(&models.WebhookDelivery{}).
+			Select("webhook_id").
+			Where("event_id = ?", event.ID)).
+		Find(&webhooks).Error
+	if err != nil {
+		return fmt.Errorf("failed to find webhooks: %w", err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(string(event.Type)) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     string(event.Type),
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := db.Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("failed to queue deliveries: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"type":"member_added"}`)
+	header := Sign("whsec_test", time.Now(), body)
+
+	assert.NoError(t, Verify("whsec_test", header, body, 5*time.Minute))
+	assert.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", header, []byte(`{"type":"amount_changed"}`), 5*time.Minute), ErrInvalidSignature)
+	assert.ErrorIs(t, Verify("whsec_test", "", body, 5*time.Minute), ErrInvalidSignature)
+}
+
+func TestVerifyRejectsStaleTimestamp(t *testing.T) {
+	body := []byte(`{}`)
+	header := Sign("whsec_test", time.Now().Add(-10*time.Minute), body)
+
+	assert.ErrorIs(t, Verify("whsec_test", header, body, 5*time.Minute), ErrInvalidSignature)
+}
+
+func TestSignKnownValue(t *testing.T) {
+	// printf "1700000000.{}" | openssl dgst -sha256 -hmac secret
+	header := Sign("secret", time.Unix(1700000000, 0), []byte("{}"))
+	assert.Equal(t, "t=1700000000,v1=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", header)
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{30, 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Backoff(30*time.Second, tt.attempt), "attempt %d", tt.attempt)
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "192.168.0.10", "169.254.169.254", "::1", "fd00::1", "0.0.0.0"} {
+		assert.False(t, isPublic(net.ParseIP(addr)), addr)
+	}
+	for _, addr := range []string{"93.184.216.34", "2606:4700::6810:85e5"} {
+		assert.True(t, isPublic(net.ParseIP(addr)), addr)
+	}
+}
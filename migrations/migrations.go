@@ -0,0 +1,11 @@
+// Package migrations holds the versioned SQL migrations, one directory per
+// database driver. Files are named <version>_<name>.up.sql and
+// <version>_<name>.down.sql and are compiled into the binary.
+package migrations
+
+import "embed"
+
+// FS contains the postgres and sqlite migration directories
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
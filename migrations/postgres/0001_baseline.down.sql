@@ -0,0 +1,25 @@
+-- Drops every table; all data is lost.
+
+DROP TABLE IF EXISTS "sms_notifications";
+DROP TABLE IF EXISTS "phone_verifications";
+DROP TABLE IF EXISTS "payment_reminders";
+DROP TABLE IF EXISTS "stream_events";
+DROP TABLE IF EXISTS "notification_preferences";
+DROP TABLE IF EXISTS "notifications";
+DROP TABLE IF EXISTS "outbox_messages";
+DROP TABLE IF EXISTS "webhook_deliveries";
+DROP TABLE IF EXISTS "webhooks";
+DROP TABLE IF EXISTS "personal_access_tokens";
+DROP TABLE IF EXISTS "oidc_states";
+DROP TABLE IF EXISTS "external_identities";
+DROP TABLE IF EXISTS "rate_limits";
+DROP TABLE IF EXISTS "account_unlock_tokens";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "email_verification_tokens";
+DROP TABLE IF EXISTS "password_reset_tokens";
+DROP TABLE IF EXISTS "amount_approvals";
+DROP TABLE IF EXISTS "contributions";
+DROP TABLE IF EXISTS "member_approvals";
+DROP TABLE IF EXISTS "circle_members";
+DROP TABLE IF EXISTS "circles";
+DROP TABLE IF EXISTS "users";
@@ -0,0 +1,359 @@
+-- Baseline: the schema as created by AutoMigrate before versioned migrations.
+-- Everything is IF NOT EXISTS so databases created by AutoMigrate adopt it,
+-- whichever release created them: missing tables and indexes are created,
+-- and columns added to existing tables since the first release are added.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "email" text NOT NULL,
+    "password" text NOT NULL,
+    "name" text NOT NULL,
+    "email_verified_at" timestamptz,
+    "avatar_url" text,
+    "phone" text NOT NULL DEFAULT '',
+    "phone_verified_at" timestamptz,
+    "digest_frequency" text NOT NULL DEFAULT 'off',
+    "time_zone" text NOT NULL DEFAULT 'UTC',
+    "digest_sent_at" timestamptz,
+    "totp_secret" text,
+    "totp_enabled_at" timestamptz,
+    "totp_last_step" bigint DEFAULT 0,
+    "locked_until" timestamptz,
+    "anonymized_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+-- Added after the first release
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "avatar_url" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "phone" text NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "phone_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "digest_frequency" text NOT NULL DEFAULT 'off';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "time_zone" text NOT NULL DEFAULT 'UTC';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "digest_sent_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" bigint DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locked_until" timestamptz;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "anonymized_at" timestamptz;
+
+CREATE TABLE IF NOT EXISTS "circles" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    "name" text NOT NULL,
+    "description" text,
+    "amount_per_member" bigint NOT NULL DEFAULT 0,
+    "proposed_amount" bigint DEFAULT 0,
+    "creator_id" bigint NOT NULL,
+    "require_two_factor" boolean NOT NULL DEFAULT false,
+    "payment_due_day" bigint NOT NULL DEFAULT 1,
+    "reminders_enabled" boolean NOT NULL DEFAULT true,
+    "reminder_days_before" bigint NOT NULL DEFAULT 3,
+    "overdue_reminder_days" bigint NOT NULL DEFAULT 7,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_circles_creator" FOREIGN KEY ("creator_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_circles_deleted_at" ON "circles" ("deleted_at");
+-- Added after the first release
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "require_two_factor" boolean NOT NULL DEFAULT false;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "payment_due_day" bigint NOT NULL DEFAULT 1;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "reminders_enabled" boolean NOT NULL DEFAULT true;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "reminder_days_before" bigint NOT NULL DEFAULT 3;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "overdue_reminder_days" bigint NOT NULL DEFAULT 7;
+
+CREATE TABLE IF NOT EXISTS "circle_members" (
+    "id" bigserial,
+    "created_at" timestamptz,
+    "circle_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "role" text NOT NULL DEFAULT 'member',
+    "status" text NOT NULL DEFAULT 'active',
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_circle_members_deleted_at" ON "circle_members" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_circle_status" ON "circle_members" ("circle_id","status");
+CREATE INDEX IF NOT EXISTS "idx_circle_user" ON "circle_members" ("circle_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "member_approvals" (
+    "id" bigserial,
+    "circle_id" bigint NOT NULL,
+    "pending_user_id" bigint NOT NULL,
+    "approver_user_id" bigint NOT NULL,
+    "approved" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_member_approvals_deleted_at" ON "member_approvals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+
+CREATE TABLE IF NOT EXISTS "contributions" (
+    "id" bigserial,
+    "circle_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "amount" bigint NOT NULL,
+    "month" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_contributions_deleted_at" ON "contributions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_contribution" ON "contributions" ("circle_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "amount_approvals" (
+    "id" bigserial,
+    "circle_id" bigint NOT NULL,
+    "proposer_id" bigint NOT NULL,
+    "proposed_amount" bigint NOT NULL,
+    "approver_id" bigint NOT NULL,
+    "approved" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_amount_approvals_deleted_at" ON "amount_approvals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_deleted_at" ON "password_reset_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_reset_tokens_token" ON "password_reset_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "email_verification_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "email" text NOT NULL DEFAULT '',
+    "token" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_email_verification_tokens_deleted_at" ON "email_verification_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_email_verification_tokens_token" ON "email_verification_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_email_verification_tokens_user_id" ON "email_verification_tokens" ("user_id");
+-- Added after the first release
+ALTER TABLE "email_verification_tokens" ADD COLUMN IF NOT EXISTS "email" text NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "account_unlock_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "token" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "used" boolean DEFAULT false,
+    "created_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_account_unlock_tokens_deleted_at" ON "account_unlock_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_account_unlock_tokens_token" ON "account_unlock_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_account_unlock_tokens_user_id" ON "account_unlock_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "rate_limits" (
+    "key" varchar(255),
+    "count" bigint NOT NULL DEFAULT 0,
+    "reset_at" timestamptz NOT NULL,
+    "blocked_until" timestamptz,
+    PRIMARY KEY ("key")
+);
+CREATE INDEX IF NOT EXISTS "idx_rate_limits_blocked_until" ON "rate_limits" ("blocked_until");
+CREATE INDEX IF NOT EXISTS "idx_rate_limits_reset_at" ON "rate_limits" ("reset_at");
+
+CREATE TABLE IF NOT EXISTS "external_identities" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "provider" text NOT NULL,
+    "subject" text NOT NULL,
+    "email" text,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_external_identity_subject" ON "external_identities" ("provider","subject");
+CREATE INDEX IF NOT EXISTS "idx_external_identities_user_id" ON "external_identities" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "oidc_states" (
+    "id" bigserial,
+    "state" text NOT NULL,
+    "provider" text NOT NULL,
+    "nonce" text NOT NULL,
+    "code_verifier" text NOT NULL,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_oidc_states_expires_at" ON "oidc_states" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_states_state" ON "oidc_states" ("state");
+
+CREATE TABLE IF NOT EXISTS "personal_access_tokens" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "name" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "hint" text NOT NULL,
+    "scopes" text NOT NULL,
+    "expires_at" timestamptz,
+    "last_used_at" timestamptz,
+    "last_used_ip" text,
+    "revoked_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_personal_access_tokens_token_hash" ON "personal_access_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_personal_access_tokens_user_id" ON "personal_access_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "circle_id" bigint,
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "events" text NOT NULL,
+    "active" boolean NOT NULL DEFAULT true,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    "deleted_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_deleted_at" ON "webhooks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_circle_id" ON "webhooks" ("circle_id");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" bigserial,
+    "webhook_id" bigint NOT NULL,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "response_status" bigint,
+    "last_error" text,
+    "delivered_at" timestamptz,
+    "created_at" timestamptz,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_delivery_due" ON "webhook_deliveries" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries" ("webhook_id");
+
+CREATE TABLE IF NOT EXISTS "outbox_messages" (
+    "id" bigserial,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "circle_id" bigint NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "next_attempt_at" timestamptz NOT NULL,
+    "last_error" text,
+    "published_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_status_due" ON "outbox_messages" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_circle_status" ON "outbox_messages" ("circle_id","status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_outbox_messages_event_id" ON "outbox_messages" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "notifications" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "type" text NOT NULL,
+    "circle_id" bigint,
+    "title" text NOT NULL,
+    "body" text,
+    "source_id" text NOT NULL,
+    "read_at" timestamptz,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_notifications_circle_id" ON "notifications" ("circle_id");
+CREATE INDEX IF NOT EXISTS "idx_notification_user_read" ON "notifications" ("user_id","read_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_source" ON "notifications" ("user_id","source_id");
+
+CREATE TABLE IF NOT EXISTS "notification_preferences" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "type" text NOT NULL,
+    "in_app" boolean NOT NULL,
+    "updated_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_preference" ON "notification_preferences" ("user_id","type");
+
+CREATE TABLE IF NOT EXISTS "stream_events" (
+    "id" bigserial,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "circle_id" bigint NOT NULL,
+    "payload" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_stream_events_created_at" ON "stream_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_stream_events_circle_id" ON "stream_events" ("circle_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_stream_events_event_id" ON "stream_events" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "payment_reminders" (
+    "id" bigserial,
+    "circle_id" bigint NOT NULL,
+    "user_id" bigint NOT NULL,
+    "period" timestamptz NOT NULL,
+    "kind" text NOT NULL,
+    "sequence" bigint NOT NULL,
+    "channel" text NOT NULL,
+    "sent_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_payment_reminders_user_id" ON "payment_reminders" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_reminder" ON "payment_reminders" ("circle_id","user_id","period","kind","sequence","channel");
+
+CREATE TABLE IF NOT EXISTS "phone_verifications" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "phone" text NOT NULL,
+    "code" text NOT NULL,
+    "attempts" bigint NOT NULL DEFAULT 0,
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_phone_verifications_user_id" ON "phone_verifications" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "sms_notifications" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "source_id" text NOT NULL,
+    "created_at" timestamptz,
+    PRIMARY KEY ("id")
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sms_notification" ON "sms_notifications" ("user_id","source_id");
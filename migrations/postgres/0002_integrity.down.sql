@@ -0,0 +1,26 @@
+-- Drops the constraints; duplicates removed by the up migration stay removed.
+
+ALTER TABLE "contributions"
+    DROP CONSTRAINT IF EXISTS "fk_contributions_circle",
+    DROP CONSTRAINT IF EXISTS "fk_contributions_user";
+
+ALTER TABLE "amount_approvals"
+    DROP CONSTRAINT IF EXISTS "fk_amount_approvals_approver",
+    DROP CONSTRAINT IF EXISTS "fk_amount_approvals_proposer";
+
+ALTER TABLE "member_approvals"
+    DROP CONSTRAINT IF EXISTS "fk_member_approvals_pending",
+    DROP CONSTRAINT IF EXISTS "fk_member_approvals_approver";
+
+ALTER TABLE "circle_members"
+    DROP CONSTRAINT IF EXISTS "chk_circle_members_role",
+    DROP CONSTRAINT IF EXISTS "chk_circle_members_status",
+    DROP CONSTRAINT IF EXISTS "fk_circle_members_circle",
+    DROP CONSTRAINT IF EXISTS "fk_circle_members_user";
+
+DROP INDEX IF EXISTS "idx_amount_approval";
+CREATE INDEX "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+DROP INDEX IF EXISTS "idx_member_approval";
+CREATE INDEX "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+DROP INDEX IF EXISTS "idx_circle_user";
+CREATE INDEX "idx_circle_user" ON "circle_members" ("circle_id","user_id");
@@ -0,0 +1,76 @@
+-- One membership per user and circle, one vote per voter, votes only by and
+-- on members, and known roles and statuses. Rows breaking these rules are
+-- repaired or removed first. Contributions are never removed: if one
+-- references a missing user or circle this migration fails instead.
+
+-- Keep the active membership, or else the oldest
+DELETE FROM circle_members WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, user_id ORDER BY status = 'active' DESC, id
+        ) AS n
+        FROM circle_members
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM circle_members
+WHERE circle_id NOT IN (SELECT id FROM circles) OR user_id NOT IN (SELECT id FROM users);
+UPDATE circle_members SET role = 'member' WHERE role NOT IN ('admin', 'member');
+UPDATE circle_members SET status = 'pending' WHERE status NOT IN ('pending', 'active');
+
+-- Keep a cast vote, or else the oldest
+DELETE FROM member_approvals WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, pending_user_id, approver_user_id ORDER BY approved DESC, id
+        ) AS n
+        FROM member_approvals
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM member_approvals a
+WHERE NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.pending_user_id)
+   OR NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.approver_user_id);
+
+DELETE FROM amount_approvals WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, approver_id ORDER BY approved DESC, id
+        ) AS n
+        FROM amount_approvals
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM amount_approvals a
+WHERE NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.approver_id)
+   OR proposer_id NOT IN (SELECT id FROM users);
+
+DROP INDEX IF EXISTS "idx_circle_user";
+CREATE UNIQUE INDEX "idx_circle_user" ON "circle_members" ("circle_id","user_id");
+DROP INDEX IF EXISTS "idx_member_approval";
+CREATE UNIQUE INDEX "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+DROP INDEX IF EXISTS "idx_amount_approval";
+CREATE UNIQUE INDEX "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+
+ALTER TABLE "circle_members"
+    ADD CONSTRAINT "chk_circle_members_role" CHECK (role IN ('admin', 'member')),
+    ADD CONSTRAINT "chk_circle_members_status" CHECK (status IN ('pending', 'active')),
+    ADD CONSTRAINT "fk_circle_members_circle" FOREIGN KEY ("circle_id") REFERENCES "circles"("id") ON DELETE CASCADE,
+    ADD CONSTRAINT "fk_circle_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE;
+
+-- Leaving a circle withdraws the member's votes and the votes on them
+ALTER TABLE "member_approvals"
+    ADD CONSTRAINT "fk_member_approvals_pending" FOREIGN KEY ("circle_id","pending_user_id")
+        REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE,
+    ADD CONSTRAINT "fk_member_approvals_approver" FOREIGN KEY ("circle_id","approver_user_id")
+        REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE;
+
+ALTER TABLE "amount_approvals"
+    ADD CONSTRAINT "fk_amount_approvals_approver" FOREIGN KEY ("circle_id","approver_id")
+        REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE,
+    ADD CONSTRAINT "fk_amount_approvals_proposer" FOREIGN KEY ("proposer_id") REFERENCES "users"("id") ON DELETE CASCADE;
+
+-- Contributions outlive memberships; users and circles are never hard deleted
+ALTER TABLE "contributions"
+    ADD CONSTRAINT "fk_contributions_circle" FOREIGN KEY ("circle_id") REFERENCES "circles"("id") ON DELETE RESTRICT,
+    ADD CONSTRAINT "fk_contributions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE RESTRICT;
@@ -0,0 +1 @@
+ALTER TABLE "circles" DROP COLUMN IF EXISTS "version";
@@ -0,0 +1,4 @@
+-- Every change to a circle increments its version, so a writer holding a
+-- stale copy is detected instead of overwriting the newer one.
+
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "version" bigint NOT NULL DEFAULT 1;
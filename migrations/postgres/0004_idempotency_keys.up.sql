@@ -0,0 +1,18 @@
+-- Responses to requests sent with an Idempotency-Key header, replayed to
+-- retries until they expire.
+
+CREATE TABLE IF NOT EXISTS "idempotency_keys" (
+    "id" bigserial,
+    "user_id" bigint NOT NULL,
+    "key" varchar(255) NOT NULL,
+    "fingerprint" text NOT NULL,
+    "status_code" bigint,
+    "content_type" text,
+    "body" bytea,
+    "created_at" timestamptz,
+    "expires_at" timestamptz NOT NULL,
+    PRIMARY KEY ("id"),
+    CONSTRAINT "fk_idempotency_keys_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_user_key" ON "idempotency_keys" ("user_id","key");
+CREATE INDEX IF NOT EXISTS "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
@@ -0,0 +1,3 @@
+UPDATE "users" SET "email_verified_at" = NULL
+WHERE "email_verified_at" = "created_at"
+  AND NOT EXISTS (SELECT 1 FROM "email_verification_tokens" WHERE "email_verification_tokens"."user_id" = "users"."id");
@@ -0,0 +1,9 @@
+-- Accounts created before email verification existed never got a
+-- verification email, so they count as verified from the day they were
+-- created. Every account registered since has a verification token, and
+-- only anonymized accounts lose theirs.
+
+UPDATE "users" SET "email_verified_at" = "created_at"
+WHERE "email_verified_at" IS NULL
+  AND "anonymized_at" IS NULL
+  AND NOT EXISTS (SELECT 1 FROM "email_verification_tokens" WHERE "email_verification_tokens"."user_id" = "users"."id");
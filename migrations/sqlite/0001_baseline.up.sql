@@ -0,0 +1,339 @@
+-- Baseline: the schema as created by AutoMigrate before versioned migrations.
+-- Everything is IF NOT EXISTS so databases created by AutoMigrate adopt it,
+-- whichever release created them: missing tables and indexes are created,
+-- and columns added to existing tables since the first release are added.
+-- SQLite lacks ADD COLUMN IF NOT EXISTS; the migration runner skips columns
+-- that exist.
+
+CREATE TABLE IF NOT EXISTS "users" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "email" text NOT NULL,
+    "password" text NOT NULL,
+    "name" text NOT NULL,
+    "email_verified_at" datetime,
+    "avatar_url" text,
+    "phone" text NOT NULL DEFAULT '',
+    "phone_verified_at" datetime,
+    "digest_frequency" text NOT NULL DEFAULT 'off',
+    "time_zone" text NOT NULL DEFAULT 'UTC',
+    "digest_sent_at" datetime,
+    "totp_secret" text,
+    "totp_enabled_at" datetime,
+    "totp_last_step" integer DEFAULT 0,
+    "locked_until" datetime,
+    "anonymized_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_email" ON "users" ("email");
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+-- Added after the first release
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "email_verified_at" datetime;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "avatar_url" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "phone" text NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "phone_verified_at" datetime;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "digest_frequency" text NOT NULL DEFAULT 'off';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "time_zone" text NOT NULL DEFAULT 'UTC';
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "digest_sent_at" datetime;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_enabled_at" datetime;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "totp_last_step" integer DEFAULT 0;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "locked_until" datetime;
+ALTER TABLE "users" ADD COLUMN IF NOT EXISTS "anonymized_at" datetime;
+
+CREATE TABLE IF NOT EXISTS "circles" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime,
+    "name" text NOT NULL,
+    "description" text,
+    "amount_per_member" integer NOT NULL DEFAULT 0,
+    "proposed_amount" integer DEFAULT 0,
+    "creator_id" integer NOT NULL,
+    "require_two_factor" numeric NOT NULL DEFAULT false,
+    "payment_due_day" integer NOT NULL DEFAULT 1,
+    "reminders_enabled" numeric NOT NULL DEFAULT true,
+    "reminder_days_before" integer NOT NULL DEFAULT 3,
+    "overdue_reminder_days" integer NOT NULL DEFAULT 7,
+    CONSTRAINT "fk_circles_creator" FOREIGN KEY ("creator_id") REFERENCES "users"("id")
+);
+CREATE INDEX IF NOT EXISTS "idx_circles_deleted_at" ON "circles" ("deleted_at");
+-- Added after the first release
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "require_two_factor" numeric NOT NULL DEFAULT false;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "payment_due_day" integer NOT NULL DEFAULT 1;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "reminders_enabled" numeric NOT NULL DEFAULT true;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "reminder_days_before" integer NOT NULL DEFAULT 3;
+ALTER TABLE "circles" ADD COLUMN IF NOT EXISTS "overdue_reminder_days" integer NOT NULL DEFAULT 7;
+
+CREATE TABLE IF NOT EXISTS "circle_members" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "role" text NOT NULL DEFAULT 'member',
+    "status" text NOT NULL DEFAULT 'active',
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_circle_members_deleted_at" ON "circle_members" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_circle_status" ON "circle_members" ("circle_id","status");
+CREATE INDEX IF NOT EXISTS "idx_circle_user" ON "circle_members" ("circle_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "member_approvals" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "pending_user_id" integer NOT NULL,
+    "approver_user_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_member_approvals_deleted_at" ON "member_approvals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+
+CREATE TABLE IF NOT EXISTS "contributions" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "amount" integer NOT NULL,
+    "month" datetime NOT NULL,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_contributions_deleted_at" ON "contributions" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_contribution" ON "contributions" ("circle_id","user_id");
+
+CREATE TABLE IF NOT EXISTS "amount_approvals" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "proposer_id" integer NOT NULL,
+    "proposed_amount" integer NOT NULL,
+    "approver_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_amount_approvals_deleted_at" ON "amount_approvals" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "token" text NOT NULL,
+    "expires_at" datetime NOT NULL,
+    "used" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_deleted_at" ON "password_reset_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_password_reset_tokens_token" ON "password_reset_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_password_reset_tokens_user_id" ON "password_reset_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "email_verification_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "email" text NOT NULL DEFAULT '',
+    "token" text NOT NULL,
+    "expires_at" datetime NOT NULL,
+    "used" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_email_verification_tokens_deleted_at" ON "email_verification_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_email_verification_tokens_token" ON "email_verification_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_email_verification_tokens_user_id" ON "email_verification_tokens" ("user_id");
+-- Added after the first release
+ALTER TABLE "email_verification_tokens" ADD COLUMN IF NOT EXISTS "email" text NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" datetime,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "account_unlock_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "token" text NOT NULL,
+    "expires_at" datetime NOT NULL,
+    "used" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_account_unlock_tokens_deleted_at" ON "account_unlock_tokens" ("deleted_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_account_unlock_tokens_token" ON "account_unlock_tokens" ("token");
+CREATE INDEX IF NOT EXISTS "idx_account_unlock_tokens_user_id" ON "account_unlock_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "rate_limits" (
+    "key" text,
+    "count" integer NOT NULL DEFAULT 0,
+    "reset_at" datetime NOT NULL,
+    "blocked_until" datetime,
+    PRIMARY KEY ("key")
+);
+CREATE INDEX IF NOT EXISTS "idx_rate_limits_blocked_until" ON "rate_limits" ("blocked_until");
+CREATE INDEX IF NOT EXISTS "idx_rate_limits_reset_at" ON "rate_limits" ("reset_at");
+
+CREATE TABLE IF NOT EXISTS "external_identities" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "provider" text NOT NULL,
+    "subject" text NOT NULL,
+    "email" text,
+    "created_at" datetime,
+    "updated_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_external_identity_subject" ON "external_identities" ("provider","subject");
+CREATE INDEX IF NOT EXISTS "idx_external_identities_user_id" ON "external_identities" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "oidc_states" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "state" text NOT NULL,
+    "provider" text NOT NULL,
+    "nonce" text NOT NULL,
+    "code_verifier" text NOT NULL,
+    "expires_at" datetime NOT NULL,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_oidc_states_expires_at" ON "oidc_states" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_oidc_states_state" ON "oidc_states" ("state");
+
+CREATE TABLE IF NOT EXISTS "personal_access_tokens" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "name" text NOT NULL,
+    "token_hash" text NOT NULL,
+    "hint" text NOT NULL,
+    "scopes" text NOT NULL,
+    "expires_at" datetime,
+    "last_used_at" datetime,
+    "last_used_ip" text,
+    "revoked_at" datetime,
+    "created_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_personal_access_tokens_token_hash" ON "personal_access_tokens" ("token_hash");
+CREATE INDEX IF NOT EXISTS "idx_personal_access_tokens_user_id" ON "personal_access_tokens" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhooks" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "circle_id" integer,
+    "url" text NOT NULL,
+    "secret" text NOT NULL,
+    "events" text NOT NULL,
+    "active" numeric NOT NULL DEFAULT true,
+    "created_at" datetime,
+    "updated_at" datetime,
+    "deleted_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_webhooks_deleted_at" ON "webhooks" ("deleted_at");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_circle_id" ON "webhooks" ("circle_id");
+CREATE INDEX IF NOT EXISTS "idx_webhooks_user_id" ON "webhooks" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "webhook_deliveries" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "webhook_id" integer NOT NULL,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "next_attempt_at" datetime NOT NULL,
+    "response_status" integer,
+    "last_error" text,
+    "delivered_at" datetime,
+    "created_at" datetime,
+    "updated_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_webhook_delivery_due" ON "webhook_deliveries" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_event_id" ON "webhook_deliveries" ("event_id");
+CREATE INDEX IF NOT EXISTS "idx_webhook_deliveries_webhook_id" ON "webhook_deliveries" ("webhook_id");
+
+CREATE TABLE IF NOT EXISTS "outbox_messages" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "circle_id" integer NOT NULL,
+    "payload" text NOT NULL,
+    "status" text NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "next_attempt_at" datetime NOT NULL,
+    "last_error" text,
+    "published_at" datetime,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_outbox_status_due" ON "outbox_messages" ("status","next_attempt_at");
+CREATE INDEX IF NOT EXISTS "idx_outbox_circle_status" ON "outbox_messages" ("circle_id","status");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_outbox_messages_event_id" ON "outbox_messages" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "notifications" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "type" text NOT NULL,
+    "circle_id" integer,
+    "title" text NOT NULL,
+    "body" text,
+    "source_id" text NOT NULL,
+    "read_at" datetime,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_notifications_circle_id" ON "notifications" ("circle_id");
+CREATE INDEX IF NOT EXISTS "idx_notification_user_read" ON "notifications" ("user_id","read_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_source" ON "notifications" ("user_id","source_id");
+
+CREATE TABLE IF NOT EXISTS "notification_preferences" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "type" text NOT NULL,
+    "in_app" numeric NOT NULL,
+    "updated_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_notification_preference" ON "notification_preferences" ("user_id","type");
+
+CREATE TABLE IF NOT EXISTS "stream_events" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "event_id" text NOT NULL,
+    "event_type" text NOT NULL,
+    "circle_id" integer NOT NULL,
+    "payload" text NOT NULL,
+    "created_at" datetime
+);
+CREATE INDEX IF NOT EXISTS "idx_stream_events_created_at" ON "stream_events" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_stream_events_circle_id" ON "stream_events" ("circle_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_stream_events_event_id" ON "stream_events" ("event_id");
+
+CREATE TABLE IF NOT EXISTS "payment_reminders" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "period" datetime NOT NULL,
+    "kind" text NOT NULL,
+    "sequence" integer NOT NULL,
+    "channel" text NOT NULL,
+    "sent_at" datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS "idx_payment_reminders_user_id" ON "payment_reminders" ("user_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payment_reminder" ON "payment_reminders" ("circle_id","user_id","period","kind","sequence","channel");
+
+CREATE TABLE IF NOT EXISTS "phone_verifications" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "phone" text NOT NULL,
+    "code" text NOT NULL,
+    "attempts" integer NOT NULL DEFAULT 0,
+    "expires_at" datetime NOT NULL,
+    "created_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_phone_verifications_user_id" ON "phone_verifications" ("user_id");
+
+CREATE TABLE IF NOT EXISTS "sms_notifications" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "source_id" text NOT NULL,
+    "created_at" datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_sms_notification" ON "sms_notifications" ("user_id","source_id");
@@ -0,0 +1,68 @@
+-- Rebuilds the tables without the constraints; duplicates removed by the up
+-- migration stay removed.
+
+CREATE TABLE "contributions_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "amount" integer NOT NULL,
+    "month" datetime NOT NULL,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+INSERT INTO "contributions_new" ("id","circle_id","user_id","amount","month","created_at","deleted_at")
+SELECT "id","circle_id","user_id","amount","month","created_at","deleted_at" FROM "contributions";
+DROP TABLE "contributions";
+ALTER TABLE "contributions_new" RENAME TO "contributions";
+CREATE INDEX "idx_contributions_deleted_at" ON "contributions" ("deleted_at");
+CREATE INDEX "idx_contribution" ON "contributions" ("circle_id","user_id");
+
+CREATE TABLE "amount_approvals_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "proposer_id" integer NOT NULL,
+    "proposed_amount" integer NOT NULL,
+    "approver_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+INSERT INTO "amount_approvals_new" ("id","circle_id","proposer_id","proposed_amount","approver_id","approved","created_at","deleted_at")
+SELECT "id","circle_id","proposer_id","proposed_amount","approver_id","approved","created_at","deleted_at" FROM "amount_approvals";
+DROP TABLE "amount_approvals";
+ALTER TABLE "amount_approvals_new" RENAME TO "amount_approvals";
+CREATE INDEX "idx_amount_approvals_deleted_at" ON "amount_approvals" ("deleted_at");
+CREATE INDEX "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+
+CREATE TABLE "member_approvals_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "pending_user_id" integer NOT NULL,
+    "approver_user_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime
+);
+INSERT INTO "member_approvals_new" ("id","circle_id","pending_user_id","approver_user_id","approved","created_at","deleted_at")
+SELECT "id","circle_id","pending_user_id","approver_user_id","approved","created_at","deleted_at" FROM "member_approvals";
+DROP TABLE "member_approvals";
+ALTER TABLE "member_approvals_new" RENAME TO "member_approvals";
+CREATE INDEX "idx_member_approvals_deleted_at" ON "member_approvals" ("deleted_at");
+CREATE INDEX "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+
+CREATE TABLE "circle_members_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "role" text NOT NULL DEFAULT 'member',
+    "status" text NOT NULL DEFAULT 'active',
+    "deleted_at" datetime
+);
+INSERT INTO "circle_members_new" ("id","created_at","circle_id","user_id","role","status","deleted_at")
+SELECT "id","created_at","circle_id","user_id","role","status","deleted_at" FROM "circle_members";
+DROP TABLE "circle_members";
+ALTER TABLE "circle_members_new" RENAME TO "circle_members";
+CREATE INDEX "idx_circle_members_deleted_at" ON "circle_members" ("deleted_at");
+CREATE INDEX "idx_circle_status" ON "circle_members" ("circle_id","status");
+CREATE INDEX "idx_circle_user" ON "circle_members" ("circle_id","user_id");
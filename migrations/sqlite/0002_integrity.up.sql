@@ -0,0 +1,126 @@
+-- One membership per user and circle, one vote per voter, votes only by and
+-- on members, and known roles and statuses. Rows breaking these rules are
+-- repaired or removed first. Contributions are never removed: if one
+-- references a missing user or circle this migration fails instead.
+--
+-- SQLite can't add constraints to a table, so the tables are rebuilt.
+
+-- Keep the active membership, or else the oldest
+DELETE FROM circle_members WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, user_id ORDER BY status = 'active' DESC, id
+        ) AS n
+        FROM circle_members
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM circle_members
+WHERE circle_id NOT IN (SELECT id FROM circles) OR user_id NOT IN (SELECT id FROM users);
+UPDATE circle_members SET role = 'member' WHERE role NOT IN ('admin', 'member');
+UPDATE circle_members SET status = 'pending' WHERE status NOT IN ('pending', 'active');
+
+-- Keep a cast vote, or else the oldest
+DELETE FROM member_approvals WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, pending_user_id, approver_user_id ORDER BY approved DESC, id
+        ) AS n
+        FROM member_approvals
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM member_approvals AS a
+WHERE NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.pending_user_id)
+   OR NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.approver_user_id);
+
+DELETE FROM amount_approvals WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (
+            PARTITION BY circle_id, approver_id ORDER BY approved DESC, id
+        ) AS n
+        FROM amount_approvals
+    ) ranked
+    WHERE n > 1
+);
+DELETE FROM amount_approvals AS a
+WHERE NOT EXISTS (SELECT 1 FROM circle_members m WHERE m.circle_id = a.circle_id AND m.user_id = a.approver_id)
+   OR proposer_id NOT IN (SELECT id FROM users);
+
+CREATE TABLE "circle_members_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "created_at" datetime,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "role" text NOT NULL DEFAULT 'member',
+    "status" text NOT NULL DEFAULT 'active',
+    "deleted_at" datetime,
+    CONSTRAINT "chk_circle_members_role" CHECK (role IN ('admin', 'member')),
+    CONSTRAINT "chk_circle_members_status" CHECK (status IN ('pending', 'active')),
+    CONSTRAINT "fk_circle_members_circle" FOREIGN KEY ("circle_id") REFERENCES "circles"("id") ON DELETE CASCADE,
+    CONSTRAINT "fk_circle_members_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+INSERT INTO "circle_members_new" ("id","created_at","circle_id","user_id","role","status","deleted_at")
+SELECT "id","created_at","circle_id","user_id","role","status","deleted_at" FROM "circle_members";
+DROP TABLE "circle_members";
+ALTER TABLE "circle_members_new" RENAME TO "circle_members";
+CREATE INDEX "idx_circle_members_deleted_at" ON "circle_members" ("deleted_at");
+CREATE INDEX "idx_circle_status" ON "circle_members" ("circle_id","status");
+CREATE UNIQUE INDEX "idx_circle_user" ON "circle_members" ("circle_id","user_id");
+
+-- Leaving a circle withdraws the member's votes and the votes on them
+CREATE TABLE "member_approvals_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "pending_user_id" integer NOT NULL,
+    "approver_user_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_member_approvals_pending" FOREIGN KEY ("circle_id","pending_user_id") REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE,
+    CONSTRAINT "fk_member_approvals_approver" FOREIGN KEY ("circle_id","approver_user_id") REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE
+);
+INSERT INTO "member_approvals_new" ("id","circle_id","pending_user_id","approver_user_id","approved","created_at","deleted_at")
+SELECT "id","circle_id","pending_user_id","approver_user_id","approved","created_at","deleted_at" FROM "member_approvals";
+DROP TABLE "member_approvals";
+ALTER TABLE "member_approvals_new" RENAME TO "member_approvals";
+CREATE INDEX "idx_member_approvals_deleted_at" ON "member_approvals" ("deleted_at");
+CREATE UNIQUE INDEX "idx_member_approval" ON "member_approvals" ("circle_id","pending_user_id","approver_user_id");
+
+CREATE TABLE "amount_approvals_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "proposer_id" integer NOT NULL,
+    "proposed_amount" integer NOT NULL,
+    "approver_id" integer NOT NULL,
+    "approved" numeric DEFAULT false,
+    "created_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_amount_approvals_approver" FOREIGN KEY ("circle_id","approver_id") REFERENCES "circle_members"("circle_id","user_id") ON DELETE CASCADE,
+    CONSTRAINT "fk_amount_approvals_proposer" FOREIGN KEY ("proposer_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+INSERT INTO "amount_approvals_new" ("id","circle_id","proposer_id","proposed_amount","approver_id","approved","created_at","deleted_at")
+SELECT "id","circle_id","proposer_id","proposed_amount","approver_id","approved","created_at","deleted_at" FROM "amount_approvals";
+DROP TABLE "amount_approvals";
+ALTER TABLE "amount_approvals_new" RENAME TO "amount_approvals";
+CREATE INDEX "idx_amount_approvals_deleted_at" ON "amount_approvals" ("deleted_at");
+CREATE UNIQUE INDEX "idx_amount_approval" ON "amount_approvals" ("circle_id","approver_id");
+
+-- Contributions outlive memberships; users and circles are never hard deleted
+CREATE TABLE "contributions_new" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "circle_id" integer NOT NULL,
+    "user_id" integer NOT NULL,
+    "amount" integer NOT NULL,
+    "month" datetime NOT NULL,
+    "created_at" datetime,
+    "deleted_at" datetime,
+    CONSTRAINT "fk_contributions_circle" FOREIGN KEY ("circle_id") REFERENCES "circles"("id") ON DELETE RESTRICT,
+    CONSTRAINT "fk_contributions_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE RESTRICT
+);
+INSERT INTO "contributions_new" ("id","circle_id","user_id","amount","month","created_at","deleted_at")
+SELECT "id","circle_id","user_id","amount","month","created_at","deleted_at" FROM "contributions";
+DROP TABLE "contributions";
+ALTER TABLE "contributions_new" RENAME TO "contributions";
+CREATE INDEX "idx_contributions_deleted_at" ON "contributions" ("deleted_at");
+CREATE INDEX "idx_contribution" ON "contributions" ("circle_id","user_id");
@@ -0,0 +1,17 @@
+-- Responses to requests sent with an Idempotency-Key header, replayed to
+-- retries until they expire.
+
+CREATE TABLE IF NOT EXISTS "idempotency_keys" (
+    "id" integer PRIMARY KEY AUTOINCREMENT,
+    "user_id" integer NOT NULL,
+    "key" text NOT NULL,
+    "fingerprint" text NOT NULL,
+    "status_code" integer,
+    "content_type" text,
+    "body" blob,
+    "created_at" datetime,
+    "expires_at" datetime NOT NULL,
+    CONSTRAINT "fk_idempotency_keys_user" FOREIGN KEY ("user_id") REFERENCES "users"("id") ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_idempotency_user_key" ON "idempotency_keys" ("user_id","key");
+CREATE INDEX IF NOT EXISTS "idx_idempotency_keys_expires_at" ON "idempotency_keys" ("expires_at");
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/Sudan23/dhukuti/internal/config"
 	"github.com/Sudan23/dhukuti/internal/database"
@@ -22,11 +23,12 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	// Create sample users
+	// Create sample users (pre-verified so they can use circles straight away)
+	verifiedAt := time.Now()
 	users := []models.User{
-		{Email: "alice@example.com", Name: "Alice Smith"},
-		{Email: "bob@example.com", Name: "Bob Johnson"},
-		{Email: "charlie@example.com", Name: "Charlie Brown"},
+		{Email: "alice@example.com", Name: "Alice Smith", EmailVerifiedAt: &verifiedAt},
+		{Email: "bob@example.com", Name: "Bob Johnson", EmailVerifiedAt: &verifiedAt},
+		{Email: "charlie@example.com", Name: "Charlie Brown", EmailVerifiedAt: &verifiedAt},
 	}
 
 	for i := range users {
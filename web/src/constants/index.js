@@ -27,3 +27,13 @@ export const SUCCESS_MESSAGES = {
     CIRCLE_CREATED: 'Circle created successfully!',
     MEMBER_INVITED: 'Member invited successfully!',
 };
+
+// Circle events pushed by the real-time stream
+export const CIRCLE_EVENTS = [
+    'member_added',
+    'member_approved',
+    'vote_cast',
+    'contribution_recorded',
+    'amount_proposed',
+    'amount_changed',
+];
@@ -0,0 +1,74 @@
+import { useEffect, useRef } from 'react';
+import api from '../lib/api';
+import { CIRCLE_EVENTS } from '../constants';
+
+const API_BASE_URL = import.meta.env.VITE_API_BASE_URL || '/api/v1';
+
+/**
+ * Custom hook to receive real-time events for a circle
+ * @param {string|number} circleId - The circle ID
+ * @param {Function} onEvent - Called with each event; { type: 'reset' } means events were missed
+ */
+export function useCircleEvents(circleId, onEvent) {
+    const onEventRef = useRef(onEvent);
+
+    useEffect(() => {
+        onEventRef.current = onEvent;
+    }, [onEvent]);
+
+    useEffect(() => {
+        if (!circleId) return;
+
+        let source = null;
+        let retryTimer = null;
+        let lastEventId = '';
+        let closed = false;
+
+        const connect = async () => {
+            try {
+                // EventSource cannot send the Authorization header, so the
+                // stream is opened with a short-lived ticket instead
+                const { data } = await api.post('/stream/ticket');
+                if (closed) return;
+
+                const params = new URLSearchParams({ ticket: data.ticket });
+                if (lastEventId) {
+                    params.set('last_event_id', lastEventId);
+                }
+                source = new EventSource(`${API_BASE_URL}/stream?${params}`);
+
+                const handleEvent = (message) => {
+                    lastEventId = message.lastEventId || lastEventId;
+                    const event = JSON.parse(message.data);
+                    if (String(event.circle_id) === String(circleId)) {
+                        onEventRef.current(event);
+                    }
+                };
+                CIRCLE_EVENTS.forEach((type) => source.addEventListener(type, handleEvent));
+                source.addEventListener('reset', () => onEventRef.current({ type: 'reset' }));
+
+                source.onerror = () => {
+                    // The ticket has expired by the time EventSource would
+                    // retry on its own, so reconnect with a fresh one
+                    source.close();
+                    if (!closed) {
+                        retryTimer = setTimeout(connect, 3000);
+                    }
+                };
+            } catch (err) {
+                console.error('Failed to open event stream:', err);
+                if (!closed) {
+                    retryTimer = setTimeout(connect, 10000);
+                }
+            }
+        };
+
+        connect();
+
+        return () => {
+            closed = true;
+            clearTimeout(retryTimer);
+            source?.close();
+        };
+    }, [circleId]);
+}
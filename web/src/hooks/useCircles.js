@@ -67,11 +67,12 @@ export function useCircleDetails(circleId) {
     const [loading, setLoading] = useState(true);
     const [error, setError] = useState(null);
 
-    const fetchCircleDetails = async () => {
+    // With { silent: true } the current details stay on screen while reloading
+    const fetchCircleDetails = async ({ silent = false } = {}) => {
         if (!circleId) return;
 
         try {
-            setLoading(true);
+            if (!silent) setLoading(true);
             setError(null);
             const response = await api.get(`/circles/${circleId}`);
             setCircle(response.data);
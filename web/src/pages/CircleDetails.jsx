@@ -3,6 +3,7 @@ import { useParams, useNavigate, Link } from 'react-router-dom';
 import { toast } from 'sonner';
 import api from '../lib/api';
 import { useCircleDetails } from '../hooks/useCircles';
+import { useCircleEvents } from '../hooks/useCircleEvents';
 import { getCurrentUser } from '../utils/auth';
 import { MEMBER_STATUS, SUCCESS_MESSAGES } from '../constants';
 import CircleDetailsSkeleton from '../components/skeletons/CircleDetailsSkeleton';
@@ -14,6 +15,9 @@ export default function CircleDetails() {
     const navigate = useNavigate();
     const { circle, loading, error, refetch } = useCircleDetails(id);
 
+    // Show other members' votes and contributions as they happen
+    useCircleEvents(id, () => refetch({ silent: true }));
+
     // Add member state
     const [newMemberId, setNewMemberId] = useState('');
     const [addingMember, setAddingMember] = useState(false);
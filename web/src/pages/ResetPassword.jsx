@@ -23,8 +23,8 @@ export default function ResetPassword() {
             return;
         }
 
-        if (newPassword.length < 6) {
-            toast.error('Password must be at least 6 characters');
+        if (newPassword.length < 8) {
+            toast.error('Password must be at least 8 characters');
             return;
         }
 
@@ -132,7 +132,7 @@ export default function ResetPassword() {
                                 placeholder="Enter new password"
                                 className="w-full px-4 py-3 pr-12 border border-slate-300 rounded-lg focus:ring-2 focus:ring-indigo-500 focus:border-transparent"
                                 required
-                                minLength={6}
+                                minLength={8}
                                 disabled={loading}
                             />
                             <button
@@ -156,7 +156,7 @@ export default function ResetPassword() {
                             placeholder="Confirm new password"
                             className="w-full px-4 py-3 border border-slate-300 rounded-lg focus:ring-2 focus:ring-indigo-500 focus:border-transparent"
                             required
-                            minLength={6}
+                            minLength={8}
                             disabled={loading}
                         />
                     </div>